@@ -0,0 +1,84 @@
+package netgo
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FailureAlert invokes Alert once an endpoint accumulates Threshold
+// failed attempts within Window, then stays quiet for Cooldown.
+// An endpoint is the method, host and path of the request.
+type FailureAlert struct {
+	Threshold int
+	Window    time.Duration
+	Cooldown  time.Duration
+	// Alert runs in its own goroutine
+	Alert func(endpoint string, failures int, lastErr error)
+
+	mu        sync.Mutex
+	endpoints map[string]*alertState
+}
+
+type alertState struct {
+	window  *rollingWindow
+	firedAt time.Time
+}
+
+func (a *FailureAlert) record(logger Logger, req *http.Request, resp *http.Response, err error) {
+	failed := err != nil || resp == nil || resp.StatusCode >= 500
+	if !failed || a.Threshold <= 0 || a.Alert == nil {
+		return
+	}
+	if err == nil {
+		err = &statusError{resp.StatusCode}
+	}
+	endpoint := req.Method + " " + req.URL.Host + req.URL.Path
+	now := time.Now()
+
+	a.mu.Lock()
+	if a.endpoints == nil {
+		a.endpoints = make(map[string]*alertState)
+	}
+	st, ok := a.endpoints[endpoint]
+	if !ok {
+		window := a.Window
+		if window <= 0 {
+			window = time.Minute
+		}
+		st = &alertState{window: newRollingWindow(window, 10)}
+		a.endpoints[endpoint] = st
+	}
+	st.window.add(now, true)
+	_, failures := st.window.counts(now)
+	fire := failures >= a.Threshold && now.Sub(st.firedAt) >= a.Cooldown
+	if fire {
+		st.firedAt = now
+	}
+	a.mu.Unlock()
+
+	if fire {
+		go a.alert(logger, endpoint, failures, err)
+	}
+}
+
+func (a *FailureAlert) alert(logger Logger, endpoint string, failures int, lastErr error) {
+	var err error
+	defer func() {
+		if err != nil {
+			logger.Printf("%v", err)
+		}
+	}()
+	defer recoverHook("FailureAlert", &err)
+	a.Alert(endpoint, failures, lastErr)
+}
+
+// statusError describes a failed attempt that got a response
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("netter: unexpected status %d %s", e.code, http.StatusText(e.code))
+}
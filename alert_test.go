@@ -0,0 +1,42 @@
+package netgo
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestFailureAlert(t *testing.T) {
+	fired := make(chan int, 4)
+	a := &FailureAlert{
+		Threshold: 3,
+		Window:    time.Minute,
+		Cooldown:  time.Hour,
+		Alert: func(endpoint string, failures int, lastErr error) {
+			if endpoint != "GET example.com/v1" {
+				t.Errorf("bad endpoint: %q", endpoint)
+			}
+			fired <- failures
+		},
+	}
+	req, _ := http.NewRequest("GET", "http://example.com/v1", nil)
+	for i := 0; i < 6; i++ {
+		a.record(NewClient().Logger, req, nil, errors.New("boom"))
+	}
+	a.record(NewClient().Logger, req, &http.Response{StatusCode: http.StatusOK}, nil)
+
+	select {
+	case n := <-fired:
+		if n != 3 {
+			t.Fatalf("alert fired with %d failures", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("alert never fired")
+	}
+	select {
+	case <-fired:
+		t.Fatal("alert fired again during cooldown")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
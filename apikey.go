@@ -0,0 +1,64 @@
+package netgo
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// APIKeyLocation tells where WithAPIKey puts the key
+type APIKeyLocation int
+
+const (
+	// APIKeyInHeader sends the key as a request header
+	APIKeyInHeader APIKeyLocation = iota
+	// APIKeyInQuery sends the key as a query parameter
+	APIKeyInQuery
+	// APIKeyInCookie sends the key as a cookie
+	APIKeyInCookie
+)
+
+type apiKey struct {
+	key  string
+	in   APIKeyLocation
+	name string
+}
+
+// WithAPIKey sends key under name in the header, query or cookie of
+// every attempt not setting it already. The key is masked in the lines
+// the client logs.
+func WithAPIKey(key string, in APIKeyLocation, name string) Option {
+	return func(c *Client) {
+		c.apiKey = &apiKey{key: key, in: in, name: name}
+		c.secrets = append(c.secrets, key)
+		if escaped := url.QueryEscape(key); escaped != key {
+			c.secrets = append(c.secrets, escaped)
+		}
+	}
+}
+
+// apply sets the key on req
+func (k *apiKey) apply(req *http.Request) {
+	if k == nil {
+		return
+	}
+	switch k.in {
+	case APIKeyInQuery:
+		if _, ok := req.URL.Query()[k.name]; ok {
+			return
+		}
+		param := url.QueryEscape(k.name) + "=" + url.QueryEscape(k.key)
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = param
+		} else {
+			req.URL.RawQuery += "&" + param
+		}
+	case APIKeyInCookie:
+		if _, err := req.Cookie(k.name); err != nil {
+			req.AddCookie(&http.Cookie{Name: k.name, Value: k.key})
+		}
+	default:
+		if req.Header.Get(k.name) == "" {
+			req.Header.Set(k.name, k.key)
+		}
+	}
+}
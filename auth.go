@@ -0,0 +1,119 @@
+package netgo
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WithBearerToken authorizes this request with a bearer token
+func WithBearerToken(token string) RequestOption {
+	return func(r *Request) {
+		r.authorization = "Bearer " + token
+	}
+}
+
+// WithDefaultBasicAuth sends basic auth credentials with every request
+// not authorizing itself
+func WithDefaultBasicAuth(user, pass string) Option {
+	return func(c *Client) {
+		c.authorization = basicAuth(user, pass)
+	}
+}
+
+// WithDefaultBearerToken sends a bearer token with every request not
+// authorizing itself
+func WithDefaultBearerToken(token string) Option {
+	return func(c *Client) {
+		c.authorization = "Bearer " + token
+	}
+}
+
+func basicAuth(user, pass string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+}
+
+// authorize sets the API key and the Authorization header of an
+// attempt. Credentials of the request win over a token of
+// Client.TokenSource, which wins over those of the client; the latter
+// leave an Authorization header set by the caller alone. Running per
+// attempt, the header survives PrepareRetry and middleware rebuilding
+// the request.
+func (c *Client) authorize(r *Request, req *http.Request) error {
+	if r.anonymous {
+		return nil
+	}
+	c.apiKey.apply(req)
+	switch {
+	case r.authorization != "":
+		req.Header.Set("Authorization", r.authorization)
+	case c.TokenSource != nil && !r.skipTokenSource:
+		tok, err := c.token(req.Context())
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", tok.header())
+	case c.authorization != "" && req.Header.Get("Authorization") == "":
+		req.Header.Set("Authorization", c.authorization)
+	}
+	return nil
+}
+
+// credentials digests the credentials authorize will send r with, so
+// responses cached for one principal are never served to another; it is
+// empty for anonymous requests
+func (c *Client) credentials(r *Request) string {
+	if r.anonymous {
+		return ""
+	}
+	h := sha256.New()
+	sent := false
+	add := func(kind, v string) {
+		if v != "" {
+			sent = true
+			io.WriteString(h, kind+"\x00"+v+"\x00")
+		}
+	}
+	switch {
+	case r.authorization != "":
+		add("authorization", r.authorization)
+	case c.TokenSource != nil && !r.skipTokenSource:
+		// tokens rotate, the source stands for the principal
+		add("token-source", fmt.Sprintf("%T %p", c.TokenSource, c.TokenSource))
+	default:
+		add("authorization", orDefault(r.Header.Get("Authorization"), c.authorization))
+	}
+	if c.apiKey != nil {
+		add("api-key", c.apiKey.key)
+	}
+	add("cookie", r.Header.Get("Cookie"))
+	if !sent {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil)[:12])
+}
+
+// reauthorize prepares a retry of a request rejected with 401, once per
+// request: it drops the rejected token of Client.TokenSource and runs
+// Client.OnUnauthorized. It reports false when there is nothing to
+// refresh or the hook failed.
+func (c *Client) reauthorize(r *Request) bool {
+	refresh := c.TokenSource != nil && r.authorization == ""
+	if r.reauthorized || r.anonymous || !refresh && c.OnUnauthorized == nil {
+		return false
+	}
+	r.reauthorized = true
+	if refresh {
+		c.invalidateToken(r.Header.Get("Authorization"))
+	}
+	if c.OnUnauthorized != nil {
+		if err := c.OnUnauthorized(r.Context(), r); err != nil {
+			c.logger(r).Printf("netter: %s refreshing credentials: %v", r.URL, err)
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package netgo
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Bandwidth caps the rate at which request and response bodies stream,
+// as a token bucket of bytes. A Bandwidth set on the client is shared by
+// all its requests.
+type Bandwidth struct {
+	// Rate in bytes per second
+	Rate int64
+	// Burst is the number of bytes that may pass at once, Rate by default
+	Burst int64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// WithBandwidth limits the bodies of this request to rate bytes per
+// second, on top of the limit of the client
+func WithBandwidth(rate, burst int64) RequestOption {
+	return func(r *Request) {
+		r.bandwidth = &Bandwidth{Rate: rate, Burst: burst}
+	}
+}
+
+func (b *Bandwidth) burst() int64 {
+	if b.Burst > 0 {
+		return b.Burst
+	}
+	return b.Rate
+}
+
+// take removes n bytes from the bucket and returns how long to wait
+// until the debt is paid back
+func (b *Bandwidth) take(n int, now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	burst := float64(b.burst())
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * float64(b.Rate)
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(b.Rate) * float64(time.Second))
+}
+
+// bandwidths returns the limits applying to req
+func (c *Client) bandwidths(req *Request) []*Bandwidth {
+	var limits []*Bandwidth
+	for _, b := range []*Bandwidth{c.Bandwidth, req.bandwidth} {
+		if b != nil && b.Rate > 0 {
+			limits = append(limits, b)
+		}
+	}
+	return limits
+}
+
+// throttleUpload limits the body of every attempt of req
+func (c *Client) throttleUpload(req *Request) {
+	limits := c.bandwidths(req)
+	if len(limits) == 0 || req.body == nil {
+		return
+	}
+	open, ctx := req.body, req.Context()
+	req.body = func() (io.Reader, error) {
+		src, err := open()
+		if err != nil {
+			return nil, err
+		}
+		return &throttledReader{ReadCloser: toReadCloser(src), ctx: ctx, clock: c.clock(), limits: limits}, nil
+	}
+	req.GetBody = getBody(req.body)
+}
+
+// throttleDownload limits the body of resp
+func (c *Client) throttleDownload(req *Request, resp *http.Response) {
+	limits := c.bandwidths(req)
+	if len(limits) == 0 || resp == nil || resp.Body == nil {
+		return
+	}
+	resp.Body = &throttledReader{ReadCloser: resp.Body, ctx: req.Context(), clock: c.clock(), limits: limits}
+}
+
+// throttledReader waits after every read until its bytes fit the limits
+type throttledReader struct {
+	io.ReadCloser
+	ctx    context.Context
+	clock  Clock
+	limits []*Bandwidth
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	for _, b := range r.limits {
+		if burst := b.burst(); int64(len(p)) > burst {
+			p = p[:burst]
+		}
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n == 0 {
+		return n, err
+	}
+	var wait time.Duration
+	now := time.Now()
+	for _, b := range r.limits {
+		if d := b.take(n, now); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		timer := r.clock.NewTimer(wait)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return n, r.ctx.Err()
+		case <-timer.C():
+		}
+	}
+	return n, err
+}
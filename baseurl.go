@@ -0,0 +1,64 @@
+package netgo
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parseBaseURL validates a client BaseURL
+func parseBaseURL(raw string) (*url.URL, error) {
+	base, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("netter: bad BaseURL: %v", err)
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("netter: BaseURL %q must be http or https", raw)
+	}
+	if base.Host == "" {
+		return nil, fmt.Errorf("netter: BaseURL %q has no host", raw)
+	}
+	if base.Fragment != "" {
+		return nil, fmt.Errorf("netter: BaseURL %q must not have a fragment", raw)
+	}
+	return base, nil
+}
+
+// joinURL appends the path of ref to base and merges their queries,
+// ref parameters replacing base ones of the same name
+func joinURL(base, ref *url.URL) *url.URL {
+	u := *base
+	u.User = base.User
+	if ref.Path != "" {
+		u.Path = strings.TrimSuffix(base.Path, "/") + "/" + strings.TrimPrefix(ref.Path, "/")
+		u.RawPath = ""
+		if ref.RawPath != "" || base.RawPath != "" {
+			u.RawPath = strings.TrimSuffix(base.EscapedPath(), "/") + "/" + strings.TrimPrefix(ref.EscapedPath(), "/")
+		}
+	}
+
+	q := base.Query()
+	for k, vs := range ref.Query() {
+		q[k] = vs
+	}
+	u.RawQuery = q.Encode()
+	u.Fragment = ref.Fragment
+	return &u
+}
+
+// resolveURL turns a relative request URL into an absolute one under
+// the client BaseURL
+func (c *Client) resolveURL(req *Request) error {
+	if c.BaseURL == "" || req.URL.IsAbs() || req.URL.Host != "" {
+		return nil
+	}
+	base, err := parseBaseURL(c.BaseURL)
+	if err != nil {
+		return err
+	}
+	req.URL = joinURL(base, req.URL)
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+	return nil
+}
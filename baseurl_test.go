@@ -0,0 +1,72 @@
+package netgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestJoinURL(t *testing.T) {
+	cases := []struct{ base, ref, want string }{
+		{"https://api.test/v1/", "/users", "https://api.test/v1/users"},
+		{"https://api.test/v1", "users/7?x=1", "https://api.test/v1/users/7?x=1"},
+		{"https://api.test/?key=a&lang=en", "/s?lang=de", "https://api.test/s?key=a&lang=de"},
+		{"https://api.test/v1", "/a%2Fb", "https://api.test/v1/a%2Fb"},
+	}
+	for _, c := range cases {
+		base, err := parseBaseURL(c.base)
+		if err != nil {
+			t.Fatalf("%s: %v", c.base, err)
+		}
+		ref, _ := url.Parse(c.ref)
+		if got := joinURL(base, ref).String(); got != c.want {
+			t.Errorf("join(%s, %s) = %s, want %s", c.base, c.ref, got, c.want)
+		}
+	}
+
+	for _, bad := range []string{"api.test/v1", "ftp://api.test", "https://", "https://api.test/#x"} {
+		if _, err := parseBaseURL(bad); err == nil {
+			t.Errorf("BaseURL %q should be rejected", bad)
+		}
+	}
+}
+
+func TestClientBaseURL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Path", req.URL.Path)
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.BaseURL = ts.URL + "/api"
+
+	resp, err := client.Get("/v1/users")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Path") != "/api/v1/users" {
+		t.Fatalf("bad path: %s", resp.Header.Get("X-Path"))
+	}
+}
+
+func TestClientBaseURLHostOverride(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Host", req.Host)
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.BaseURL = ts.URL
+	req, _ := NewRequest("GET", "/v1/users", nil)
+	req.SetHostOverride("tenant.example")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Host"); got != "tenant.example" {
+		t.Fatalf("host override lost: %s", got)
+	}
+}
@@ -0,0 +1,63 @@
+package netgo
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// CloseBody drains up to 4KB of the response body, so the connection can
+// be reused, and closes it. It accepts nil responses.
+func CloseBody(resp *http.Response) error {
+	if resp == nil || resp.Body == nil {
+		return nil
+	}
+	_, err := io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	if cerr := resp.Body.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// AutoCloseOn makes Do drain and close the body of a response returned
+// together with an error matching one of errs, or with any error when
+// errs is empty. The response is still returned, with an empty body.
+func AutoCloseOn(errs ...error) Option {
+	return func(c *Client) {
+		c.autoClose = func(err error) bool {
+			if len(errs) == 0 {
+				return true
+			}
+			for _, target := range errs {
+				if errors.Is(err, target) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+}
+
+func (c *Client) closeOnError(resp *http.Response, err error) {
+	if err == nil || resp == nil || resp.Body == nil || c.autoClose == nil || !c.autoClose(err) {
+		return
+	}
+	if cerr := CloseBody(resp); cerr != nil {
+		c.log().Printf("netter: closing response body: %v", cerr)
+	}
+	resp.Body = http.NoBody
+}
+
+// DoAndClose sends req, hands the response to decode and always drains
+// and closes the body afterwards
+func DoAndClose[T any](c *Client, req *Request, decode func(*http.Response) (T, error)) (T, error) {
+	resp, err := c.Do(req)
+	if err != nil {
+		CloseBody(resp)
+		var zero T
+		return zero, err
+	}
+	defer CloseBody(resp)
+	return decode(resp)
+}
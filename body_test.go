@@ -0,0 +1,144 @@
+package netgo
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDoAndClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("42"))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	req, _ := NewRequest("GET", ts.URL, nil)
+
+	var body *trackedBody
+	n, err := DoAndClose(client, req, func(resp *http.Response) (int, error) {
+		body = &trackedBody{ReadCloser: resp.Body}
+		resp.Body = body
+		b, err := ioutil.ReadAll(resp.Body)
+		return len(b), err
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("bad result: %d %v", n, err)
+	}
+	if !body.closed {
+		t.Fatal("body not closed")
+	}
+}
+
+func TestAutoCloseOn(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("rejected"))
+	}))
+	defer ts.Close()
+
+	errReject := errors.New("rejected")
+	client := NewClient(WithTransport(ts.Client().Transport), AutoCloseOn(errReject))
+	client.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		return false, errReject
+	}
+
+	resp, err := client.Get(ts.URL)
+	if err != errReject {
+		t.Fatalf("expected errReject, got %v", err)
+	}
+	if resp == nil || resp.Body != http.NoBody {
+		t.Fatalf("body should be closed and replaced: %v", resp)
+	}
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (b *trackedBody) Close() error {
+	b.closed = true
+	return b.ReadCloser.Close()
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("chunked") != "" {
+			w.(http.Flusher).Flush()
+		} else {
+			w.Header().Set("Content-Length", "10")
+		}
+		w.Write([]byte("0123456789"))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.MaxResponseBytes = 8
+	if _, err := client.Get(ts.URL); err != ErrResponseTooLarge {
+		t.Fatalf("declared length not checked: %v", err)
+	}
+
+	resp, err := client.Get(ts.URL + "?chunked=1")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != ErrResponseTooLarge {
+		t.Fatalf("streamed body not limited: %v", err)
+	}
+
+	resp, err = client.Get(ts.URL, WithMaxResponseBytes(10))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || string(b) != "0123456789" {
+		t.Fatalf("override not applied: %q %v", b, err)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	RegisterContentDecoder("x-b64", func(r io.Reader) (io.ReadCloser, error) {
+		return ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+	})
+	t.Cleanup(func() { RegisterContentDecoder("x-b64", nil) })
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !strings.HasPrefix(req.Header.Get("Accept-Encoding"), "x-b64") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.URL.Query().Get("enc") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			zw := gzip.NewWriter(w)
+			zw.Write([]byte("hello"))
+			zw.Close()
+			return
+		}
+		w.Header().Set("Content-Encoding", "x-b64")
+		w.Write([]byte(base64.StdEncoding.EncodeToString([]byte("hello"))))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.Decompress = true
+	for _, q := range []string{"", "?enc=gzip"} {
+		resp, err := client.Get(ts.URL + q)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != "hello" || resp.Header.Get("Content-Encoding") != "" {
+			t.Fatalf("%q not decoded: %d %q", q, resp.StatusCode, b)
+		}
+	}
+}
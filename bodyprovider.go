@@ -0,0 +1,122 @@
+package netgo
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// BodyProvider supplies the request body afresh for every attempt, so
+// retried and hedged requests always send it whole
+type BodyProvider interface {
+	// Open returns a reader positioned at the start of the body; it is
+	// closed after the attempt when it implements io.Closer
+	Open() (io.Reader, error)
+	// Len reports the body size in bytes, or -1 when unknown
+	Len() int64
+}
+
+type bytesBody []byte
+
+func (b bytesBody) Open() (io.Reader, error) { return bytes.NewReader(b), nil }
+func (b bytesBody) Len() int64               { return int64(len(b)) }
+
+// BodyBytes sends b; it must not be modified while the request is in use
+func BodyBytes(b []byte) BodyProvider {
+	return bytesBody(b)
+}
+
+type stringBody string
+
+func (s stringBody) Open() (io.Reader, error) { return strings.NewReader(string(s)), nil }
+func (s stringBody) Len() int64               { return int64(len(s)) }
+
+// BodyString sends s
+func BodyString(s string) BodyProvider {
+	return stringBody(s)
+}
+
+type fileBody string
+
+func (f fileBody) Open() (io.Reader, error) { return os.Open(string(f)) }
+
+func (f fileBody) Len() int64 {
+	fi, err := os.Stat(string(f))
+	if err != nil || !fi.Mode().IsRegular() {
+		return -1
+	}
+	return fi.Size()
+}
+
+// BodyFile streams the file at path, reopening it for every attempt
+func BodyFile(path string) BodyProvider {
+	return fileBody(path)
+}
+
+type funcBody func() (io.Reader, error)
+
+func (f funcBody) Open() (io.Reader, error) { return f() }
+func (f funcBody) Len() int64               { return -1 }
+
+// BodyFunc calls open for every attempt; the body size is unknown, so it
+// is sent chunked
+func BodyFunc(open func() (io.Reader, error)) BodyProvider {
+	return funcBody(open)
+}
+
+type seekerBody struct {
+	rs io.ReadSeeker
+
+	once  sync.Once
+	start int64
+	size  int64
+	err   error
+}
+
+// init records the body as the bytes from the current offset to the end
+func (s *seekerBody) init() {
+	s.once.Do(func() {
+		if s.start, s.err = s.rs.Seek(0, io.SeekCurrent); s.err != nil {
+			return
+		}
+		end, err := s.rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.size = end - s.start
+		_, s.err = s.rs.Seek(s.start, io.SeekStart)
+	})
+}
+
+func (s *seekerBody) Open() (io.Reader, error) {
+	s.init()
+	if s.err != nil {
+		return nil, s.err
+	}
+	if ra, ok := s.rs.(io.ReaderAt); ok {
+		return io.NewSectionReader(ra, s.start, s.size), nil
+	}
+	if _, err := s.rs.Seek(s.start, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.LimitReader(s.rs, s.size), nil
+}
+
+func (s *seekerBody) Len() int64 {
+	s.init()
+	if s.err != nil {
+		return -1
+	}
+	return s.size
+}
+
+// BodySeeker sends rs from its current offset to the end, seeking back
+// for every attempt. rs is never closed. Unless rs is also an
+// io.ReaderAt, attempts share its offset and it must not be used with
+// Hedge or Mirror.
+func BodySeeker(rs io.ReadSeeker) BodyProvider {
+	return &seekerBody{rs: rs}
+}
@@ -0,0 +1,113 @@
+package netgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// Builder assembles a request step by step, e.g.
+//
+//	resp, err := netgo.R().Method("POST").URL(u).Query("q", "x").
+//		Header("X", "y").JSONBody(v).Do(ctx, client)
+//
+// The first error met is reported by Build or Do.
+type Builder struct {
+	method string
+	url    string
+	query  url.Values
+	header http.Header
+	body   interface{}
+	opts   []RequestOption
+	err    error
+}
+
+// R starts a new GET request builder
+func R() *Builder {
+	return &Builder{method: "GET", query: url.Values{}, header: http.Header{}}
+}
+
+// Method sets the HTTP method
+func (b *Builder) Method(method string) *Builder {
+	b.method = method
+	return b
+}
+
+// URL sets the request URL
+func (b *Builder) URL(u string) *Builder {
+	b.url = u
+	return b
+}
+
+// Query adds a query parameter
+func (b *Builder) Query(key, value string) *Builder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Header sets a request header
+func (b *Builder) Header(key, value string) *Builder {
+	b.header.Set(key, value)
+	return b
+}
+
+// Body sets the request body; it accepts the same types as NewRequest
+func (b *Builder) Body(body interface{}) *Builder {
+	b.body = body
+	return b
+}
+
+// JSONBody sets v encoded as JSON as the body
+func (b *Builder) JSONBody(v interface{}) *Builder {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return b
+	}
+	b.body = bytes.NewReader(buf)
+	b.header.Set("Content-Type", "application/json")
+	return b
+}
+
+// Options adds request options applied by Do
+func (b *Builder) Options(opts ...RequestOption) *Builder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// Build returns the assembled request
+func (b *Builder) Build(ctx context.Context) (*Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	req, err := NewRequestWithContext(ctx, b.method, b.url, b.body)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.query) > 0 {
+		q := req.URL.Query()
+		for k, vs := range b.query {
+			for _, v := range vs {
+				q.Add(k, v)
+			}
+		}
+		req.URL.RawQuery = q.Encode()
+	}
+	for k, vs := range b.header {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+	return req, nil
+}
+
+// Do builds the request and sends it with c
+func (b *Builder) Do(ctx context.Context, c *Client) (*http.Response, error) {
+	req, err := b.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req, b.opts...)
+}
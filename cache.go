@@ -0,0 +1,667 @@
+package netgo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HTTPCache is an RFC 7234 cache of GET responses. Fresh stored
+// responses are served without contacting the origin, stale ones are
+// revalidated with their ETag or Last-Modified validators. Bodies stream
+// into the store while the caller reads them. Set it as Client.HTTPCache;
+// it runs around the retry loop, inside the RetryMiddleware.
+type HTTPCache struct {
+	// Store keeps the entries, a MemoryCacheStore by default
+	Store CacheStore
+	// Key derives the key requests are stored under, CacheKey by
+	// default. Entries are partitioned further by the credentials the
+	// client sends the request with and by the Vary header of their
+	// responses.
+	Key func(req *http.Request) string
+	// NegativeTTL, when positive, keeps responses with one of the
+	// NegativeStatuses fresh for that long unless they carry explicit
+	// freshness, so lookups of missing resources spare the origin
+	NegativeTTL time.Duration
+	// NegativeStatuses defaults to 404 and 410
+	NegativeStatuses []int
+	// Shared makes the cache behave as a shared one: s-maxage applies,
+	// private responses are not stored and neither are responses to
+	// requests with Authorization unless explicitly allowed
+	Shared bool
+
+	once  sync.Once
+	store CacheStore
+
+	mu           sync.Mutex
+	revalidating map[string]bool
+
+	hits, misses, revalidated, stale int64
+}
+
+// cacheableByDefault lists the statuses stored without explicit
+// freshness information
+var cacheableByDefault = map[int]bool{
+	200: true, 203: true, 204: true, 300: true, 301: true, 308: true,
+	404: true, 405: true, 410: true, 414: true, 501: true,
+}
+
+func (h *HTTPCache) getStore() CacheStore {
+	h.once.Do(func() {
+		h.store = h.Store
+		if h.store == nil {
+			h.store = &MemoryCacheStore{}
+		}
+	})
+	return h.store
+}
+
+// middleware serves requests sent with credentials from the cache of c
+func (h *HTTPCache) middleware(c *Client, credentials string) Middleware {
+	return func(next RoundTripperFunc) RoundTripperFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return h.roundTrip(c, credentials, req, next)
+		}
+	}
+}
+
+func (h *HTTPCache) roundTrip(c *Client, credentials string, req *http.Request, next RoundTripperFunc) (*http.Response, error) {
+	if req.Method != "GET" {
+		resp, err := next(req)
+		if err == nil && !isSafeMethod(req.Method) && resp.StatusCode < 400 {
+			h.invalidate(req, resp)
+		}
+		return resp, err
+	}
+	reqCC := parseCacheControl(req.Header)
+	if reqCC.has("no-store") || req.Header.Get("Range") != "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		// the caller handles partial and conditional responses itself
+		return next(req)
+	}
+
+	primary := partitionKey(h.key(req), credentials)
+	entry, body, key, ok := h.lookup(primary, req)
+	if !ok {
+		atomic.AddInt64(&h.misses, 1)
+		if reqCC.has("only-if-cached") {
+			return gatewayTimeout(req), nil
+		}
+		return h.fetch(c, primary, req, next)
+	}
+
+	now := time.Now()
+	age := entry.age(now)
+	if h.usable(entry, reqCC, age) {
+		atomic.AddInt64(&h.hits, 1)
+		return entry.response(req, body, age), nil
+	}
+	if reqCC.has("only-if-cached") {
+		atomic.AddInt64(&h.misses, 1)
+		body.Close()
+		return gatewayTimeout(req), nil
+	}
+
+	if h.staleWhileRevalidate(entry, reqCC, age) {
+		atomic.AddInt64(&h.stale, 1)
+		h.revalidateInBackground(c, primary, key, req, entry, next)
+		return entry.response(req, body, age), nil
+	}
+
+	resp, err := next(conditional(req, entry))
+	if (err != nil || resp.StatusCode >= 500) && h.staleIfError(entry, reqCC, age) {
+		if err == nil {
+			CloseBody(resp)
+			err = fmt.Errorf("status %s", resp.Status)
+		}
+		c.log().Printf("netter: cache serving stale %s: %v", req.URL, err)
+		atomic.AddInt64(&h.stale, 1)
+		return entry.response(req, body, age), nil
+	}
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusNotModified {
+		atomic.AddInt64(&h.misses, 1)
+		body.Close()
+		return h.save(c, primary, req, resp, now), nil
+	}
+	atomic.AddInt64(&h.revalidated, 1)
+	entry = h.freshen(c, key, entry, resp, now)
+	return entry.response(req, body, entry.age(time.Now())), nil
+}
+
+// conditional returns req asking to validate entry, or req itself when
+// the entry has no validators
+func conditional(req *http.Request, entry *CacheEntry) *http.Request {
+	etag, lm := entry.Header.Get("Etag"), entry.Header.Get("Last-Modified")
+	if etag == "" && lm == "" {
+		return req
+	}
+	cond := req.Clone(req.Context())
+	if etag != "" {
+		cond.Header.Set("If-None-Match", etag)
+	}
+	if lm != "" {
+		cond.Header.Set("If-Modified-Since", lm)
+	}
+	return cond
+}
+
+// freshen stores the entry updated by a 304 response
+func (h *HTTPCache) freshen(c *Client, key string, entry *CacheEntry, resp *http.Response, reqTime time.Time) *CacheEntry {
+	CloseBody(resp)
+	entry = entry.freshen(resp, reqTime, time.Now())
+	if err := h.getStore().Update(key, entry); err != nil {
+		c.log().Printf("netter: cache update %s: %v", entry.URL, err)
+	}
+	return entry
+}
+
+// staleWhileRevalidate tells whether a stale entry may be served while
+// it is revalidated in the background, RFC 5861 section 3
+func (h *HTTPCache) staleWhileRevalidate(entry *CacheEntry, reqCC cacheControl, age time.Duration) bool {
+	cc := parseCacheControl(entry.Header)
+	if cc.has("no-cache") || cc.has("must-revalidate") || reqCC.has("no-cache") {
+		return false
+	}
+	window, ok := cc.seconds("stale-while-revalidate")
+	return ok && age-entry.lifetime(h.Shared) <= window
+}
+
+// staleIfError tells whether a stale entry may stand in for a failed
+// request, RFC 5861 section 4; the directive may come with the request
+// or the stored response
+func (h *HTTPCache) staleIfError(entry *CacheEntry, reqCC cacheControl, age time.Duration) bool {
+	staleness := age - entry.lifetime(h.Shared)
+	for _, cc := range []cacheControl{reqCC, parseCacheControl(entry.Header)} {
+		if window, ok := cc.seconds("stale-if-error"); ok && staleness <= window {
+			return true
+		}
+	}
+	return false
+}
+
+// revalidateInBackground refreshes entry, stored under key, detached
+// from the caller, once at a time per key
+func (h *HTTPCache) revalidateInBackground(c *Client, primary, key string, req *http.Request, entry *CacheEntry, next RoundTripperFunc) {
+	h.mu.Lock()
+	if h.revalidating == nil {
+		h.revalidating = make(map[string]bool)
+	}
+	if h.revalidating[key] {
+		h.mu.Unlock()
+		return
+	}
+	h.revalidating[key] = true
+	h.mu.Unlock()
+
+	cond := conditional(req.Clone(context.WithoutCancel(req.Context())), entry)
+	go func() {
+		defer func() {
+			h.mu.Lock()
+			delete(h.revalidating, key)
+			h.mu.Unlock()
+		}()
+		now := time.Now()
+		resp, err := next(cond)
+		if err != nil {
+			c.log().Printf("netter: cache revalidating %s: %v", cond.URL, err)
+			return
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			h.freshen(c, key, entry, resp, now)
+			return
+		}
+		// reading to the end commits the new entry
+		resp = h.save(c, primary, cond, resp, now)
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+}
+
+// Fallback serves the stored response for req whatever its age, for
+// use as Client.Fallback when failures should fall back to stale
+// content even without stale-if-error
+func (h *HTTPCache) Fallback(req *Request, err error) (*http.Response, error) {
+	if req.Method != "GET" {
+		return nil, err
+	}
+	entry, body, _, ok := h.lookup(partitionKey(h.key(req.Request), req.credentials), req.Request)
+	if !ok {
+		return nil, err
+	}
+	return entry.response(req.Request, body, entry.age(time.Now())), nil
+}
+
+// fetch forwards req and stores the response if allowed
+func (h *HTTPCache) fetch(c *Client, primary string, req *http.Request, next RoundTripperFunc) (*http.Response, error) {
+	reqTime := time.Now()
+	resp, err := next(req)
+	if err != nil {
+		return nil, err
+	}
+	return h.save(c, primary, req, resp, reqTime), nil
+}
+
+// save streams resp into the store while the caller reads it. A
+// response with Vary is stored as a variant, see lookup.
+func (h *HTTPCache) save(c *Client, primary string, req *http.Request, resp *http.Response, reqTime time.Time) *http.Response {
+	if !h.storable(req, resp) {
+		return resp
+	}
+	key := primary
+	if vary := varyNames(resp.Header); len(vary) > 0 {
+		if err := h.putVariants(primary, req.URL.String(), vary); err != nil {
+			c.log().Printf("netter: cache put %s: %v", req.URL, err)
+			return resp
+		}
+		key = variantKey(primary, vary, req)
+	}
+	entry := &CacheEntry{
+		URL:          req.URL.String(),
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		VaryHeader:   varyHeader(req, resp),
+		RequestTime:  reqTime,
+		ResponseTime: time.Now(),
+	}
+	if h.negative(resp.StatusCode) && !hasExplicitFreshness(resp.Header) {
+		entry.TTL = h.NegativeTTL
+	}
+	w, err := h.getStore().Put(key, entry)
+	if err != nil {
+		c.log().Printf("netter: cache put %s: %v", req.URL, err)
+		return resp
+	}
+	teeToCache(resp, w, c.log())
+	return resp
+}
+
+// storable tells whether resp to req may be stored, RFC 7234 section 3
+func (h *HTTPCache) storable(req *http.Request, resp *http.Response) bool {
+	if resp.Body == nil || resp.StatusCode == http.StatusPartialContent {
+		return false
+	}
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") || parseCacheControl(req.Header).has("no-store") {
+		return false
+	}
+	if h.Shared {
+		if cc.has("private") {
+			return false
+		}
+		if req.Header.Get("Authorization") != "" && !cc.has("public") && !cc.has("s-maxage") && !cc.has("must-revalidate") {
+			return false
+		}
+	}
+	for _, name := range varyNames(resp.Header) {
+		if name == "*" {
+			return false
+		}
+	}
+	return cacheableByDefault[resp.StatusCode] || h.negative(resp.StatusCode) || cc.has("public") ||
+		hasExplicitFreshness(resp.Header) || (h.Shared && cc.has("s-maxage"))
+}
+
+// negative tells whether responses with code are cached for NegativeTTL
+func (h *HTTPCache) negative(code int) bool {
+	if h.NegativeTTL <= 0 {
+		return false
+	}
+	if h.NegativeStatuses == nil {
+		return code == http.StatusNotFound || code == http.StatusGone
+	}
+	for _, s := range h.NegativeStatuses {
+		if s == code {
+			return true
+		}
+	}
+	return false
+}
+
+func hasExplicitFreshness(h http.Header) bool {
+	cc := parseCacheControl(h)
+	return cc.has("max-age") || cc.has("s-maxage") || h.Get("Expires") != ""
+}
+
+// usable tells whether entry of the given age may be served without
+// revalidation, RFC 7234 sections 4.2 and 5.2.1
+func (h *HTTPCache) usable(entry *CacheEntry, reqCC cacheControl, age time.Duration) bool {
+	cc := parseCacheControl(entry.Header)
+	if cc.has("no-cache") || reqCC.has("no-cache") {
+		return false
+	}
+	lifetime := entry.lifetime(h.Shared)
+	if v, ok := reqCC.seconds("max-age"); ok && age > v {
+		return false
+	}
+	if v, ok := reqCC.seconds("min-fresh"); ok && lifetime-age < v {
+		return false
+	}
+	if age < lifetime {
+		return true
+	}
+	if cc.has("must-revalidate") || (h.Shared && cc.has("proxy-revalidate")) {
+		return false
+	}
+	if !reqCC.has("max-stale") {
+		return false
+	}
+	v, ok := reqCC.seconds("max-stale")
+	return !ok || age-lifetime <= v
+}
+
+// invalidate drops the entries an unsafe request changed, RFC 7234
+// section 4.4
+func (h *HTTPCache) invalidate(req *http.Request, resp *http.Response) {
+	store := h.getStore()
+	deletePartitions(store, h.key(&http.Request{Method: "GET", URL: req.URL, Header: req.Header}))
+	for _, name := range []string{"Location", "Content-Location"} {
+		v := resp.Header.Get(name)
+		if v == "" {
+			continue
+		}
+		if u, err := req.URL.Parse(v); err == nil && u.Host == req.URL.Host {
+			deletePartitions(store, h.key(&http.Request{Method: "GET", URL: u, Header: req.Header}))
+		}
+	}
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return true
+	}
+	return false
+}
+
+// CacheKey is the default cache key, the request URL without fragment
+func CacheKey(req *http.Request) string {
+	u := *req.URL
+	u.Fragment, u.RawFragment = "", ""
+	return u.String()
+}
+
+// CacheKeyIgnoring returns a cache key function leaving out the named
+// query parameters, e.g. tracking or cache-busting ones
+func CacheKeyIgnoring(params ...string) func(*http.Request) string {
+	return func(req *http.Request) string {
+		u := *req.URL
+		u.Fragment, u.RawFragment = "", ""
+		q := u.Query()
+		for _, p := range params {
+			q.Del(p)
+		}
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+}
+
+// partitionKey keeps the entries stored for different credentials apart
+func partitionKey(key, credentials string) string {
+	if credentials == "" {
+		return key
+	}
+	return key + "\x01" + credentials
+}
+
+// deletePartitions deletes the entries under key for all credentials,
+// Vary variants included
+func deletePartitions(store CacheStore, key string) {
+	store.Delete(key)
+	store.Range(func(k string, _ *CacheEntry) bool {
+		if strings.HasPrefix(k, key+"\x00") || strings.HasPrefix(k, key+"\x01") {
+			store.Delete(k)
+		}
+		return true
+	})
+}
+
+func (h *HTTPCache) key(req *http.Request) string {
+	if h.Key != nil {
+		return h.Key(req)
+	}
+	return CacheKey(req)
+}
+
+// lookup finds the entry for req under its primary key. Responses with
+// Vary are stored as variants: the primary key then holds a bodiless
+// marker entry listing the Vary header names and every variant lives
+// under a key derived from the request values of those headers.
+func (h *HTTPCache) lookup(primary string, req *http.Request) (*CacheEntry, io.ReadCloser, string, bool) {
+	store := h.getStore()
+	entry, body, ok := store.Get(primary)
+	if !ok {
+		return nil, nil, "", false
+	}
+	key := primary
+	if entry.StatusCode == 0 {
+		body.Close()
+		key = variantKey(primary, entry.Header.Values("Vary"), req)
+		if entry, body, ok = store.Get(key); !ok {
+			return nil, nil, "", false
+		}
+	}
+	if !varyMatches(entry, req) {
+		body.Close()
+		return nil, nil, "", false
+	}
+	return entry, body, key, true
+}
+
+// putVariants stores the marker entry listing the Vary header names
+func (h *HTTPCache) putVariants(primary, url string, vary []string) error {
+	w, err := h.getStore().Put(primary, &CacheEntry{URL: url, Header: http.Header{"Vary": vary}})
+	if err != nil {
+		return err
+	}
+	return w.Commit()
+}
+
+// variantKey derives the key of the variant of primary selected by req
+func variantKey(primary string, vary []string, req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(primary)
+	for _, name := range vary {
+		b.WriteByte(0)
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(req.Header.Values(name), ","))
+	}
+	return b.String()
+}
+
+// varyNames returns the canonical header names listed by Vary
+func varyNames(h http.Header) []string {
+	var names []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// varyHeader keeps the request headers named by the Vary of resp
+func varyHeader(req *http.Request, resp *http.Response) http.Header {
+	var h http.Header
+	for _, name := range varyNames(resp.Header) {
+		if h == nil {
+			h = http.Header{}
+		}
+		h[name] = append([]string(nil), req.Header.Values(name)...)
+	}
+	return h
+}
+
+// varyMatches tells whether req selects entry, RFC 7234 section 4.1
+func varyMatches(entry *CacheEntry, req *http.Request) bool {
+	for name, want := range entry.VaryHeader {
+		if strings.Join(req.Header.Values(name), ",") != strings.Join(want, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// date returns the Date of the stored response
+func (e *CacheEntry) date() time.Time {
+	if t, err := http.ParseTime(e.Header.Get("Date")); err == nil {
+		return t
+	}
+	return e.ResponseTime
+}
+
+// age is the current age of the entry, RFC 7234 section 4.2.3
+func (e *CacheEntry) age(now time.Time) time.Duration {
+	apparent := e.ResponseTime.Sub(e.date())
+	if apparent < 0 {
+		apparent = 0
+	}
+	var ageValue time.Duration
+	if n, err := strconv.ParseInt(e.Header.Get("Age"), 10, 64); err == nil && n > 0 {
+		ageValue = time.Duration(n) * time.Second
+	}
+	corrected := ageValue + e.ResponseTime.Sub(e.RequestTime)
+	if corrected < apparent {
+		corrected = apparent
+	}
+	return corrected + now.Sub(e.ResponseTime)
+}
+
+// lifetime is the freshness lifetime of the entry, RFC 7234 section 4.2.1
+func (e *CacheEntry) lifetime(shared bool) time.Duration {
+	if e.TTL > 0 {
+		return e.TTL
+	}
+	cc := parseCacheControl(e.Header)
+	if shared {
+		if v, ok := cc.seconds("s-maxage"); ok {
+			return v
+		}
+	}
+	if v, ok := cc.seconds("max-age"); ok {
+		return v
+	}
+	if exp := e.Header.Get("Expires"); exp != "" {
+		t, err := http.ParseTime(exp)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(e.date())
+	}
+	if !cacheableByDefault[e.StatusCode] {
+		return 0
+	}
+	if lm, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil {
+		// heuristic freshness, a tenth of the time since modification
+		if d := e.date().Sub(lm); d > 0 {
+			return d / 10
+		}
+	}
+	return 0
+}
+
+// freshen updates a copy of the entry with the headers of a 304
+// response, RFC 7234 section 4.3.4
+func (e *CacheEntry) freshen(resp *http.Response, reqTime, respTime time.Time) *CacheEntry {
+	f := e.clone()
+	for k, vs := range resp.Header {
+		switch k {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding", "Content-Range":
+			continue
+		}
+		f.Header[k] = append([]string(nil), vs...)
+	}
+	f.RequestTime, f.ResponseTime = reqTime, respTime
+	return f
+}
+
+// response builds a response to req from the entry
+func (e *CacheEntry) response(req *http.Request, body io.ReadCloser, age time.Duration) *http.Response {
+	header := e.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+	length := int64(-1)
+	if n, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil {
+		length = n
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode)),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: length,
+		Request:       req,
+	}
+}
+
+// gatewayTimeout answers only-if-cached requests missing the cache
+func gatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 Gateway Timeout",
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+}
+
+// cacheControl holds Cache-Control directives with lowercase names
+type cacheControl map[string]string
+
+func parseCacheControl(h http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			name, val := part, ""
+			if i := strings.IndexByte(part, '='); i >= 0 {
+				name, val = part[:i], strings.Trim(strings.TrimSpace(part[i+1:]), `"`)
+			}
+			cc[strings.ToLower(strings.TrimSpace(name))] = val
+		}
+	}
+	if len(h.Values("Cache-Control")) == 0 && strings.EqualFold(h.Get("Pragma"), "no-cache") {
+		cc["no-cache"] = ""
+	}
+	return cc
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// seconds returns a delta-seconds directive, false when absent or
+// without a valid value
+func (cc cacheControl) seconds(name string) (time.Duration, bool) {
+	v, ok := cc[name]
+	if !ok || v == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * time.Second, true
+}
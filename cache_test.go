@@ -0,0 +1,374 @@
+package netgo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type testCacheWriter struct {
+	bytes.Buffer
+	committed, aborted bool
+}
+
+func (w *testCacheWriter) Commit() error { w.committed = true; return nil }
+func (w *testCacheWriter) Abort()        { w.aborted = true }
+
+func TestTeeToCache(t *testing.T) {
+	logger := log.New(ioutil.Discard, "", 0)
+
+	w := &testCacheWriter{}
+	resp := &http.Response{Body: ioutil.NopCloser(strings.NewReader("cached body"))}
+	teeToCache(resp, w, logger)
+	var p [4]byte
+	n, _ := resp.Body.Read(p[:])
+	if w.String() != string(p[:n]) {
+		t.Fatalf("cache got %q before the caller read on", w.String())
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if w.String() != "cached body" || !w.committed || w.aborted {
+		t.Fatalf("complete read: %q committed=%v aborted=%v", w.String(), w.committed, w.aborted)
+	}
+
+	w = &testCacheWriter{}
+	resp = &http.Response{Body: ioutil.NopCloser(strings.NewReader("cached body"))}
+	teeToCache(resp, w, logger)
+	resp.Body.Read(p[:])
+	resp.Body.Close()
+	if w.committed || !w.aborted {
+		t.Fatalf("early close: committed=%v aborted=%v", w.committed, w.aborted)
+	}
+}
+
+func cachedGet(t *testing.T, c *Client, url string, opts ...RequestOption) (*http.Response, string) {
+	t.Helper()
+	resp, err := c.Get(url, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp, string(b)
+}
+
+func TestHTTPCache(t *testing.T) {
+	var hits, revalidated int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		if req.Method == "POST" {
+			return
+		}
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			revalidated++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Etag", `"v1"`)
+		w.Write([]byte("cached"))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.HTTPCache = &HTTPCache{}
+
+	if _, body := cachedGet(t, client, ts.URL); body != "cached" || hits != 1 {
+		t.Fatalf("first get: %q, %d hits", body, hits)
+	}
+	resp, body := cachedGet(t, client, ts.URL)
+	if body != "cached" || hits != 1 || resp.Header.Get("Age") == "" {
+		t.Fatalf("fresh get: %q, %d hits, age %q", body, hits, resp.Header.Get("Age"))
+	}
+	if _, body := cachedGet(t, client, ts.URL, WithHeader("Cache-Control", "no-cache")); body != "cached" || revalidated != 1 {
+		t.Fatalf("no-cache get: %q, %d revalidations", body, revalidated)
+	}
+
+	if _, err := client.Post(ts.URL, "text/plain", "x"); err != nil {
+		t.Fatal(err)
+	}
+	hits = 0
+	if _, body := cachedGet(t, client, ts.URL); body != "cached" || hits != 1 {
+		t.Fatalf("get after post: %q, %d hits", body, hits)
+	}
+}
+
+func TestHTTPCacheStale(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		version = 1
+		failing bool
+		hits    int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		hits++
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "max-age=0, stale-while-revalidate=60, stale-if-error=60")
+		fmt.Fprintf(w, "v%d", version)
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}))
+	client.HTTPCache = &HTTPCache{}
+	cachedGet(t, client, ts.URL)
+
+	mu.Lock()
+	version = 2
+	mu.Unlock()
+	if _, body := cachedGet(t, client, ts.URL); body != "v1" {
+		t.Fatalf("stale-while-revalidate served %q", body)
+	}
+	for i := 0; ; i++ {
+		mu.Lock()
+		n := hits
+		mu.Unlock()
+		client.HTTPCache.mu.Lock()
+		pending := len(client.HTTPCache.revalidating)
+		client.HTTPCache.mu.Unlock()
+		if n == 2 && pending == 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatal("no background revalidation")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	resp, body := cachedGet(t, client, ts.URL, WithHeader("Cache-Control", "no-cache"))
+	if resp.StatusCode != http.StatusOK || body != "v2" {
+		t.Fatalf("stale-if-error served %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestRevalidator(t *testing.T) {
+	var hits, notModified int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		if req.Header.Get("If-Modified-Since") == "Mon, 02 Jan 2006 15:04:05 GMT" {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Write([]byte("listing"))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.Revalidator = &Revalidator{}
+	for i := 0; i < 3; i++ {
+		if resp, body := cachedGet(t, client, ts.URL); resp.StatusCode != http.StatusOK || body != "listing" {
+			t.Fatalf("get %d: %d %q", i, resp.StatusCode, body)
+		}
+	}
+	if hits != 3 || notModified != 2 {
+		t.Fatalf("%d requests, %d not modified", hits, notModified)
+	}
+}
+
+func TestHTTPCacheKeyAndVary(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(req.Header.Get("Accept-Language")))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.HTTPCache = &HTTPCache{Key: CacheKeyIgnoring("utm_source")}
+
+	for _, lang := range []string{"en", "de", "en", "de"} {
+		if _, body := cachedGet(t, client, ts.URL+"/?utm_source="+lang, WithHeader("Accept-Language", lang)); body != lang {
+			t.Fatalf("Accept-Language %s served %q", lang, body)
+		}
+	}
+	if hits != 2 {
+		t.Fatalf("%d hits, want one per variant", hits)
+	}
+}
+
+func TestHTTPCacheCredentials(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(req.Header.Get("Authorization")))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.HTTPCache = &HTTPCache{}
+	for _, user := range []string{"alice", "bob", "alice", "bob"} {
+		if _, body := cachedGet(t, client, ts.URL, WithBearerToken(user)); body != "Bearer "+user {
+			t.Fatalf("%s served %q", user, body)
+		}
+	}
+	if _, body := cachedGet(t, client, ts.URL); body != "" {
+		t.Fatalf("anonymous request served %q", body)
+	}
+	if hits != 3 {
+		t.Fatalf("%d hits, want one per principal", hits)
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("memo"))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, bodies[i] = cachedGet(t, client, ts.URL, WithMemoize(time.Minute))
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, b := range bodies {
+		if b != "memo" {
+			t.Fatalf("bodies %q", bodies)
+		}
+	}
+	cachedGet(t, client, ts.URL, WithMemoize(time.Minute))
+	if n := atomic.LoadInt32(&hits); n != 1 {
+		t.Fatalf("%d requests, want 1", n)
+	}
+	cachedGet(t, client, ts.URL, WithMemoize(time.Nanosecond))
+	if n := atomic.LoadInt32(&hits); n != 2 {
+		t.Fatalf("%d requests after expiry, want 2", n)
+	}
+	cachedGet(t, client, ts.URL, WithMemoize(time.Minute), WithBearerToken("bob"))
+	if n := atomic.LoadInt32(&hits); n != 3 {
+		t.Fatalf("%d requests, want another one for other credentials", n)
+	}
+	// bodies over the response limit are passed on, not memoized
+	if _, err := client.Get(ts.URL+"/large", WithMemoize(time.Minute), WithMaxResponseBytes(2)); err != ErrResponseTooLarge {
+		t.Fatalf("error %v, want ErrResponseTooLarge", err)
+	}
+	cachedGet(t, client, ts.URL+"/large", WithMemoize(time.Minute))
+	if n := atomic.LoadInt32(&hits); n != 5 {
+		t.Fatalf("%d requests, want the large body fetched again", n)
+	}
+}
+
+func TestCacheStatsAndPurge(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte(req.URL.Path))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	if s := client.Cache().Stats(); s != (CacheStats{}) {
+		t.Fatalf("stats without cache: %+v", s)
+	}
+	client.HTTPCache = &HTTPCache{}
+	cachedGet(t, client, ts.URL+"/a")
+	cachedGet(t, client, ts.URL+"/a")
+	cachedGet(t, client, ts.URL+"/b")
+	if s := client.Cache().Stats(); s.Hits != 1 || s.Misses != 2 {
+		t.Fatalf("stats: %+v", s)
+	}
+
+	client.Cache().Purge(ts.URL + "/a")
+	cachedGet(t, client, ts.URL+"/a")
+	cachedGet(t, client, ts.URL+"/b")
+	if hits != 3 {
+		t.Fatalf("%d requests after purge, want 3", hits)
+	}
+	client.Cache().PurgeHost(strings.TrimPrefix(ts.URL, "http://"))
+	cachedGet(t, client, ts.URL+"/a")
+	cachedGet(t, client, ts.URL+"/b")
+	if hits != 5 {
+		t.Fatalf("%d requests after host purge, want 5", hits)
+	}
+}
+
+func TestCachePurgeCredentials(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		w.Header().Set("Cache-Control", "private, max-age=60")
+		if req.URL.Path == "/vary" {
+			w.Header().Set("Vary", "Accept")
+		}
+		w.Write([]byte(req.URL.Path))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithDefaultBearerToken("secret"))
+	client.HTTPCache = &HTTPCache{}
+	for _, path := range []string{"/a", "/vary"} {
+		cachedGet(t, client, ts.URL+path)
+		cachedGet(t, client, ts.URL+path)
+		if hits != 1 {
+			t.Fatalf("%s: %d requests, want the second cached", path, hits)
+		}
+		client.Cache().Purge(ts.URL + path)
+		cachedGet(t, client, ts.URL+path)
+		if hits != 2 {
+			t.Fatalf("%s: %d requests after purge, want 2", path, hits)
+		}
+		hits = 0
+	}
+}
+
+func TestHTTPCacheNegative(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		http.NotFound(w, req)
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.HTTPCache = &HTTPCache{NegativeTTL: time.Minute}
+	for i := 0; i < 3; i++ {
+		if resp, _ := cachedGet(t, client, ts.URL+"/missing"); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("status %d", resp.StatusCode)
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("%d requests for a cached 404", hits)
+	}
+
+	client.HTTPCache = &HTTPCache{}
+	cachedGet(t, client, ts.URL+"/missing")
+	cachedGet(t, client, ts.URL+"/missing")
+	if hits != 3 {
+		t.Fatalf("%d requests without negative caching, want 3", hits)
+	}
+}
@@ -0,0 +1,78 @@
+package netgo
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// Cache returns the HTTP cache of the client, nil when none is set. The
+// inspection methods of HTTPCache are safe to call on nil.
+func (c *Client) Cache() *HTTPCache {
+	return c.HTTPCache
+}
+
+// CacheStats counts how requests were answered by an HTTPCache
+type CacheStats struct {
+	// Hits were served fresh from the cache
+	Hits int64
+	// Misses went to the origin and got a new response
+	Misses int64
+	// Revalidated were answered from the cache after a 304
+	Revalidated int64
+	// Stale were served stale, while revalidating or on errors
+	Stale int64
+}
+
+// HitRatio is the share of requests answered from the cache
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses + s.Revalidated + s.Stale
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits+s.Revalidated+s.Stale) / float64(total)
+}
+
+// Stats returns the counters of the cache since it was created
+func (h *HTTPCache) Stats() CacheStats {
+	if h == nil {
+		return CacheStats{}
+	}
+	return CacheStats{
+		Hits:        atomic.LoadInt64(&h.hits),
+		Misses:      atomic.LoadInt64(&h.misses),
+		Revalidated: atomic.LoadInt64(&h.revalidated),
+		Stale:       atomic.LoadInt64(&h.stale),
+	}
+}
+
+// Purge removes the entries stored for GETs of rawURL, all of its Vary
+// variants and the entries of every credential included
+func (h *HTTPCache) Purge(rawURL string) error {
+	if h == nil {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	deletePartitions(h.getStore(), h.key(&http.Request{Method: "GET", URL: u, Header: http.Header{}}))
+	return nil
+}
+
+// PurgeHost removes every entry fetched from host, given with or
+// without port
+func (h *HTTPCache) PurgeHost(host string) {
+	if h == nil {
+		return
+	}
+	store := h.getStore()
+	store.Range(func(key string, entry *CacheEntry) bool {
+		u, err := url.Parse(entry.URL)
+		if err == nil && (strings.EqualFold(u.Host, host) || strings.EqualFold(u.Hostname(), host)) {
+			store.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,180 @@
+package netgo
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CacheEntry is a stored response, its body kept apart by the store
+type CacheEntry struct {
+	// URL the response was fetched from
+	URL        string
+	StatusCode int
+	Header     http.Header
+	// VaryHeader holds the request headers named by the Vary header of
+	// the response
+	VaryHeader http.Header
+	// RequestTime and ResponseTime bracket the exchange the entry came
+	// from, for age calculation
+	RequestTime  time.Time
+	ResponseTime time.Time
+	// TTL overrides the freshness lifetime of the response when positive
+	TTL time.Duration
+}
+
+func (e *CacheEntry) clone() *CacheEntry {
+	c := *e
+	c.Header = e.Header.Clone()
+	c.VaryHeader = e.VaryHeader.Clone()
+	return &c
+}
+
+// CacheStore keeps the entries of an HTTPCache. Implementations must be
+// safe for concurrent use.
+type CacheStore interface {
+	// Get returns the entry stored under key and a reader of its body
+	Get(key string) (*CacheEntry, io.ReadCloser, bool)
+	// Put starts storing entry under key; the body is written to the
+	// returned writer and the entry replaces any previous one once the
+	// writer commits
+	Put(key string, entry *CacheEntry) (CacheWriter, error)
+	// Update replaces the entry under key, keeping its body
+	Update(key string, entry *CacheEntry) error
+	// Delete removes the entry under key
+	Delete(key string)
+	// Range calls fn for every entry until it returns false; fn may
+	// delete entries
+	Range(fn func(key string, entry *CacheEntry) bool)
+}
+
+// errCacheEntryTooLarge aborts storing a body over the store limit
+var errCacheEntryTooLarge = errors.New("netter: cache entry too large")
+
+// MemoryCacheStore keeps entries in memory and evicts the least recently
+// used ones beyond MaxBytes of bodies
+type MemoryCacheStore struct {
+	// MaxBytes bounds the total size of stored bodies, 64MB by default
+	MaxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List
+	size    int64
+}
+
+type memoryEntry struct {
+	key   string
+	entry *CacheEntry
+	body  []byte
+}
+
+func (s *MemoryCacheStore) maxBytes() int64 {
+	if s.MaxBytes <= 0 {
+		return 64 << 20
+	}
+	return s.MaxBytes
+}
+
+// Get implements CacheStore
+func (s *MemoryCacheStore) Get(key string) (*CacheEntry, io.ReadCloser, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, nil, false
+	}
+	s.lru.MoveToFront(el)
+	me := el.Value.(*memoryEntry)
+	return me.entry.clone(), ioutil.NopCloser(bytes.NewReader(me.body)), true
+}
+
+// Put implements CacheStore
+func (s *MemoryCacheStore) Put(key string, entry *CacheEntry) (CacheWriter, error) {
+	return &memoryWriter{s: s, key: key, entry: entry.clone()}, nil
+}
+
+// Update implements CacheStore
+func (s *MemoryCacheStore) Update(key string, entry *CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		el.Value.(*memoryEntry).entry = entry.clone()
+	}
+	return nil
+}
+
+// Delete implements CacheStore
+func (s *MemoryCacheStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+}
+
+// Range implements CacheStore
+func (s *MemoryCacheStore) Range(fn func(key string, entry *CacheEntry) bool) {
+	s.mu.Lock()
+	entries := make([]memoryEntry, 0, len(s.entries))
+	for el := s.lru.Front(); el != nil; el = el.Next() {
+		me := el.Value.(*memoryEntry)
+		entries = append(entries, memoryEntry{key: me.key, entry: me.entry.clone()})
+	}
+	s.mu.Unlock()
+	for _, me := range entries {
+		if !fn(me.key, me.entry) {
+			return
+		}
+	}
+}
+
+func (s *MemoryCacheStore) remove(el *list.Element) {
+	me := s.lru.Remove(el).(*memoryEntry)
+	delete(s.entries, me.key)
+	s.size -= int64(len(me.body))
+}
+
+func (s *MemoryCacheStore) commit(me *memoryEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries == nil {
+		s.entries = make(map[string]*list.Element)
+	}
+	if el, ok := s.entries[me.key]; ok {
+		s.remove(el)
+	}
+	s.entries[me.key] = s.lru.PushFront(me)
+	s.size += int64(len(me.body))
+	for s.size > s.maxBytes() {
+		s.remove(s.lru.Back())
+	}
+}
+
+type memoryWriter struct {
+	s     *MemoryCacheStore
+	key   string
+	entry *CacheEntry
+	buf   bytes.Buffer
+}
+
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	if int64(w.buf.Len()+len(p)) > w.s.maxBytes() {
+		return 0, errCacheEntryTooLarge
+	}
+	return w.buf.Write(p)
+}
+
+func (w *memoryWriter) Commit() error {
+	w.s.commit(&memoryEntry{key: w.key, entry: w.entry, body: w.buf.Bytes()})
+	return nil
+}
+
+func (w *memoryWriter) Abort() {
+	w.buf = bytes.Buffer{}
+}
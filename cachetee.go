@@ -0,0 +1,67 @@
+package netgo
+
+import (
+	"io"
+	"net/http"
+)
+
+// CacheWriter receives a response body streamed into a cache store.
+// Commit makes the entry visible once the caller read the body to the
+// end; Abort discards it when the body was cut off or closed early.
+type CacheWriter interface {
+	io.Writer
+	Commit() error
+	Abort()
+}
+
+// teeToCache copies the body of resp into w while the caller reads it,
+// so the response is neither buffered nor delayed on its way into the
+// cache. Errors of w only stop the copy, never the caller's read.
+func teeToCache(resp *http.Response, w CacheWriter, logger Logger) {
+	resp.Body = &teeBody{ReadCloser: resp.Body, w: w, logger: logger}
+}
+
+type teeBody struct {
+	io.ReadCloser
+	w      CacheWriter
+	logger Logger
+	done   bool
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.done {
+		return n, err
+	}
+	if n > 0 {
+		if _, werr := b.w.Write(p[:n]); werr != nil {
+			b.logger.Printf("netter: cache write: %v", werr)
+			b.finish(false)
+			return n, err
+		}
+	}
+	if err == io.EOF {
+		b.finish(true)
+	} else if err != nil {
+		b.finish(false)
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	if !b.done {
+		b.finish(false)
+	}
+	return b.ReadCloser.Close()
+}
+
+func (b *teeBody) finish(complete bool) {
+	b.done = true
+	if !complete {
+		b.w.Abort()
+		return
+	}
+	if err := b.w.Commit(); err != nil {
+		b.logger.Printf("netter: cache commit: %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package netgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"sync"
+	"time"
+)
+
+// captureMagic opens every capture file
+const captureMagic = "NETGOCAP1\n"
+
+// Direction of a captured record
+const (
+	CaptureRequest  byte = '>'
+	CaptureResponse byte = '<'
+)
+
+// Capture writes raw plaintext request and response bytes of every attempt
+// made by a client, so framing and encoding issues can be inspected later.
+// Header values and URL parts masked by the client's Redaction are
+// replaced.
+//
+// Responses are copied while the caller reads the body and recorded once
+// it is read to the end or closed; a body cut short is recorded with the
+// Content-Length of the bytes read.
+//
+// Record layout: direction (1 byte), unix nano timestamp (8 bytes),
+// payload length (4 bytes), payload. Integers are big endian.
+type Capture struct {
+	// MaxBody bounds the response body bytes kept per record, 1 MiB by
+	// default
+	MaxBody int
+
+	mu     sync.Mutex
+	w      io.Writer
+	header bool
+}
+
+// NewCapture represents new capture writing into w
+func NewCapture(w io.Writer) *Capture {
+	return &Capture{w: w}
+}
+
+// CreateCapture creates or truncates the named file and captures into it.
+// The caller closes the returned file when done.
+func CreateCapture(name string) (*Capture, *os.File, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewCapture(f), f, nil
+}
+
+func (c *Capture) write(dir byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.header {
+		if _, err := io.WriteString(c.w, captureMagic); err != nil {
+			return err
+		}
+		c.header = true
+	}
+
+	var hdr [13]byte
+	hdr[0] = dir
+	binary.BigEndian.PutUint64(hdr[1:9], uint64(time.Now().UnixNano()))
+	binary.BigEndian.PutUint32(hdr[9:13], uint32(len(payload)))
+	if _, err := c.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := c.w.Write(payload)
+	return err
+}
+
+func (c *Capture) request(req *http.Request, r *Redaction) error {
+	masked := r.request(req)
+	dump, err := httputil.DumpRequestOut(masked, true)
+	// the dump replaced the consumed body of the copy
+	req.Body = masked.Body
+	if err != nil {
+		return err
+	}
+	return c.write(CaptureRequest, dump)
+}
+
+// response records resp once its body is done with
+func (c *Capture) response(resp *http.Response, r *Redaction, logger Logger) {
+	masked := *resp
+	masked.Header = r.Header(resp.Header)
+	if resp.Body == nil || resp.Body == http.NoBody {
+		c.writeResponse(&masked, logger)
+		return
+	}
+	max := c.MaxBody
+	if max <= 0 {
+		max = 1 << 20
+	}
+	resp.Body = &captureBody{ReadCloser: resp.Body, c: c, resp: &masked, max: max, logger: logger}
+}
+
+func (c *Capture) writeResponse(resp *http.Response, logger Logger) {
+	dump, err := httputil.DumpResponse(resp, true)
+	if err == nil {
+		err = c.write(CaptureResponse, dump)
+	}
+	if err != nil {
+		logger.Printf("netter: capturing response: %v", err)
+	}
+}
+
+// captureBody copies the body into the capture while the caller reads it
+type captureBody struct {
+	io.ReadCloser
+	c      *Capture
+	resp   *http.Response
+	max    int
+	logger Logger
+
+	buf             bytes.Buffer
+	truncated, done bool
+}
+
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.done {
+		return n, err
+	}
+	if keep := min(n, b.max-b.buf.Len()); keep < n {
+		b.buf.Write(p[:keep])
+		b.truncated = true
+	} else {
+		b.buf.Write(p[:n])
+	}
+	if err != nil {
+		b.finish(err == io.EOF)
+	}
+	return n, err
+}
+
+func (b *captureBody) Close() error {
+	if !b.done {
+		b.finish(false)
+	}
+	return b.ReadCloser.Close()
+}
+
+func (b *captureBody) finish(complete bool) {
+	b.done = true
+	if !complete || b.truncated {
+		b.resp.ContentLength = int64(b.buf.Len())
+		b.resp.TransferEncoding = nil
+	}
+	b.resp.Body = ioutil.NopCloser(&b.buf)
+	b.c.writeResponse(b.resp, b.logger)
+}
+
+// CaptureRecord represents single captured message
+type CaptureRecord struct {
+	Direction byte
+	Time      time.Time
+	Payload   []byte
+}
+
+// Request parses a captured request record
+func (r *CaptureRecord) Request() (*http.Request, error) {
+	if r.Direction != CaptureRequest {
+		return nil, errors.New("netter: capture record is not a request")
+	}
+	return http.ReadRequest(bufio.NewReader(bytes.NewReader(r.Payload)))
+}
+
+// Response parses a captured response record
+func (r *CaptureRecord) Response() (*http.Response, error) {
+	if r.Direction != CaptureResponse {
+		return nil, errors.New("netter: capture record is not a response")
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(r.Payload)), nil)
+}
+
+// CaptureReader replays records written by Capture
+type CaptureReader struct {
+	r      *bufio.Reader
+	header bool
+}
+
+// NewCaptureReader represents new capture reader
+func NewCaptureReader(r io.Reader) *CaptureReader {
+	return &CaptureReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the next record or io.EOF when the capture is exhausted
+func (cr *CaptureReader) Next() (*CaptureRecord, error) {
+	if !cr.header {
+		magic := make([]byte, len(captureMagic))
+		if _, err := io.ReadFull(cr.r, magic); err != nil {
+			return nil, err
+		}
+		if string(magic) != captureMagic {
+			return nil, errors.New("netter: not a capture file")
+		}
+		cr.header = true
+	}
+
+	var hdr [13]byte
+	if _, err := io.ReadFull(cr.r, hdr[:]); err != nil {
+		return nil, err
+	}
+	switch hdr[0] {
+	case CaptureRequest, CaptureResponse:
+	default:
+		return nil, fmt.Errorf("netter: bad capture direction %q", hdr[0])
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(hdr[9:13]))
+	if _, err := io.ReadFull(cr.r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return &CaptureRecord{
+		Direction: hdr[0],
+		Time:      time.Unix(0, int64(binary.BigEndian.Uint64(hdr[1:9]))),
+		Payload:   payload,
+	}, nil
+}
@@ -0,0 +1,125 @@
+package netgo
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		w.Header().Set("X-Echo", string(body))
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger, Capture: NewCapture(&buf)}
+
+	resp, err := client.Post(ts.URL, "text/plain", strings.NewReader("ping"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if string(body) != "pong" {
+		t.Fatalf("bad body after capture: %q", body)
+	}
+
+	cr := NewCaptureReader(&buf)
+	rec, err := cr.Next()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req, err := rec.Request()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if b, _ := ioutil.ReadAll(req.Body); req.Method != "POST" || string(b) != "ping" {
+		t.Fatalf("bad captured request: %s %q", req.Method, b)
+	}
+
+	rec, err = cr.Next()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	res, err := rec.Response()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if res.Header.Get("X-Echo") != "ping" {
+		t.Fatalf("bad captured response headers: %v", res.Header)
+	}
+
+	if _, err := cr.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestCaptureStream(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "{\"n\":1}\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "{\"n\":2}\n")
+	}))
+	defer ts.Close()
+
+	var buf bytes.Buffer
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}))
+	client.Capture = NewCapture(&buf)
+
+	// the response is handed over before the stream ends
+	resp, err := client.Get(ts.URL)
+	close(release)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	cr := NewCaptureReader(&buf)
+	cr.Next()
+	rec, err := cr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := rec.Response()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(res.Body); string(b) != string(body) || len(res.TransferEncoding) == 0 {
+		t.Fatalf("captured %q %v, want %q chunked", b, res.TransferEncoding, body)
+	}
+
+	// bodies beyond MaxBody are cut in the capture only
+	buf.Reset()
+	client.Capture = NewCapture(&buf)
+	client.Capture.MaxBody = 4
+	resp, err = client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "{\"n\":1}\n{\"n\":2}\n" {
+		t.Fatalf("body %q", body)
+	}
+	cr = NewCaptureReader(&buf)
+	cr.Next()
+	if rec, err = cr.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := rec.Response(); err != nil || res.ContentLength != 4 {
+		t.Fatalf("truncated capture: %v %v", res, err)
+	}
+}
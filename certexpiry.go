@@ -0,0 +1,96 @@
+package netgo
+
+import (
+	"crypto/tls"
+	"sync"
+	"time"
+)
+
+// CertExpiryMonitor records the expiry of the leaf certificates servers
+// present and warns through the client logger once one is about to
+// expire, so renewals missed by partners show up before handshakes fail
+type CertExpiryMonitor struct {
+	// Within is the warning horizon, 30 days by default
+	Within time.Duration
+	// Repeat is the quiet period between warnings per server, a day by
+	// default
+	Repeat time.Duration
+	// OnExpiring is called with every warning, e.g. to feed metrics
+	OnExpiring func(server string, notAfter time.Time)
+
+	mu     sync.Mutex
+	expiry map[string]time.Time
+	warned map[string]time.Time
+}
+
+// WithCertExpiryMonitor checks the server certificates of every
+// handshake of the client with m, warning through the logger the client
+// has once all options are applied
+func WithCertExpiryMonitor(m *CertExpiryMonitor) Option {
+	return func(c *Client) {
+		logger := c.log()
+		c.afterOptions(func(c *Client) { logger = c.log() })
+		withTLSConfig(func(cfg *tls.Config) {
+			verify := cfg.VerifyConnection
+			cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+				if verify != nil {
+					if err := verify(cs); err != nil {
+						return err
+					}
+				}
+				m.record(logger, cs, time.Now())
+				return nil
+			}
+		})(c)
+	}
+}
+
+// Expiries returns the NotAfter of the last certificate seen per server
+func (m *CertExpiryMonitor) Expiries() map[string]time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]time.Time, len(m.expiry))
+	for server, t := range m.expiry {
+		out[server] = t
+	}
+	return out
+}
+
+func (m *CertExpiryMonitor) record(logger Logger, cs tls.ConnectionState, now time.Time) {
+	if len(cs.PeerCertificates) == 0 {
+		return
+	}
+	leaf := cs.PeerCertificates[0]
+	server := cs.ServerName
+	if server == "" {
+		server = leaf.Subject.CommonName
+	}
+	within := m.Within
+	if within <= 0 {
+		within = 30 * 24 * time.Hour
+	}
+	repeat := m.Repeat
+	if repeat <= 0 {
+		repeat = 24 * time.Hour
+	}
+
+	m.mu.Lock()
+	if m.expiry == nil {
+		m.expiry = make(map[string]time.Time)
+		m.warned = make(map[string]time.Time)
+	}
+	m.expiry[server] = leaf.NotAfter
+	left := leaf.NotAfter.Sub(now)
+	warn := left < within && now.Sub(m.warned[server]) >= repeat
+	if warn {
+		m.warned[server] = now
+	}
+	m.mu.Unlock()
+	if !warn {
+		return
+	}
+	logger.Printf("netter: certificate of %s expires at %s, in %s", server, leaf.NotAfter.Format(time.RFC3339), left.Round(time.Minute))
+	if m.OnExpiring != nil {
+		m.OnExpiring(server, leaf.NotAfter)
+	}
+}
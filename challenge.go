@@ -0,0 +1,284 @@
+package netgo
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Challenge is one parsed WWW-Authenticate or Proxy-Authenticate challenge
+type Challenge struct {
+	// Scheme in canonical case, e.g. "Basic" or "Digest"
+	Scheme string
+	// Params holds auth-params with lowercase names
+	Params map[string]string
+}
+
+// Realm returns the realm parameter
+func (c *Challenge) Realm() string {
+	return c.Params["realm"]
+}
+
+// ParseChallenges parses the challenges of a WWW-Authenticate or
+// Proxy-Authenticate header value
+func ParseChallenges(header string) []Challenge {
+	var (
+		out []Challenge
+		cur *Challenge
+		s   = header
+	)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return out
+		}
+		tok, rest := splitToken(s)
+		if tok == "" {
+			s = s[1:]
+			continue
+		}
+		rest = strings.TrimLeft(rest, " \t")
+		if cur != nil && strings.HasPrefix(rest, "=") {
+			var val string
+			val, s = parseParamValue(strings.TrimLeft(rest[1:], " \t"))
+			cur.Params[strings.ToLower(tok)] = val
+			continue
+		}
+		out = append(out, Challenge{Scheme: canonicalScheme(tok), Params: make(map[string]string)})
+		cur = &out[len(out)-1]
+		s = rest
+		if t, after, ok := splitToken68(s); ok {
+			// stored under the empty name, e.g. "Negotiate abc123=="
+			cur.Params[""] = t
+			s = after
+		}
+	}
+}
+
+func canonicalScheme(s string) string {
+	switch strings.ToLower(s) {
+	case "basic":
+		return "Basic"
+	case "digest":
+		return "Digest"
+	case "bearer":
+		return "Bearer"
+	case "negotiate":
+		return "Negotiate"
+	}
+	return s
+}
+
+func isTokenChar(c byte) bool {
+	return c > ' ' && c < 0x7f && !strings.ContainsRune("()<>@,;:\\\"/[]?={}", rune(c))
+}
+
+func splitToken(s string) (string, string) {
+	i := 0
+	for i < len(s) && isTokenChar(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+// splitToken68 consumes a token68 credential ending a challenge, as
+// opposed to the first auth-param name
+func splitToken68(s string) (string, string, bool) {
+	i := 0
+	for i < len(s) && (isTokenChar(s[i]) || s[i] == '/') {
+		i++
+	}
+	if i == 0 {
+		return "", s, false
+	}
+	for i < len(s) && s[i] == '=' {
+		i++
+	}
+	rest := strings.TrimLeft(s[i:], " \t")
+	if rest != "" && rest[0] != ',' {
+		return "", s, false
+	}
+	return s[:i], rest, true
+}
+
+func parseParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		tok, rest := splitToken(s)
+		return tok, rest
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
+
+// Authenticator answers challenges of one scheme
+type Authenticator interface {
+	// Scheme handled, compared case-insensitively
+	Scheme() string
+	// Authorize returns the credentials header value answering ch for
+	// req. nc counts how often the cached challenge has been answered,
+	// starting at 1, as needed for digest nonce counts.
+	Authorize(req *http.Request, ch *Challenge, nc uint32) (string, error)
+}
+
+// BasicAuthenticator answers Basic challenges with credentials looked up
+// by host and realm
+type BasicAuthenticator struct {
+	Credentials func(host, realm string) (user, pass string, ok bool)
+}
+
+// Scheme implements Authenticator
+func (BasicAuthenticator) Scheme() string { return "Basic" }
+
+// Authorize implements Authenticator
+func (a BasicAuthenticator) Authorize(req *http.Request, ch *Challenge, nc uint32) (string, error) {
+	user, pass, ok := a.Credentials(req.URL.Host, ch.Realm())
+	if !ok {
+		return "", errNoCredentials
+	}
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass)), nil
+}
+
+var errNoCredentials = errors.New("netter: no credentials for challenge")
+
+// ChallengeAuth answers 401 and 407 challenges with its authenticators
+// and caches the resolved challenge per host, so later requests to that
+// host are authorized pre-emptively without an extra round trip.
+type ChallengeAuth struct {
+	Authenticators []Authenticator
+
+	mu    sync.Mutex
+	cache map[string]*cachedChallenge
+}
+
+type cachedChallenge struct {
+	auth Authenticator
+	ch   Challenge
+	nc   uint32
+}
+
+func challengeKey(req *http.Request, proxy bool) string {
+	if proxy {
+		return "proxy " + req.URL.Host
+	}
+	return req.URL.Host
+}
+
+func (a *ChallengeAuth) authenticator(scheme string) Authenticator {
+	for _, auth := range a.Authenticators {
+		if strings.EqualFold(auth.Scheme(), scheme) {
+			return auth
+		}
+	}
+	return nil
+}
+
+// preempt sets cached credentials on req
+func (a *ChallengeAuth) preempt(req *http.Request) {
+	for _, proxy := range []bool{false, true} {
+		a.mu.Lock()
+		cc, ok := a.cache[challengeKey(req, proxy)]
+		var nc uint32
+		if ok {
+			cc.nc++
+			nc = cc.nc
+		}
+		a.mu.Unlock()
+		if !ok {
+			continue
+		}
+		value, err := cc.auth.Authorize(req, &cc.ch, nc)
+		if err != nil {
+			continue
+		}
+		req.Header.Set(authHeader(proxy), value)
+	}
+}
+
+// answer resolves the challenge of a 401 or 407 response; it reports
+// false when no authenticator could answer it
+func (a *ChallengeAuth) answer(req *http.Request, resp *http.Response) bool {
+	proxy := resp.StatusCode == http.StatusProxyAuthRequired
+	header := "Www-Authenticate"
+	if proxy {
+		header = "Proxy-Authenticate"
+	}
+	for _, v := range resp.Header.Values(header) {
+		for _, ch := range ParseChallenges(v) {
+			auth := a.authenticator(ch.Scheme)
+			if auth == nil {
+				continue
+			}
+			ch := ch
+			value, err := auth.Authorize(req, &ch, 1)
+			if err != nil {
+				continue
+			}
+			a.mu.Lock()
+			if a.cache == nil {
+				a.cache = make(map[string]*cachedChallenge)
+			}
+			a.cache[challengeKey(req, proxy)] = &cachedChallenge{auth: auth, ch: ch, nc: 1}
+			a.mu.Unlock()
+			req.Header.Set(authHeader(proxy), value)
+			return true
+		}
+	}
+	return false
+}
+
+// roundTrip sends req through send, answering one challenge if the
+// response asks for authentication
+func (a *ChallengeAuth) roundTrip(c *Client, r *Request, req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	a.preempt(req)
+	resp, err := send(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusProxyAuthRequired {
+		return resp, nil
+	}
+	proxy := resp.StatusCode == http.StatusProxyAuthRequired
+	if !a.answer(req, resp) {
+		a.forget(req, proxy)
+		return resp, nil
+	}
+
+	if r.body != nil {
+		body, err := r.body()
+		if err != nil {
+			return resp, nil
+		}
+		req.Body = toReadCloser(body)
+	}
+	c.drainBody(resp.Body)
+	return send(req)
+}
+
+// forget drops the cached challenge after it was rejected
+func (a *ChallengeAuth) forget(req *http.Request, proxy bool) {
+	a.mu.Lock()
+	delete(a.cache, challengeKey(req, proxy))
+	a.mu.Unlock()
+}
+
+func authHeader(proxy bool) string {
+	if proxy {
+		return "Proxy-Authorization"
+	}
+	return "Authorization"
+}
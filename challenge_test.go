@@ -0,0 +1,131 @@
+package netgo
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseChallenges(t *testing.T) {
+	got := ParseChallenges(`Digest realm="api, v1", nonce="abc", qop="auth,auth-int", Basic realm=simple, Negotiate dG9rZW4=`)
+	want := []Challenge{
+		{Scheme: "Digest", Params: map[string]string{"realm": "api, v1", "nonce": "abc", "qop": "auth,auth-int"}},
+		{Scheme: "Basic", Params: map[string]string{"realm": "simple"}},
+		{Scheme: "Negotiate", Params: map[string]string{"": "dG9rZW4="}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("bad challenges:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestChallengeAuthCaches(t *testing.T) {
+	var hits, challenged int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		if user, pass, ok := req.BasicAuth(); !ok || user != "u" || pass != "p" {
+			challenged++
+			w.Header().Set("WWW-Authenticate", `Basic realm="r"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger}
+	client.Auth = &ChallengeAuth{Authenticators: []Authenticator{BasicAuthenticator{
+		Credentials: func(host, realm string) (string, string, bool) { return "u", "p", realm == "r" },
+	}}}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("bad status: %d", resp.StatusCode)
+		}
+	}
+	if hits != 4 || challenged != 1 {
+		t.Fatalf("challenge not cached: %d hits, %d challenges", hits, challenged)
+	}
+}
+
+func TestDigestAuthenticator(t *testing.T) {
+	var (
+		challenged int
+		counts     []string
+	)
+	md5hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		chs := ParseChallenges(req.Header.Get("Authorization"))
+		if len(chs) == 1 && chs[0].Scheme == "Digest" {
+			p := chs[0].Params
+			ha1 := md5hex("u:r:p")
+			ha2 := md5hex(req.Method + ":" + p["uri"])
+			want := md5hex(ha1 + ":n1:" + p["nc"] + ":" + p["cnonce"] + ":auth:" + ha2)
+			if p["response"] == want && p["uri"] == req.URL.RequestURI() && p["opaque"] == "o" {
+				counts = append(counts, p["nc"])
+				return
+			}
+		}
+		challenged++
+		w.Header().Set("WWW-Authenticate", `Digest realm="r", nonce="n1", qop="auth", opaque="o"`)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.Auth = &ChallengeAuth{Authenticators: []Authenticator{DigestAuthenticator{
+		Credentials: func(host, realm string) (string, string, bool) { return "u", "p", realm == "r" },
+	}}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL + "/dir/index.html?x=1")
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("get %d: %v %v", i, resp, err)
+		}
+		resp.Body.Close()
+	}
+	if challenged != 1 || !reflect.DeepEqual(counts, []string{"00000001", "00000002"}) {
+		t.Fatalf("%d challenges, nonce counts %q", challenged, counts)
+	}
+}
+
+type fakeNegotiate struct{ spns []string }
+
+func (f *fakeNegotiate) Token(req *http.Request, spn string, input []byte) ([]byte, error) {
+	f.spns = append(f.spns, spn)
+	return []byte("ticket-for-" + spn), nil
+}
+
+func TestNegotiateAuthenticator(t *testing.T) {
+	var challenged int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Negotiate "+base64.StdEncoding.EncodeToString([]byte("ticket-for-HTTP/127.0.0.1")) {
+			challenged++
+			w.Header().Set("WWW-Authenticate", "Negotiate")
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	p := &fakeNegotiate{}
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.Auth = &ChallengeAuth{Authenticators: []Authenticator{NegotiateAuthenticator{Provider: p}}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("get %d: %v %v", i, resp, err)
+		}
+		resp.Body.Close()
+	}
+	if challenged != 1 || len(p.spns) != 2 {
+		t.Fatalf("%d challenges, tokens for %q", challenged, p.spns)
+	}
+}
@@ -1,12 +1,16 @@
 package netgo
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,128 +23,563 @@ type Client struct {
 	Inner *http.Client
 	Logger
 	Retry
+	// BaseURL is prepended to requests created with a relative URL
+	BaseURL string
+	// Headers are set on every request lacking them
+	Headers http.Header
+	// UserAgent is sent unless the request sets its own
+	UserAgent string
+	// URLPolicy rejects malformed or disallowed URLs before dialing
+	URLPolicy *URLPolicy
+	// AllowedHosts, when set, restricts requests to the listed hosts and
+	// DeniedHosts refuses the listed ones. Entries are exact names,
+	// wildcards like "*.example.com" or CIDRs matched against the
+	// addresses dialed, the proxy's behind a proxy. Redirects are checked
+	// too.
+	AllowedHosts []string
+	DeniedHosts  []string
+	// MaxResponseBytes limits response bodies; reading past it fails with
+	// ErrResponseTooLarge. Zero means no limit.
+	MaxResponseBytes int64
+	// Decompress advertises and decodes the registered content
+	// encodings, see RegisterContentDecoder
+	Decompress bool
+	// Bandwidth caps the streaming rate of request and response bodies
+	// across all requests
+	Bandwidth *Bandwidth
+	// Clock drives waits between retries, the real clock by default
+	Clock Clock
+	// Capture records raw bytes of every attempt when set
+	Capture *Capture
+	// Hedge enables latency-based backup requests when set
+	Hedge *Hedge
+	// DeadlineTimeouts derives per-phase timeouts from the context deadline
+	DeadlineTimeouts *DeadlineTimeouts
+	// DeadlineHeader propagates the remaining deadline to upstreams
+	DeadlineHeader *DeadlineHeader
+	// CrawlDelay spaces out successive requests to the same host
+	CrawlDelay *CrawlDelay
+	// RateLimit delays requests to hosts announcing an exhausted quota
+	RateLimit *RateLimitThrottle
+	// TokenSource supplies the access token of every request; a 401
+	// drops the token and retries once with a fresh one
+	TokenSource TokenSource
+	// OnUnauthorized is called once per request answered with 401 to
+	// refresh credentials; the request is retried unless it fails
+	OnUnauthorized func(ctx context.Context, req *Request) error
+	// Auth answers 401/407 challenges and caches them per host
+	Auth *ChallengeAuth
+	// Mirror copies a fraction of requests to a secondary backend
+	Mirror *Mirror
+	// Journal records every attempt for usage reconciliation
+	Journal *Journal
+	// LoadShed rejects requests locally while a host keeps failing
+	LoadShed *LoadShed
+	// ProxyFunc picks the proxy of each attempt; a nil URL leaves the
+	// choice to ProxyPool or the transport. WithProxy takes precedence.
+	ProxyFunc func(req *Request) (*url.URL, error)
+	// ProxyPool spreads requests across proxies when set
+	ProxyPool *ProxyPool
+	// FailureAlert calls back once an endpoint keeps failing
+	FailureAlert *FailureAlert
+	// Faults injects delays and failures for resilience testing
+	Faults *FaultInjector
+	// CheckRetry decides whether an attempt is retried, replacing the
+	// default policy of Retry
+	CheckRetry func(ctx context.Context, resp *http.Response, err error) (bool, error)
+	// OnRetry is notified of every attempt about to be retried
+	OnRetry func(req *Request, resp *http.Response, err error, attempt int)
+	// PrepareRetry may modify the request before every retried attempt
+	PrepareRetry func(req *Request) error
+	// AttemptMiddleware wraps every attempt, RetryMiddleware the whole
+	// retry loop; see Use
+	AttemptMiddleware []Middleware
+	RetryMiddleware   []Middleware
+	// Redaction masks secrets in log lines and captures,
+	// DefaultRedaction when nil
+	Redaction *Redaction
+	// HTTPCache serves GET requests from an RFC 7234 cache when set
+	HTTPCache *HTTPCache
+	// Revalidator makes repeated GETs conditional without a full cache
+	Revalidator *Revalidator
+	// Fallback is invoked with the final error once the request failed,
+	// e.g. to serve a stale cached response or a synthesized default
+	Fallback func(req *Request, err error) (*http.Response, error)
+
+	autoClose func(error) bool
+
+	life *lifecycle
+	sni  *sniClients
+	memo *memoizer
+
+	tokens *tokenCache
+
+	// authorization is the default Authorization header value, see
+	// WithDefaultBearerToken
+	authorization string
+	apiKey        *apiKey
+	// dialer is installed on the transport by dial options
+	dialer *dialer
+	// err is the first error of an option, failing every request
+	err error
+	// secrets are masked in log lines
+	secrets []string
+	// pending run once every option is applied, see afterOptions
+	pending []func(*Client)
+}
+
+// stateMu guards lazy initialization of the unexported client state
+var stateMu sync.Mutex
+
+// NewClient represents new http client with its own transport.
+// Options are applied in order on top of the defaults.
+func NewClient(opts ...Option) *Client {
+	c := newDefaultClient()
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.applied()
+	return c
 }
 
-// NewClient represents new http client
-func NewClient() *Client {
-	return defaultClient
+var defaultClient = newDefaultClient()
+
+// newDefaultClient returns a fresh client with default settings and its
+// own copy of defaultTransport
+func newDefaultClient() *Client {
+	return &Client{
+		Inner: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: defaultTransport.Clone(),
+		},
+		Logger: log.New(os.Stderr, "", log.LstdFlags),
+		Retry: Retry{
+			WaitMin: 2 * time.Second,
+			WaitMax: 8 * time.Second,
+			Max:     4,
+		},
+	}
 }
 
-var defaultClient = &Client{
-	Inner: &http.Client{
-		Timeout:   30 * time.Second,
-		Transport: defaultTransport,
-	},
-	Logger: log.New(os.Stderr, "", log.LstdFlags),
-	Retry: Retry{
-		WaitMin: 2 * time.Second,
-		WaitMax: 8 * time.Second,
-		Max:     4,
-	},
+// Do sends an HTTP request and returns an HTTP response. Options
+// modify req before the first attempt.
+func (c *Client) Do(req *Request, opts ...RequestOption) (*http.Response, error) {
+	life := c.lifecycle()
+	if !life.enter() {
+		return nil, ErrClientClosed
+	}
+	defer life.leave()
+
+	// a reused request may refresh its credentials again
+	req.reauthorized = false
+	release := req.applyOptions(opts)
+	err := req.err
+	if err == nil {
+		err = c.err
+	}
+	if err == nil {
+		err = req.expandPath()
+	}
+	c.applyHeaders(req)
+	if c.Decompress {
+		advertiseEncodings(req)
+	}
+	if err == nil {
+		err = c.authorize(req, req.Request)
+	}
+	if err == nil {
+		err = c.resolveURL(req)
+	}
+	if err == nil {
+		err = c.checkURL(req.Context(), req.URL)
+	}
+	if err != nil {
+		release(nil, err)
+		return nil, err
+	}
+	if ctx := c.withHostPolicy(req.Context()); ctx != req.Context() {
+		req.Request = req.WithContext(ctx)
+	}
+	c.throttleUpload(req)
+	req.progress.watchUpload(req)
+
+	var resp *http.Response
+	req.credentials = c.credentials(req)
+	retryMiddleware := c.RetryMiddleware
+	if req.memoize > 0 {
+		retryMiddleware = append(retryMiddleware[:len(retryMiddleware):len(retryMiddleware)], c.memoizer().middleware(c, req, req.memoize))
+	}
+	if c.HTTPCache != nil {
+		retryMiddleware = append(retryMiddleware[:len(retryMiddleware):len(retryMiddleware)], c.HTTPCache.middleware(c, req.credentials))
+	}
+	if c.Revalidator != nil {
+		retryMiddleware = append(retryMiddleware[:len(retryMiddleware):len(retryMiddleware)], c.Revalidator.middleware(c, req.credentials))
+	}
+	if len(retryMiddleware) > 0 {
+		loop := func(hr *http.Request) (*http.Response, error) {
+			r := *req
+			r.Request = hr
+			return c.do(&r)
+		}
+		resp, err = chain(retryMiddleware, loop)(req.Request)
+	} else {
+		resp, err = c.do(req)
+	}
+	if err != nil && c.Fallback != nil && req.Context().Err() == nil {
+		resp, err = c.fallback(req, err)
+	}
+	if err == nil && req.upgrade && resp.StatusCode == http.StatusSwitchingProtocols {
+		// the body is the upgraded connection, not to be wrapped
+		release(nil, nil)
+		return resp, nil
+	}
+	if err == nil && c.Decompress {
+		if err = decodeContent(resp); err != nil {
+			resp = nil
+		}
+	}
+	if err == nil {
+		if err = limitResponse(resp, c.maxResponseBytes(req)); err != nil {
+			resp = nil
+		}
+	}
+	if err == nil {
+		c.throttleDownload(req, resp)
+		req.progress.watchDownload(resp)
+	}
+	if err == nil && req.checkStatus {
+		err = checkStatus(resp)
+	}
+	if err == nil && len(req.checks) > 0 {
+		err = runChecks(resp, req.checks)
+	}
+	c.closeOnError(resp, err)
+	release(resp, err)
+	if resp != nil && resp.Body != nil && resp.Body != http.NoBody {
+		resp = trackBody(c, req, resp)
+	}
+	return resp, err
 }
 
-// Do sends an HTTP request and returns an HTTP response
-func (c *Client) Do(req *Request) (resp *http.Response, err error) {
+// do runs the retry loop
+func (c *Client) do(req *Request) (resp *http.Response, err error) {
+
+	if c.LoadShed != nil && c.LoadShed.shed(req.URL.Host) {
+		return nil, ErrLoadShed
+	}
+
+	if c.Mirror != nil && !req.upgrade {
+		c.mirror(req)
+	}
+
+	policy := c.retryPolicy(req)
+	logger := c.logger(req)
 
 	for i := 0; ; i++ {
 
 		var code int
 
+		if c.CrawlDelay != nil {
+			if err := c.CrawlDelay.wait(req.Context(), c, req.URL); err != nil {
+				return nil, err
+			}
+		}
+
+		if c.RateLimit != nil {
+			if err := c.RateLimit.wait(req.Context(), c, req.URL.Host); err != nil {
+				return nil, err
+			}
+		}
+
 		if req.body != nil {
 			body, err := req.body()
 			if err != nil {
 				return resp, err
 			}
-			if c, ok := body.(io.ReadCloser); ok {
-				req.Body = c
-			} else {
-				req.Body = ioutil.NopCloser(body)
+			req.Body = toReadCloser(body)
+		}
+
+		if i > 0 {
+			if err := c.prepareRetry(req); err != nil {
+				return nil, err
 			}
 		}
 
-		resp, err = c.Inner.Do(req.Request)
+		if c.Hedge != nil && !req.upgrade {
+			resp, err = c.hedged(req)
+		} else {
+			resp, err = c.attempt(req, req.Request)
+		}
 		if resp != nil {
 			code = resp.StatusCode
 		}
+		if c.LoadShed != nil {
+			c.LoadShed.record(req.URL.Host, resp, err)
+		}
+		if c.RateLimit != nil {
+			c.RateLimit.record(req.URL.Host, resp, time.Now())
+		}
+		if c.FailureAlert != nil {
+			c.FailureAlert.record(logger, req.Request, resp, err)
+		}
 		if err != nil {
-			c.Logger.Printf("netter: %s request failed: %v", req.URL, err)
+			logger.Printf("netter: %s request failed: %v", req.URL, err)
 		}
 
-		retryable, checkErr := c.Retry.isRetry(req.Context(), resp, err)
+		if err == nil && resp.StatusCode == http.StatusUnauthorized && c.reauthorize(req) {
+			// the next attempt goes out with refreshed credentials
+			c.drainBody(resp.Body)
+			continue
+		}
+
+		retryable, checkErr := c.checkRetry(req.Context(), policy, resp, err)
 
 		if !retryable {
+			if _, ok := checkErr.(*HookError); ok && err == nil && resp != nil {
+				c.drainBody(resp.Body)
+				resp = nil
+			}
 			if checkErr != nil {
 				err = checkErr
 			}
 			return resp, err
 		}
 
-		remain := c.Retry.Max - i
+		remain := policy.Max - i
 		if remain <= 0 {
 			break
 		}
 
+		hookErr := c.onRetry(req, resp, err, i+1)
+
 		if err == nil && resp != nil {
 			c.drainBody(resp.Body)
 		}
+		if hookErr != nil {
+			return nil, hookErr
+		}
 
-		wait := c.Retry.backoff(c.Retry.WaitMin, c.Retry.WaitMax, i)
+		wait := policy.backoff(policy.WaitMin, policy.WaitMax, i)
 
 		desc := fmt.Sprintf("%s (status: %d)", req.URL, code)
-		c.Logger.Printf("netter: %s retrying in %s (%d left)", desc, wait, remain)
+		logger.Printf("netter: %s retrying in %s (%d left)", desc, wait, remain)
 
+		timer := c.clock().NewTimer(wait)
 		select {
 		case <-req.Context().Done():
+			timer.Stop()
 			return nil, req.Context().Err()
-		case <-time.After(wait):
+		case <-c.lifecycle().abort:
+			timer.Stop()
+			return nil, ErrClientClosed
+		case <-timer.C():
 		}
 	}
 
 	if resp != nil {
 		if err := resp.Body.Close(); err != nil {
-			c.Logger.Printf(err.Error())
+			logger.Printf("netter: closing response body: %v", err)
+		}
+	}
+	return nil, fmt.Errorf("netter: %s giving up after %d attempts", req.URL, policy.Max+1)
+}
+
+// attempt performs a single round trip of req, the http.Request of r
+// or a clone of it
+func (c *Client) attempt(r *Request, req *http.Request) (*http.Response, error) {
+	if err := c.authorize(r, req); err != nil {
+		return nil, err
+	}
+	c.DeadlineHeader.apply(req)
+	if c.Faults != nil {
+		if resp, injected, err := c.Faults.inject(req); injected {
+			return resp, err
+		}
+	}
+	if c.Capture != nil {
+		if err := c.Capture.request(req, c.redaction()); err != nil {
+			c.logger(r).Printf("netter: capturing request: %v", err)
 		}
 	}
-	return nil, fmt.Errorf("netter: %s giving up after %d attempts", req.URL, c.Max+1)
+	proxy := r.proxy
+	if proxy == nil && c.ProxyFunc != nil {
+		var err error
+		if proxy, err = c.proxyFunc(r); err != nil {
+			return nil, err
+		}
+	}
+	if proxy == nil && c.ProxyPool != nil {
+		return c.ProxyPool.roundTrip(c, r, req)
+	}
+	return c.roundTripVia(r, req, proxy)
+}
+
+// roundTripVia sends req through proxy, or the proxy of the client
+// transport when nil
+func (c *Client) roundTripVia(r *Request, req *http.Request, proxy *url.URL) (*http.Response, error) {
+	inner := c.Inner
+	if r.sni != "" || proxy != nil {
+		var err error
+		if inner, err = c.variantClient(r.sni, proxy); err != nil {
+			return nil, err
+		}
+	}
+	inner = c.checkingRedirects(inner)
+	if r.upgrade && inner.Timeout != 0 {
+		// the timeout of http.Client would hide the writable connection
+		// of an upgrade; the handshake is bounded by the context instead
+		cp := *inner
+		cp.Timeout = 0
+		inner = &cp
+	}
+	var send RoundTripperFunc = func(req *http.Request) (*http.Response, error) {
+		req, finish := c.DeadlineTimeouts.scope(req)
+		req, track := c.poolStats().scope(req)
+		resp, err := finish(track(inner.Do(req)))
+		if c.Journal != nil && !r.upgrade {
+			c.journal(req, resp, err)
+		}
+		return resp, err
+	}
+	send = chain(c.AttemptMiddleware, send)
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	if c.Auth != nil && !r.anonymous {
+		resp, err = c.Auth.roundTrip(c, r, req, send)
+	} else {
+		resp, err = send(req)
+	}
+	if c.Capture != nil && resp != nil && !r.upgrade {
+		c.Capture.response(resp, c.redaction(), c.logger(r))
+	}
+	return resp, err
+}
+
+func toReadCloser(body io.Reader) io.ReadCloser {
+	if c, ok := body.(io.ReadCloser); ok {
+		return c
+	}
+	return ioutil.NopCloser(body)
 }
 
 func (c *Client) drainBody(body io.ReadCloser) {
 	_, err := io.Copy(ioutil.Discard, io.LimitReader(body, 4096))
 	if err != nil {
-		c.Logger.Printf("netter: reading response body: %v", err)
+		c.log().Printf("netter: reading response body: %v", err)
 	}
 
 	err = body.Close()
 	if err != nil {
-		c.Logger.Printf(err.Error())
+		c.log().Printf("netter: closing response body: %v", err)
 	}
 }
 
 // Get sends get request
-func Get(url string) (*http.Response, error) {
-	return defaultClient.Get(url)
+func Get(url string, opts ...RequestOption) (*http.Response, error) {
+	return defaultClient.Get(url, opts...)
 }
 
 // Get sends get request
-func (c *Client) Get(url string) (*http.Response, error) {
-	req, err := NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	return c.Do(req)
+func (c *Client) Get(url string, opts ...RequestOption) (*http.Response, error) {
+	return c.send("GET", url, opts...)
 }
 
 // Post sends post request
-func Post(url, bodyType string, body interface{}) (*http.Response, error) {
-	return defaultClient.Post(url, bodyType, body)
+func Post(url, bodyType string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	return defaultClient.Post(url, bodyType, body, opts...)
 }
 
 // Post sends post request
-func (c *Client) Post(url, bodyType string, body interface{}) (*http.Response, error) {
-	req, err := NewRequest("POST", url, body)
+func (c *Client) Post(url, bodyType string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	return c.sendBody("POST", url, bodyType, body, opts...)
+}
+
+// Put sends put request
+func Put(url, bodyType string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	return defaultClient.Put(url, bodyType, body, opts...)
+}
+
+// Put sends put request
+func (c *Client) Put(url, bodyType string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	return c.sendBody("PUT", url, bodyType, body, opts...)
+}
+
+// Patch sends patch request
+func Patch(url, bodyType string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	return defaultClient.Patch(url, bodyType, body, opts...)
+}
+
+// Patch sends patch request
+func (c *Client) Patch(url, bodyType string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	return c.sendBody("PATCH", url, bodyType, body, opts...)
+}
+
+// Delete sends delete request
+func Delete(url string, opts ...RequestOption) (*http.Response, error) {
+	return defaultClient.Delete(url, opts...)
+}
+
+// Delete sends delete request
+func (c *Client) Delete(url string, opts ...RequestOption) (*http.Response, error) {
+	return c.send("DELETE", url, opts...)
+}
+
+// DeleteWithBody sends delete request with a body
+func DeleteWithBody(url, bodyType string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	return defaultClient.DeleteWithBody(url, bodyType, body, opts...)
+}
+
+// DeleteWithBody sends delete request with a body
+func (c *Client) DeleteWithBody(url, bodyType string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	return c.sendBody("DELETE", url, bodyType, body, opts...)
+}
+
+// Head sends head request
+func Head(url string, opts ...RequestOption) (*http.Response, error) {
+	return defaultClient.Head(url, opts...)
+}
+
+// Head sends head request
+func (c *Client) Head(url string, opts ...RequestOption) (*http.Response, error) {
+	return c.send("HEAD", url, opts...)
+}
+
+// Options sends options request
+func Options(url string, opts ...RequestOption) (*http.Response, error) {
+	return defaultClient.Options(url, opts...)
+}
+
+// Options sends options request
+func (c *Client) Options(url string, opts ...RequestOption) (*http.Response, error) {
+	return c.send("OPTIONS", url, opts...)
+}
+
+func (c *Client) send(method, url string, opts ...RequestOption) (*http.Response, error) {
+	req, err := NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req, opts...)
+}
+
+func (c *Client) sendBody(method, url, bodyType string, body interface{}, opts ...RequestOption) (*http.Response, error) {
+	req, err := NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", bodyType)
-	return c.Do(req)
+	return c.Do(req, opts...)
+}
+
+// PostForm sends post request with form-encoded data
+func PostForm(url string, data url.Values, opts ...RequestOption) (*http.Response, error) {
+	return defaultClient.PostForm(url, data, opts...)
+}
+
+// PostForm sends post request with form-encoded data
+func (c *Client) PostForm(url string, data url.Values, opts ...RequestOption) (*http.Response, error) {
+	return c.Post(url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()), opts...)
 }
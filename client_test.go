@@ -1,6 +1,7 @@
 package netgo
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -181,7 +182,7 @@ func newClientServerTest(t *testing.T, tr *http.Transport, h http.Handler, opts
 }
 
 func TestClientHead(t *testing.T) {
-	cst := newClientServerTest(t, defaultTransport, robotsTxtHandler)
+	cst := newClientServerTest(t, defaultTransport.Clone(), robotsTxtHandler)
 	defer cst.close()
 
 	r, err := cst.c.Inner.Head(cst.ts.URL)
@@ -384,3 +385,206 @@ func TestClientTimeout(t *testing.T) {
 		t.Errorf("timeout after %v waiting for timeout of %v", failTime, timeout)
 	}
 }
+
+func TestClientFallback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	var called error
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger}
+	client.Fallback = func(req *Request, err error) (*http.Response, error) {
+		called = err
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("stale")),
+		}, nil
+	}
+
+	res, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer res.Body.Close()
+	if called == nil || !strings.Contains(called.Error(), "giving up after 1 attempts") {
+		t.Fatalf("fallback got bad error: %v", called)
+	}
+	if b, _ := ioutil.ReadAll(res.Body); string(b) != "stale" {
+		t.Fatalf("bad fallback body: %q", b)
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger}
+	client.Max = 10
+	client.WaitMin = time.Minute
+	client.WaitMax = time.Minute
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := client.Get(ts.URL)
+		errc <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := client.Close(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if err := <-errc; err != ErrClientClosed {
+		t.Fatalf("pending retry should be abandoned, got %v", err)
+	}
+	if _, err := client.Get(ts.URL); err != ErrClientClosed {
+		t.Fatalf("closed client accepted request: %v", err)
+	}
+}
+
+func TestClientHookPanic(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger}
+	client.Max = 2
+
+	var prepared int
+	client.PrepareRetry = func(req *Request) error {
+		prepared++
+		req.Header.Set("X-Attempt", strconv.Itoa(prepared))
+		return nil
+	}
+	client.OnRetry = func(req *Request, resp *http.Response, err error, attempt int) {
+		if attempt == 2 {
+			panic("boom")
+		}
+	}
+
+	_, err := client.Get(ts.URL)
+	hookErr, ok := err.(*HookError)
+	if !ok || hookErr.Hook != "OnRetry" || hookErr.Value != "boom" {
+		t.Fatalf("expected OnRetry HookError, got %v", err)
+	}
+	if prepared != 1 {
+		t.Fatalf("PrepareRetry called %d times", prepared)
+	}
+
+	client.OnRetry = nil
+	client.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		panic("check")
+	}
+	if _, err := client.Get(ts.URL); err == nil || !strings.Contains(err.Error(), "CheckRetry hook panicked: check") {
+		t.Fatalf("expected CheckRetry HookError, got %v", err)
+	}
+}
+
+func TestNewClientIndependent(t *testing.T) {
+	a := NewClient()
+	b := NewClient(WithRetry(Retry{Max: 1}), WithTimeout(time.Second))
+	a.Max = 7
+
+	if b.Max != 1 || b.Inner.Timeout != time.Second {
+		t.Fatalf("options not applied: %+v", b.Retry)
+	}
+	if NewClient().Max == 7 || defaultClient.Max == 7 {
+		t.Fatal("clients share retry settings")
+	}
+	if a.Inner == b.Inner || a.Inner.Transport == b.Inner.Transport || a.Inner.Transport == defaultTransport {
+		t.Fatal("clients share transport")
+	}
+}
+
+func TestClientWith(t *testing.T) {
+	base := NewClient()
+	derived := base.With(WithTimeout(time.Second), WithRetry(Retry{Max: 1}))
+
+	if derived.Inner.Transport != base.Inner.Transport {
+		t.Fatal("derived client should share the transport")
+	}
+	if derived.Inner.Timeout != time.Second || base.Inner.Timeout == time.Second {
+		t.Fatal("timeout override leaked into base client")
+	}
+	if derived.Max != 1 || base.Max == 1 {
+		t.Fatal("retry override leaked into base client")
+	}
+}
+
+func TestClientCloseIdleConnections(t *testing.T) {
+	a, b := NewClient(), NewClient()
+	a.CloseIdleConnections()
+	if b.Inner.Transport == defaultClient.Inner.Transport || a.Inner.Transport == b.Inner.Transport {
+		t.Fatal("clients share a transport")
+	}
+}
+
+func TestClientVerbs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		w.Header().Set("X-Method", req.Method)
+		w.Header().Set("X-Body", string(body))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	calls := map[string]func() (*http.Response, error){
+		"PUT":   func() (*http.Response, error) { return client.Put(ts.URL, "text/plain", strings.NewReader("b")) },
+		"PATCH": func() (*http.Response, error) { return client.Patch(ts.URL, "text/plain", strings.NewReader("b")) },
+		"DELETE": func() (*http.Response, error) {
+			return client.DeleteWithBody(ts.URL, "text/plain", strings.NewReader("b"))
+		},
+		"HEAD":    func() (*http.Response, error) { return client.Head(ts.URL) },
+		"OPTIONS": func() (*http.Response, error) { return client.Options(ts.URL) },
+	}
+	for method, call := range calls {
+		res, err := call()
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		res.Body.Close()
+		if res.Header.Get("X-Method") != method {
+			t.Errorf("%s: server saw %s", method, res.Header.Get("X-Method"))
+		}
+		if (method == "PUT" || method == "PATCH" || method == "DELETE") && res.Header.Get("X-Body") != "b" {
+			t.Errorf("%s: body not sent", method)
+		}
+	}
+}
+
+func TestClientDefaultHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Agent", req.UserAgent())
+		w.Header().Set("X-Got-Service", req.Header.Get("X-Service"))
+	}))
+	defer ts.Close()
+
+	base := NewClient(WithTransport(ts.Client().Transport), WithUserAgent("netgo-test/1"), WithDefaultHeader("x-service", "billing"))
+	derived := base.With(WithDefaultHeader("X-Extra", "1"))
+	if base.Headers.Get("X-Extra") != "" {
+		t.Fatal("derived header leaked into base client")
+	}
+
+	resp, err := derived.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Agent") != "netgo-test/1" || resp.Header.Get("X-Got-Service") != "billing" {
+		t.Fatalf("defaults not sent: %v", resp.Header)
+	}
+
+	resp, err = derived.Get(ts.URL, WithHeader("User-Agent", "mine"), WithHeader("X-Service", "search"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Agent") != "mine" || resp.Header.Get("X-Got-Service") != "search" {
+		t.Fatalf("request headers not preferred: %v", resp.Header)
+	}
+}
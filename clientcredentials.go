@@ -0,0 +1,192 @@
+package netgo
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientCredentials is a TokenSource running the OAuth2
+// client_credentials grant against TokenURL. Tokens are cached and
+// renewed ahead of expiry at a random point of the EarlyRefresh window,
+// so a fleet of clients does not refresh all at once. It may serve as
+// the TokenSource of the very client it sends token requests with.
+type ClientCredentials struct {
+	// Client sends the token requests, through its retry loop
+	Client       *Client
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// EndpointParams are added to the token request form
+	EndpointParams url.Values
+	// AuthInBody sends the client credentials as form fields instead
+	// of basic auth
+	AuthInBody bool
+	// EarlyRefresh is the window before expiry in which the token is
+	// renewed, one minute by default
+	EarlyRefresh time.Duration
+
+	cache refreshCache
+}
+
+// Token implements TokenSource
+func (cc *ClientCredentials) Token() (*Token, error) {
+	return cc.tokenContext(context.Background())
+}
+
+func (cc *ClientCredentials) tokenContext(ctx context.Context) (*Token, error) {
+	return cc.cache.get(ctx, cc.EarlyRefresh, func(ctx context.Context) (*Token, error) {
+		form := url.Values{"grant_type": {"client_credentials"}}
+		if len(cc.Scopes) > 0 {
+			form.Set("scope", strings.Join(cc.Scopes, " "))
+		}
+		for k, vs := range cc.EndpointParams {
+			form[k] = append(form[k], vs...)
+		}
+		authorization := basicAuth(url.QueryEscape(cc.ClientID), url.QueryEscape(cc.ClientSecret))
+		if cc.AuthInBody {
+			form.Set("client_id", cc.ClientID)
+			form.Set("client_secret", cc.ClientSecret)
+			authorization = ""
+		}
+		tok, err := requestToken(ctx, cc.Client, cc.TokenURL, form, authorization)
+		if err != nil {
+			return nil, fmt.Errorf("netter: client credentials grant: %w", err)
+		}
+		return tok, nil
+	})
+}
+
+func (cc *ClientCredentials) invalidate() {
+	cc.cache.invalidate()
+}
+
+// refreshingSource is a TokenSource caching its own tokens, which the
+// client asks every time instead of caching them again
+type refreshingSource interface {
+	TokenSource
+	// tokenContext is Token fetching with the context of the request
+	// needing the token
+	tokenContext(ctx context.Context) (*Token, error)
+	// invalidate drops the cached token after it was rejected
+	invalidate()
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// requestToken posts form to the OAuth2 token endpoint tokenURL through
+// c, authorized with authorization unless empty
+func requestToken(ctx context.Context, c *Client, tokenURL string, form url.Values, authorization string) (*Token, error) {
+	req, err := NewRequestWithContext(ctx, "POST", tokenURL, form.Encode())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.skipTokenSource = true
+	req.authorization = authorization
+
+	now := time.Now()
+	var tr tokenResponse
+	if err := c.doJSON(req, &tr); err != nil {
+		return nil, err
+	}
+	if tr.AccessToken == "" {
+		return nil, fmt.Errorf("no access_token in response")
+	}
+	tok := &Token{AccessToken: tr.AccessToken, TokenType: tr.TokenType}
+	if tr.ExpiresIn > 0 {
+		tok.Expiry = now.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// refreshCache holds a token until a random point of the early refresh
+// window before its expiry
+type refreshCache struct {
+	mu        sync.Mutex
+	tok       *Token
+	refreshAt time.Time
+	// fetching is the fetch in flight, which concurrent callers wait for
+	fetching *tokenFetch
+}
+
+type tokenFetch struct {
+	done chan struct{}
+	tok  *Token
+	err  error
+	// abandoned is set when the context of the fetching caller ended
+	abandoned bool
+}
+
+// get returns the cached token or one of fetch, renewed within early of
+// its expiry, one minute by default. A single fetch runs at a time;
+// callers waiting for it give up when ctx ends, and fetch again when the
+// context of the caller fetching ended first.
+func (rc *refreshCache) get(ctx context.Context, early time.Duration, fetch func(ctx context.Context) (*Token, error)) (*Token, error) {
+	for {
+		rc.mu.Lock()
+		if rc.tok != nil && (rc.refreshAt.IsZero() || time.Now().Before(rc.refreshAt)) {
+			tok := rc.tok
+			rc.mu.Unlock()
+			return tok, nil
+		}
+		if f := rc.fetching; f != nil {
+			rc.mu.Unlock()
+			select {
+			case <-f.done:
+				if f.abandoned {
+					continue
+				}
+				return f.tok, f.err
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		f := &tokenFetch{done: make(chan struct{})}
+		rc.fetching = f
+		rc.mu.Unlock()
+
+		now := time.Now()
+		f.tok, f.err = fetch(ctx)
+		rc.mu.Lock()
+		rc.fetching = nil
+		if f.err == nil {
+			rc.store(f.tok, now, early)
+		} else {
+			f.abandoned = ctx.Err() != nil
+		}
+		rc.mu.Unlock()
+		close(f.done)
+		return f.tok, f.err
+	}
+}
+
+// store caches tok, fetched at now
+func (rc *refreshCache) store(tok *Token, now time.Time, early time.Duration) {
+	rc.tok, rc.refreshAt = tok, time.Time{}
+	if !tok.Expiry.IsZero() {
+		if early <= 0 {
+			early = time.Minute
+		}
+		if lifetime := tok.Expiry.Sub(now); early > lifetime/2 {
+			early = lifetime / 2
+		}
+		// somewhere in the second half of the early refresh window
+		rc.refreshAt = tok.Expiry.Add(-early/2 - time.Duration(rand.Int63n(int64(early/2)+1)))
+	}
+}
+
+func (rc *refreshCache) invalidate() {
+	rc.mu.Lock()
+	rc.tok = nil
+	rc.mu.Unlock()
+}
@@ -0,0 +1,36 @@
+package netgo
+
+import "time"
+
+// Clock provides the timers the retry loop waits on between attempts.
+// Tests substitute a fake clock to observe backoff without sleeping.
+type Clock interface {
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is the subset of *time.Timer used by the retry loop
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+func (c *Client) clock() Clock {
+	if c.Clock == nil {
+		return realClock{}
+	}
+	return c.Clock
+}
@@ -0,0 +1,47 @@
+package netgo
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// WithCompressedBody gzips the request body while it is sent and sets
+// Content-Encoding: gzip. Every attempt compresses the body afresh, so
+// retries stay possible; the compressed size is unknown upfront and the
+// body goes out chunked.
+func WithCompressedBody() RequestOption {
+	return func(r *Request) {
+		if r.body == nil || r.Header.Get("Content-Encoding") != "" {
+			return
+		}
+		open := r.body
+		r.body = func() (io.Reader, error) {
+			src, err := open()
+			if err != nil {
+				return nil, err
+			}
+			return gzipReader(src), nil
+		}
+		r.GetBody = getBody(r.body)
+		r.ContentLength = -1
+		r.Header.Set("Content-Encoding", "gzip")
+	}
+}
+
+// gzipReader streams src compressed; closing the reader stops the
+// compression and closes src
+func gzipReader(src io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		zw := gzip.NewWriter(pw)
+		_, err := io.Copy(zw, src)
+		if err == nil {
+			err = zw.Close()
+		}
+		if c, ok := src.(io.Closer); ok {
+			c.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
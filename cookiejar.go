@@ -0,0 +1,310 @@
+package netgo
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithCookieJar stores and sends cookies with jar, e.g. a
+// PersistentCookieJar
+func WithCookieJar(jar http.CookieJar) Option {
+	return func(c *Client) {
+		c.Inner.Jar = jar
+	}
+}
+
+// StoredCookie is a cookie as kept by a PersistentCookieJar
+type StoredCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	HostOnly bool      `json:"host_only,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+	SameSite string    `json:"same_site,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Created  time.Time `json:"created"`
+}
+
+func (sc *StoredCookie) id() string {
+	return sc.Domain + ";" + sc.Path + ";" + sc.Name
+}
+
+func (sc *StoredCookie) expired(now time.Time) bool {
+	return !sc.Expires.IsZero() && !sc.Expires.After(now)
+}
+
+// CookieStore persists the cookies of a PersistentCookieJar
+type CookieStore interface {
+	Load() ([]StoredCookie, error)
+	Save(cookies []StoredCookie) error
+}
+
+// FileCookieStore keeps cookies as JSON in a file, replaced atomically
+// on every save
+type FileCookieStore struct {
+	Path string
+}
+
+// Load implements CookieStore; a missing file holds no cookies
+func (s *FileCookieStore) Load() ([]StoredCookie, error) {
+	data, err := os.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cookies []StoredCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// Save implements CookieStore
+func (s *FileCookieStore) Save(cookies []StoredCookie) error {
+	data, err := json.MarshalIndent(cookies, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.Path)
+}
+
+// PersistentCookieJar is an http.CookieJar following the domain, path,
+// secure and expiry rules of RFC 6265 that saves its cookies to a
+// CookieStore whenever they change, so sessions survive restarts.
+// Those saves are best effort; Flush reports whether saving works.
+type PersistentCookieJar struct {
+	store CookieStore
+	psl   cookiejar.PublicSuffixList
+	// session keeps cookies without expiry in the store too
+	session bool
+
+	mu      sync.Mutex
+	cookies map[string]*StoredCookie
+}
+
+// NewPersistentCookieJar represents new jar loading its cookies from
+// store. Without psl, domain cookies are only refused for single-label
+// domains such as "com"; pass a public suffix list, e.g. of
+// golang.org/x/net/publicsuffix, to refuse them for every public
+// suffix. Session cookies, lacking an expiry, are persisted when
+// keepSession is set.
+func NewPersistentCookieJar(store CookieStore, psl cookiejar.PublicSuffixList, keepSession bool) (*PersistentCookieJar, error) {
+	j := &PersistentCookieJar{store: store, psl: psl, session: keepSession, cookies: make(map[string]*StoredCookie)}
+	loaded, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i := range loaded {
+		sc := &loaded[i]
+		if !sc.expired(now) {
+			j.cookies[sc.id()] = sc
+		}
+	}
+	return j, nil
+}
+
+// SetCookies implements http.CookieJar
+func (j *PersistentCookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	host := canonicalCookieHost(u.Host)
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	changed := false
+	for _, c := range cookies {
+		sc, ok := j.newCookie(c, u, host, now)
+		if !ok {
+			continue
+		}
+		id := sc.id()
+		old := j.cookies[id]
+		if sc.expired(now) {
+			if old != nil {
+				delete(j.cookies, id)
+				changed = true
+			}
+			continue
+		}
+		if old != nil {
+			sc.Created = old.Created
+		}
+		j.cookies[id] = sc
+		changed = true
+	}
+	if changed {
+		j.save()
+	}
+}
+
+// newCookie validates c as received from host; expired results delete
+// the cookie they replace
+func (j *PersistentCookieJar) newCookie(c *http.Cookie, u *url.URL, host string, now time.Time) (*StoredCookie, bool) {
+	sc := &StoredCookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		Created:  now,
+	}
+	switch c.SameSite {
+	case http.SameSiteLaxMode:
+		sc.SameSite = "Lax"
+	case http.SameSiteStrictMode:
+		sc.SameSite = "Strict"
+	case http.SameSiteNoneMode:
+		sc.SameSite = "None"
+	}
+	if sc.Path == "" || sc.Path[0] != '/' {
+		sc.Path = defaultCookiePath(u.Path)
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+	switch {
+	case domain == "" || domain == host:
+		sc.Domain, sc.HostOnly = host, c.Domain == ""
+		if !sc.HostOnly && j.isPublicSuffix(domain) {
+			// a suffix may set cookies for itself only
+			sc.HostOnly = true
+		}
+	case net.ParseIP(host) != nil || !strings.HasSuffix(host, "."+domain) || j.isPublicSuffix(domain):
+		return nil, false
+	default:
+		sc.Domain = domain
+	}
+
+	switch {
+	case c.MaxAge < 0:
+		sc.Expires = now
+	case c.MaxAge > 0:
+		sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		sc.Expires = c.Expires
+		if !sc.Expires.After(now) {
+			sc.Expires = now
+		}
+	}
+	return sc, true
+}
+
+func (j *PersistentCookieJar) isPublicSuffix(domain string) bool {
+	if j.psl != nil {
+		return j.psl.PublicSuffix(domain) == domain
+	}
+	return !strings.Contains(domain, ".")
+}
+
+// Cookies implements http.CookieJar
+func (j *PersistentCookieJar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	host := canonicalCookieHost(u.Host)
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	j.mu.Lock()
+	var (
+		matched []*StoredCookie
+		expired bool
+	)
+	for id, sc := range j.cookies {
+		if sc.expired(now) {
+			delete(j.cookies, id)
+			expired = true
+			continue
+		}
+		if sc.Secure && u.Scheme != "https" {
+			continue
+		}
+		if sc.HostOnly && host != sc.Domain || !sc.HostOnly && host != sc.Domain && !strings.HasSuffix(host, "."+sc.Domain) {
+			continue
+		}
+		if !cookiePathMatch(sc.Path, path) {
+			continue
+		}
+		matched = append(matched, sc)
+	}
+	if expired {
+		j.save()
+	}
+	j.mu.Unlock()
+
+	// longer paths first, then older cookies, as RFC 6265 recommends
+	sort.Slice(matched, func(a, b int) bool {
+		if len(matched[a].Path) != len(matched[b].Path) {
+			return len(matched[a].Path) > len(matched[b].Path)
+		}
+		return matched[a].Created.Before(matched[b].Created)
+	})
+	out := make([]*http.Cookie, len(matched))
+	for i, sc := range matched {
+		out[i] = &http.Cookie{Name: sc.Name, Value: sc.Value}
+	}
+	return out
+}
+
+// Flush saves the cookies now
+func (j *PersistentCookieJar) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.save()
+}
+
+func (j *PersistentCookieJar) save() error {
+	out := make([]StoredCookie, 0, len(j.cookies))
+	for _, sc := range j.cookies {
+		if sc.Expires.IsZero() && !j.session {
+			continue
+		}
+		out = append(out, *sc)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].id() < out[b].id() })
+	return j.store.Save(out)
+}
+
+func canonicalCookieHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// defaultCookiePath is the directory of the request path
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+func cookiePathMatch(cookiePath, path string) bool {
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+	return len(path) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
@@ -0,0 +1,83 @@
+package netgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestPersistentCookieJar(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", MaxAge: 3600})
+			http.SetCookie(w, &http.Cookie{Name: "tmp", Value: "1"})
+			return
+		}
+		if c, err := req.Cookie("session"); err != nil || c.Value != "abc" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	store := &FileCookieStore{Path: filepath.Join(t.TempDir(), "cookies.json")}
+	jar, err := NewPersistentCookieJar(store, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(WithTransport(ts.Client().Transport), WithCookieJar(jar))
+	resp, err := client.Get(ts.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// a restarted process picks up the persistent cookie only
+	jar, err = NewPersistentCookieJar(store, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = NewClient(WithTransport(ts.Client().Transport), WithCookieJar(jar))
+	resp, err = client.Get(ts.URL + "/data")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("after restart: %v %v", resp, err)
+	}
+	resp.Body.Close()
+	u, _ := url.Parse(ts.URL)
+	if got := jar.Cookies(u); len(got) != 1 || got[0].Name != "session" {
+		t.Fatalf("restored cookies %v", got)
+	}
+
+	set := func(rawurl string, c *http.Cookie) {
+		u, _ := url.Parse(rawurl)
+		jar.SetCookies(u, []*http.Cookie{c})
+	}
+	names := func(rawurl string) (out []string) {
+		u, _ := url.Parse(rawurl)
+		for _, c := range jar.Cookies(u) {
+			out = append(out, c.Name)
+		}
+		return out
+	}
+	set("https://www.example.com/shop/cart", &http.Cookie{Name: "cart", Value: "1"})
+	set("https://www.example.com/", &http.Cookie{Name: "wide", Value: "1", Domain: ".example.com", Secure: true})
+	set("https://www.example.com/", &http.Cookie{Name: "tld", Value: "1", Domain: "com"})
+	set("https://www.example.com/", &http.Cookie{Name: "other", Value: "1", Domain: "example.org"})
+	cases := map[string][]string{
+		"https://www.example.com/shop/cart/x": {"cart", "wide"},
+		"https://www.example.com/shopping":    {"wide"},
+		"http://api.example.com/":             nil,
+		"https://api.example.com/":            {"wide"},
+		"https://example.org/":                nil,
+	}
+	for rawurl, want := range cases {
+		if got := names(rawurl); len(got) != len(want) || len(want) > 0 && got[0] != want[0] {
+			t.Errorf("%s: cookies %q, want %q", rawurl, got, want)
+		}
+	}
+	set("https://www.example.com/", &http.Cookie{Name: "wide", Domain: "example.com", MaxAge: -1})
+	if got := names("https://api.example.com/"); len(got) != 0 {
+		t.Fatalf("deleted cookie still sent: %q", got)
+	}
+}
@@ -0,0 +1,190 @@
+package netgo
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CrawlDelay enforces a minimum interval between successive requests
+// to the same host
+type CrawlDelay struct {
+	// Delay between requests to one host
+	Delay time.Duration
+	// Robots fetches /robots.txt on first contact with a host and uses
+	// its Crawl-delay when longer than Delay
+	Robots bool
+	// UserAgent picks the robots.txt group, "*" is used as fallback, and
+	// is sent when fetching it; the client's User-Agent by default
+	UserAgent string
+
+	mu    sync.Mutex
+	hosts map[string]*crawlHost
+}
+
+type crawlHost struct {
+	// fetch serializes the robots.txt fetches; robots is set once one
+	// completed
+	fetch  sync.Mutex
+	robots bool
+	delay  time.Duration
+	next   time.Time
+}
+
+func (cd *CrawlDelay) host(u *url.URL) *crawlHost {
+	cd.mu.Lock()
+	defer cd.mu.Unlock()
+	if cd.hosts == nil {
+		cd.hosts = make(map[string]*crawlHost)
+	}
+	h, ok := cd.hosts[u.Host]
+	if !ok {
+		h = &crawlHost{delay: cd.Delay}
+		cd.hosts[u.Host] = h
+	}
+	return h
+}
+
+// wait blocks until the host of u may be requested again
+func (cd *CrawlDelay) wait(ctx context.Context, c *Client, u *url.URL) error {
+	h := cd.host(u)
+	if cd.Robots {
+		cd.robots(ctx, c, u, h)
+	}
+
+	cd.mu.Lock()
+	now := time.Now()
+	at := h.next
+	if at.Before(now) {
+		at = now
+	}
+	h.next = at.Add(h.delay)
+	cd.mu.Unlock()
+
+	wait := time.Until(at)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// robots applies the Crawl-delay of the robots.txt of u's host. A fetch
+// cut short by ctx is tried again by the next request.
+func (cd *CrawlDelay) robots(ctx context.Context, c *Client, u *url.URL, h *crawlHost) {
+	h.fetch.Lock()
+	defer h.fetch.Unlock()
+	if h.robots {
+		return
+	}
+	agent := cd.UserAgent
+	if agent == "" {
+		agent = c.UserAgent
+	}
+	if agent == "" {
+		agent = c.Headers.Get("User-Agent")
+	}
+	d, ok := c.robotsCrawlDelay(ctx, u, agent)
+	if ctx.Err() != nil {
+		return
+	}
+	h.robots = true
+	if ok {
+		cd.mu.Lock()
+		if d > h.delay {
+			h.delay = d
+		}
+		cd.mu.Unlock()
+	}
+}
+
+func (c *Client) robotsCrawlDelay(ctx context.Context, u *url.URL, agent string) (time.Duration, bool) {
+	robots := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	if err := c.checkURL(ctx, robots); err != nil {
+		c.log().Printf("netter: fetching %s: %v", robots, err)
+		return 0, false
+	}
+	req, err := http.NewRequestWithContext(c.withHostPolicy(ctx), "GET", robots.String(), nil)
+	if err != nil {
+		return 0, false
+	}
+	if agent != "" {
+		req.Header.Set("User-Agent", agent)
+	}
+	resp, err := c.checkingRedirects(c.Inner).Do(req)
+	if err != nil {
+		c.log().Printf("netter: fetching %s: %v", robots, err)
+		return 0, false
+	}
+	defer c.drainBody(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return 0, false
+	}
+	return parseCrawlDelay(io.LimitReader(resp.Body, 512<<10), agent)
+}
+
+// parseCrawlDelay returns the Crawl-delay of the group matching agent,
+// falling back to the "*" group
+func parseCrawlDelay(r io.Reader, agent string) (time.Duration, bool) {
+	agent = strings.ToLower(agent)
+	var (
+		group           []string
+		inRules         bool
+		exact, wildcard time.Duration
+		hasExact, hasWc bool
+	)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		val := strings.TrimSpace(kv[1])
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				group, inRules = nil, false
+			}
+			group = append(group, strings.ToLower(val))
+		case "crawl-delay":
+			inRules = true
+			secs, err := strconv.ParseFloat(val, 64)
+			if err != nil || secs < 0 {
+				continue
+			}
+			d := time.Duration(secs * float64(time.Second))
+			for _, ua := range group {
+				switch {
+				case agent != "" && strings.Contains(agent, ua):
+					exact, hasExact = d, true
+				case ua == "*":
+					wildcard, hasWc = d, true
+				}
+			}
+		default:
+			inRules = true
+		}
+	}
+	if hasExact {
+		return exact, true
+	}
+	return wildcard, hasWc
+}
@@ -0,0 +1,91 @@
+package netgo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const robotsTxt = `# comment
+User-agent: *
+Disallow: /private/
+Crawl-delay: 2
+
+User-agent: netgo
+User-agent: other
+Crawl-delay: 0.5
+`
+
+func TestParseCrawlDelay(t *testing.T) {
+	if d, ok := parseCrawlDelay(strings.NewReader(robotsTxt), "netgo/1.0"); !ok || d != 500*time.Millisecond {
+		t.Fatalf("bad agent delay: %v %v", d, ok)
+	}
+	if d, ok := parseCrawlDelay(strings.NewReader(robotsTxt), "curl"); !ok || d != 2*time.Second {
+		t.Fatalf("bad wildcard delay: %v %v", d, ok)
+	}
+	if _, ok := parseCrawlDelay(strings.NewReader("User-agent: *\nDisallow: /\n"), ""); ok {
+		t.Fatal("delay found in robots.txt without Crawl-delay")
+	}
+}
+
+func TestClientCrawlDelay(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/robots.txt" {
+			_, _ = w.Write([]byte("User-agent: *\nCrawl-delay: 0.2\n"))
+		}
+	}))
+	defer ts.Close()
+
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger,
+		CrawlDelay: &CrawlDelay{Delay: 50 * time.Millisecond, Robots: true}}
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL + "/page")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Fatalf("robots.txt crawl delay not honored: %v", elapsed)
+	}
+}
+
+func TestCrawlDelayRobotsFetch(t *testing.T) {
+	var agents []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/robots.txt" {
+			agents = append(agents, req.UserAgent())
+			_, _ = w.Write([]byte(robotsTxt))
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}), WithUserAgent("netgo/1.0"))
+	client.CrawlDelay = &CrawlDelay{Robots: true}
+
+	// a fetch cut short by the caller is not the final word
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := NewRequestWithContext(ctx, "GET", ts.URL+"/page", nil)
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("cancelled request succeeded")
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL + "/page")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if len(agents) != 1 || agents[0] != "netgo/1.0" {
+		t.Fatalf("robots.txt fetched by %q, want once by netgo/1.0", agents)
+	}
+	if h := client.CrawlDelay.hosts[ts.Listener.Addr().String()]; h.delay != 500*time.Millisecond {
+		t.Fatalf("crawl delay %v, want the netgo group", h.delay)
+	}
+}
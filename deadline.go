@@ -0,0 +1,161 @@
+package netgo
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DeadlineTimeouts derives dial, TLS handshake and response header
+// timeouts of every attempt from the time left until the request
+// context deadline. Requests without a deadline are not affected.
+type DeadlineTimeouts struct {
+	// Fractions of the remaining deadline given to each phase
+	Dial, TLSHandshake, ResponseHeader float64
+	// Floor and Ceiling clamp each derived timeout when non-zero
+	Floor, Ceiling time.Duration
+}
+
+func (d *DeadlineTimeouts) derive(remain time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return 0
+	}
+	t := time.Duration(float64(remain) * fraction)
+	if d.Floor > 0 && t < d.Floor {
+		t = d.Floor
+	}
+	if d.Ceiling > 0 && t > d.Ceiling {
+		t = d.Ceiling
+	}
+	return t
+}
+
+// phaseTimer cancels the attempt when a phase outlives its budget
+type phaseTimer struct {
+	mu     sync.Mutex
+	timer  *time.Timer
+	cancel context.CancelCauseFunc
+}
+
+func (p *phaseTimer) start(phase string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.timer = time.AfterFunc(d, func() {
+		p.cancel(fmt.Errorf("netter: %s timeout after %s", phase, d))
+	})
+}
+
+func (p *phaseTimer) stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+}
+
+// scope attaches the phase timeouts to req; finish must be called
+// with the attempt result
+func (d *DeadlineTimeouts) scope(req *http.Request) (*http.Request, func(*http.Response, error) (*http.Response, error)) {
+	deadline, ok := req.Context().Deadline()
+	if d == nil || !ok {
+		return req, func(resp *http.Response, err error) (*http.Response, error) { return resp, err }
+	}
+	remain := time.Until(deadline)
+
+	parent := req.Context()
+	ctx, cancel := context.WithCancelCause(parent)
+	p := &phaseTimer{cancel: cancel}
+	dial := d.derive(remain, d.Dial)
+	handshake := d.derive(remain, d.TLSHandshake)
+	header := d.derive(remain, d.ResponseHeader)
+
+	trace := &httptrace.ClientTrace{
+		ConnectStart:         func(string, string) { p.start("dial", dial) },
+		ConnectDone:          func(string, string, error) { p.stop() },
+		TLSHandshakeStart:    func() { p.start("tls handshake", handshake) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { p.stop() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { p.start("response header", header) },
+		GotFirstResponseByte: p.stop,
+	}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+	return req, func(resp *http.Response, err error) (*http.Response, error) {
+		p.stop()
+		if err != nil {
+			if ctx.Err() != nil && parent.Err() == nil {
+				err = context.Cause(ctx)
+			}
+			cancel(nil)
+			return resp, err
+		}
+		resp.Body = &cancelBody{resp.Body, func() { cancel(nil) }}
+		return resp, nil
+	}
+}
+
+// DeadlineHeader sends the time left until the request context deadline
+// with every attempt, so upstreams can give up early
+type DeadlineHeader struct {
+	// Name of the header, X-Request-Deadline by default
+	Name string
+	// Format renders the remaining time, milliseconds by default
+	Format func(time.Duration) string
+}
+
+// DeadlineMillis formats the remaining time as integer milliseconds
+func DeadlineMillis(d time.Duration) string {
+	return strconv.FormatInt(d.Milliseconds(), 10)
+}
+
+// DeadlineGRPC formats the remaining time like the grpc-timeout header,
+// using the finest unit that fits in 8 digits, e.g. "1500000u" for 1.5s
+func DeadlineGRPC(d time.Duration) string {
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"n", time.Nanosecond},
+		{"u", time.Microsecond},
+		{"m", time.Millisecond},
+		{"S", time.Second},
+		{"M", time.Minute},
+		{"H", time.Hour},
+	}
+	for _, u := range units {
+		if v := d / u.unit; v < 1e8 {
+			return strconv.FormatInt(int64(v), 10) + u.suffix
+		}
+	}
+	return strconv.FormatInt(int64(d/time.Hour), 10) + "H"
+}
+
+func (h *DeadlineHeader) apply(req *http.Request) {
+	deadline, ok := req.Context().Deadline()
+	if h == nil || !ok {
+		return
+	}
+	remain := time.Until(deadline)
+	if remain < 0 {
+		remain = 0
+	}
+	name, format := h.Name, h.Format
+	if name == "" {
+		name = "X-Request-Deadline"
+	}
+	if format == nil {
+		format = DeadlineMillis
+	}
+	req.Header.Set(name, format(remain))
+}
@@ -0,0 +1,65 @@
+package netgo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeadlineTimeoutsDerive(t *testing.T) {
+	d := &DeadlineTimeouts{Floor: 100 * time.Millisecond, Ceiling: time.Second}
+	if got := d.derive(10*time.Second, 0.5); got != time.Second {
+		t.Fatalf("ceiling not applied: %v", got)
+	}
+	if got := d.derive(time.Second, 0.01); got != 100*time.Millisecond {
+		t.Fatalf("floor not applied: %v", got)
+	}
+	if got := d.derive(time.Second, 0.5); got != 500*time.Millisecond {
+		t.Fatalf("bad timeout: %v", got)
+	}
+}
+
+func TestDeadlineTimeoutsResponseHeader(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger,
+		DeadlineTimeouts: &DeadlineTimeouts{ResponseHeader: 0.1}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	start := time.Now()
+	_, err = client.attempt(&Request{Request: req}, req)
+	if err == nil || !strings.Contains(err.Error(), "response header timeout") {
+		t.Fatalf("expected response header timeout, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("timeout not derived from deadline: %v", time.Since(start))
+	}
+}
+
+func TestDeadlineGRPC(t *testing.T) {
+	cases := map[time.Duration]string{
+		1500 * time.Millisecond: "1500000u",
+		2 * time.Minute:         "120000m",
+		200 * time.Hour:         "720000S",
+	}
+	for d, want := range cases {
+		if got := DeadlineGRPC(d); got != want {
+			t.Errorf("DeadlineGRPC(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package netgo
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Decoder decodes a response body into v
+type Decoder func(r io.Reader, v interface{}) error
+
+var (
+	decodersMu sync.RWMutex
+	decoders   = map[string]Decoder{
+		"application/json": decodeJSON,
+		"application/xml":  decodeXML,
+		"text/xml":         decodeXML,
+	}
+)
+
+func decodeJSON(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+func decodeXML(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// RegisterDecoder sets the decoder DoAs uses for a media type
+func RegisterDecoder(mediaType string, d Decoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	decoders[strings.ToLower(mediaType)] = d
+}
+
+// decoderFor picks the decoder for a Content-Type, honouring structured
+// syntax suffixes such as +json and +xml and defaulting to JSON
+func decoderFor(contentType string) Decoder {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return decodeJSON
+	}
+	decodersMu.RLock()
+	defer decodersMu.RUnlock()
+	if d, ok := decoders[mediaType]; ok {
+		return d
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		if d, ok := decoders["application/"+mediaType[i+1:]]; ok {
+			return d
+		}
+	}
+	return decodeJSON
+}
+
+// DoAs sends req with ctx and decodes a 2xx response into a T chosen by
+// the response Content-Type; other statuses fail with an *HTTPError.
+// The body is closed when DoAs returns; the response is returned for its
+// status and headers.
+func DoAs[T any](ctx context.Context, c *Client, req *Request, opts ...RequestOption) (T, *http.Response, error) {
+	var out T
+	if ctx != nil {
+		req.Request = req.Request.WithContext(ctx)
+	}
+	resp, err := c.Do(req, opts...)
+	if err != nil {
+		CloseBody(resp)
+		return out, resp, err
+	}
+	defer CloseBody(resp)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return out, resp, newHTTPError(resp)
+	}
+	if resp.StatusCode == http.StatusNoContent || req.Method == "HEAD" {
+		return out, resp, nil
+	}
+	err = decoderFor(resp.Header.Get("Content-Type"))(resp.Body, &out)
+	return out, resp, err
+}
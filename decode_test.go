@@ -0,0 +1,37 @@
+package netgo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type typedUser struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestDoAs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/xml" {
+			w.Header().Set("Content-Type", "application/vnd.user+xml; charset=utf-8")
+			_, _ = w.Write([]byte("<user><name>xml</name></user>"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"json"}`))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	for path, want := range map[string]string{"/json": "json", "/xml": "xml"} {
+		req, _ := NewRequest("GET", ts.URL+path, nil)
+		u, resp, err := DoAs[typedUser](context.Background(), client, req)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if u.Name != want || resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: bad result %+v %d", path, u, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package netgo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// dialer dials the connections of a client transport, resolving host
+// names itself
+type dialer struct {
+	// tr is the transport dialing through the dialer
+	tr *http.Transport
+	// next dials resolved addresses
+	next func(ctx context.Context, network, addr string) (net.Conn, error)
+	// cache resolves host names when set, otherwise resolver
+	cache    *DNSCache
+	resolver Resolver
+	// overrides maps dialed addresses to others, see WithDialOverride
+	overrides map[string]string
+	// unixSocket receives every connection when set
+	unixSocket string
+	// srv maps hosts to the services they stand for, see WithSRV
+	srv map[string]*SRVDiscovery
+	// family selects the addresses dialed, attemptDelay staggers them
+	family       int
+	attemptDelay time.Duration
+	// stats tracks the connections dialed when set, see WithPoolStats
+	stats *poolStats
+	// checksHosts tells whether next dials through controlHostPolicy
+	checksHosts bool
+}
+
+// withDialer clones the client transport and lets fn configure the
+// dialer it dials through; other transports are left alone. The dial
+// state of the client carries over whatever options cloned the transport
+// in between; a transport replaced by WithTransport is dialed through its
+// own dial function.
+func withDialer(fn func(d *dialer)) Option {
+	return func(c *Client) {
+		tr, ok := c.Inner.Transport.(*http.Transport)
+		if !ok {
+			return
+		}
+		d := &dialer{}
+		if c.dialer != nil {
+			*d = *c.dialer
+		}
+		if c.dialer == nil || !isDialerFunc(tr.DialContext) {
+			d.next = tr.DialContext
+			d.checksHosts = isBaseDial(tr.DialContext)
+		}
+		if d.next == nil {
+			d.next, d.checksHosts = dialBase, true
+		}
+		tr = tr.Clone()
+		tr.DialContext = d.DialContext
+		d.tr = tr
+		fn(d)
+		c.dialer = d
+		c.Inner.Transport = tr
+	}
+}
+
+// isDialerFunc tells whether dial is the DialContext of some dialer
+func isDialerFunc(dial func(ctx context.Context, network, addr string) (net.Conn, error)) bool {
+	return dial != nil && reflect.ValueOf(dial).Pointer() == reflect.ValueOf((&dialer{}).DialContext).Pointer()
+}
+
+// WithDialOverride dials target whenever the client connects to addr,
+// like an entry of a hosts file. Both are host:port, or a host alone to
+// match any port and keep it. TLS still verifies, and the Host header
+// still names, the original host; see WithHostOverride to change the
+// Host header instead.
+func WithDialOverride(addr, target string) Option {
+	return withDialer(func(d *dialer) {
+		overrides := make(map[string]string, len(d.overrides)+1)
+		for k, v := range d.overrides {
+			overrides[k] = v
+		}
+		overrides[strings.ToLower(addr)] = target
+		d.overrides = overrides
+	})
+}
+
+// override returns the address replacing addr
+func (d *dialer) override(addr, host, port string) string {
+	if target, ok := d.overrides[strings.ToLower(addr)]; ok {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return net.JoinHostPort(target, port)
+		}
+		return target
+	}
+	if target, ok := d.overrides[strings.ToLower(host)]; ok {
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return net.JoinHostPort(target, port)
+		}
+		return target
+	}
+	return addr
+}
+
+// WithUnixSocket connects to the Unix domain socket at path for every
+// request, whatever the host of its URL, e.g. http://docker/v1.43/info
+// for the Docker daemon. Proxies are not used.
+func WithUnixSocket(path string) Option {
+	return withDialer(func(d *dialer) {
+		d.unixSocket = path
+		d.tr.Proxy = nil
+	})
+}
+
+// DialContext implements http.Transport.DialContext
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	ctx = dialingHost(ctx, addr)
+	conn, err := d.dial(ctx, network, addr)
+	if err != nil || d.stats == nil {
+		return conn, err
+	}
+	return &trackedConn{Conn: conn, stats: d.stats}, nil
+}
+
+func (d *dialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.unixSocket != "" {
+		return d.next(ctx, "unix", d.unixSocket)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.overrides) > 0 {
+		addr = d.override(addr, host, port)
+		if host, port, err = net.SplitHostPort(addr); err != nil {
+			return nil, err
+		}
+	}
+	if disc, ok := d.srv[strings.ToLower(host)]; ok {
+		return d.dialService(ctx, network, disc)
+	}
+	return d.dialHost(ctx, network, addr, host, port)
+}
+
+// dialService dials the endpoints of disc in turn
+func (d *dialer) dialService(ctx context.Context, network string, disc *SRVDiscovery) (net.Conn, error) {
+	targets, err := disc.Targets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var first error
+	for _, target := range targets {
+		host, port, _ := net.SplitHostPort(target)
+		conn, err := d.dialHost(ctx, network, target, host, port)
+		if err == nil {
+			return conn, nil
+		}
+		if first == nil {
+			first = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, first
+}
+
+// dialHost dials addr, resolving its host when the dialer has a resolver
+func (d *dialer) dialHost(ctx context.Context, network, addr, host, port string) (net.Conn, error) {
+	if _, err := netip.ParseAddr(host); err == nil || d.cache == nil && d.resolver == nil && d.family == familyAny {
+		return d.next(ctx, network, addr)
+	}
+	addrs, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if network == "tcp4" || d.family == familyIPv4Only {
+		network = "tcp4"
+	}
+	addrs = orderAddrs(addrs, network, d.family)
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no suitable address", Name: host, IsNotFound: true}
+	}
+	return d.dialParallel(ctx, network, addrs, port)
+}
+
+func (d *dialer) lookup(ctx context.Context, host string) ([]netip.Addr, error) {
+	if d.cache != nil {
+		return d.cache.LookupNetIP(ctx, host)
+	}
+	res := d.resolver
+	if res == nil {
+		res = SystemResolver{}
+	}
+	addrs, _, err := res.LookupNetIP(ctx, host)
+	return addrs, err
+}
+
+// Address family preferences of a dialer
+const (
+	familyAny = iota
+	familyIPv4Only
+	familyIPv6Preferred
+)
+
+// WithIPv4Only dials IPv4 addresses only, for destinations with broken
+// AAAA records
+func WithIPv4Only() Option {
+	return withDialer(func(d *dialer) {
+		d.family = familyIPv4Only
+	})
+}
+
+// WithIPv6Preferred tries IPv6 addresses first, whatever the order the
+// resolver returned
+func WithIPv6Preferred() Option {
+	return withDialer(func(d *dialer) {
+		d.family = familyIPv6Preferred
+	})
+}
+
+// WithHappyEyeballs sets the delay between connection attempts to the
+// addresses of a host, 250ms by default
+func WithHappyEyeballs(delay time.Duration) Option {
+	return withDialer(func(d *dialer) {
+		d.attemptDelay = delay
+	})
+}
+
+// orderAddrs keeps the addresses network can reach and alternates the
+// families as RFC 8305 asks, starting with the preferred one or that of
+// the first address
+func orderAddrs(addrs []netip.Addr, network string, family int) []netip.Addr {
+	var v4, v6 []netip.Addr
+	for _, a := range addrs {
+		a = a.Unmap()
+		switch {
+		case a.Is4() && network != "tcp6":
+			v4 = append(v4, a)
+		case a.Is6() && network != "tcp4":
+			v6 = append(v6, a)
+		}
+	}
+	first, second := v4, v6
+	if family == familyIPv6Preferred || family == familyAny && len(addrs) > 0 && addrs[0].Unmap().Is6() {
+		first, second = v6, v4
+	}
+	out := make([]netip.Addr, 0, len(v4)+len(v6))
+	for i := 0; i < len(first) || i < len(second); i++ {
+		if i < len(first) {
+			out = append(out, first[i])
+		}
+		if i < len(second) {
+			out = append(out, second[i])
+		}
+	}
+	return out
+}
+
+// dialParallel races connection attempts to addrs in order, starting
+// one every attempt delay or as soon as the previous one failed, and
+// returns the first connection established (RFC 8305)
+func (d *dialer) dialParallel(ctx context.Context, network string, addrs []netip.Addr, port string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	next, pending := 0, 0
+	start := func() {
+		addr := net.JoinHostPort(addrs[next].String(), port)
+		next++
+		pending++
+		go func() {
+			conn, err := d.next(ctx, network, addr)
+			results <- result{conn, err}
+		}()
+	}
+	delay := durationOr(d.attemptDelay, 250*time.Millisecond)
+
+	start()
+	timer := time.NewTimer(delay)
+	defer func() { timer.Stop() }()
+	var first error
+	for pending > 0 {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// attempts still running lose the race
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.conn != nil {
+							r.conn.Close()
+						}
+					}
+				}(pending)
+				return r.conn, nil
+			}
+			if first == nil {
+				first = r.err
+			}
+		case <-timer.C:
+		}
+		if next < len(addrs) {
+			start()
+			timer.Stop()
+			timer = time.NewTimer(delay)
+		}
+	}
+	if first == nil {
+		first = errors.New("netter: no addresses to dial")
+	}
+	return nil, first
+}
@@ -0,0 +1,508 @@
+package netgo
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers from a map and counts lookups
+type fakeResolver struct {
+	mu      sync.Mutex
+	hosts   map[string][]netip.Addr
+	srv     []*net.SRV
+	lookups map[string]int
+}
+
+func (r *fakeResolver) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lookups == nil {
+		r.lookups = make(map[string]int)
+	}
+	r.lookups[host]++
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, time.Minute, nil
+}
+
+// hostURL returns the URL of ts with its IP replaced by host
+func hostURL(ts *httptest.Server, host string) string {
+	u, _ := url.Parse(ts.URL)
+	u.Host = net.JoinHostPort(host, u.Port())
+	return u.String()
+}
+
+func TestDNSCache(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer ts.Close()
+
+	res := &fakeResolver{hosts: map[string][]netip.Addr{"api.test": {netip.MustParseAddr("127.0.0.1")}}}
+	cache := &DNSCache{Resolver: res}
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}), WithDNSCache(cache))
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(hostURL(ts, "api.test"))
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("get %d: %v %v", i, resp, err)
+		}
+		resp.Body.Close()
+		client.Inner.CloseIdleConnections()
+		if _, err := client.Get(hostURL(ts, "missing.test")); err == nil {
+			t.Fatal("missing host resolved")
+		}
+	}
+	if res.lookups["api.test"] != 1 || res.lookups["missing.test"] != 1 {
+		t.Fatalf("lookups %v, want one per name", res.lookups)
+	}
+	if s := cache.Stats(); s.Hits != 4 || s.Misses != 2 {
+		t.Fatalf("stats %+v", s)
+	}
+}
+
+// slowResolver answers once release is closed
+type slowResolver struct{ release chan struct{} }
+
+func (r slowResolver) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	<-r.release
+	return []netip.Addr{netip.MustParseAddr("127.0.0.1")}, 0, nil
+}
+
+func TestDNSCacheLookup(t *testing.T) {
+	res := slowResolver{make(chan struct{})}
+	cache := &DNSCache{Resolver: res, MinTTL: time.Millisecond, MaxTTL: time.Millisecond}
+
+	// the caller starting the lookup gives up with its context too
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.LookupNetIP(ctx, "a.test"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("lookup outlived its context: %v", err)
+	}
+	close(res.release)
+	if addrs, err := cache.LookupNetIP(context.Background(), "a.test"); err != nil || len(addrs) != 1 {
+		t.Fatalf("shared lookup: %v %v", addrs, err)
+	}
+
+	for _, host := range []string{"b.test", "c.test"} {
+		cache.LookupNetIP(context.Background(), host)
+	}
+	time.Sleep(5 * time.Millisecond)
+	cache.LookupNetIP(context.Background(), "d.test")
+	cache.mu.Lock()
+	n := len(cache.entries)
+	cache.mu.Unlock()
+	if n != 1 {
+		t.Fatalf("%d entries, expired ones not evicted", n)
+	}
+}
+
+// dnsAnswer answers a query for the A records in hosts
+func dnsAnswer(query []byte, hosts map[string]netip.Addr) []byte {
+	name, end, err := dnsName(query, 12)
+	if err != nil {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end:])
+	resp := append([]byte(nil), query[:end+4]...)
+	resp[2], resp[3] = 0x81, 0x80
+	if qtype == dnsTypeSRV && name == "_api._tcp.test" {
+		resp[7] = 1
+		resp = append(resp, 0xc0, 12, 0, dnsTypeSRV, 0, 1, 0, 0, 0, 60, 0, 14, 0, 1, 0, 2, 0x1f, 0x90)
+		return append(resp, 3, 'a', 'p', 'i', 4, 't', 'e', 's', 't', 0)
+	}
+	a, ok := hosts[name]
+	if !ok {
+		resp[3] |= 3
+		return resp
+	}
+	if qtype != dnsTypeA {
+		return resp
+	}
+	resp[7] = 1
+	ip := a.As4()
+	resp = append(resp, 0xc0, 12, 0, dnsTypeA, 0, 1, 0, 0, 0, 60, 0, 4)
+	return append(resp, ip[:]...)
+}
+
+func TestResolvers(t *testing.T) {
+	hosts := map[string]netip.Addr{"api.test": netip.MustParseAddr("127.0.0.1")}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(dnsAnswer(buf[:n], hosts), addr)
+		}
+	}()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var size [2]byte
+			io.ReadFull(conn, size[:])
+			query := make([]byte, binary.BigEndian.Uint16(size[:]))
+			io.ReadFull(conn, query)
+			resp := dnsAnswer(query, hosts)
+			binary.BigEndian.PutUint16(size[:], uint16(len(resp)))
+			conn.Write(append(size[:], resp...))
+			conn.Close()
+		}
+	}()
+
+	doh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		query, _ := ioutil.ReadAll(req.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(dnsAnswer(query, hosts))
+	}))
+	defer doh.Close()
+
+	resolvers := map[string]Resolver{
+		"udp": &DNSServerResolver{Addr: pc.LocalAddr().String()},
+		"tcp": &DNSServerResolver{Addr: ln.Addr().String(), TCP: true},
+		"doh": &DoHResolver{URL: doh.URL},
+	}
+	for name, r := range resolvers {
+		addrs, ttl, err := r.LookupNetIP(context.Background(), "api.test")
+		if err != nil || len(addrs) != 1 || addrs[0] != hosts["api.test"] || ttl != time.Minute {
+			t.Fatalf("%s: %v %v %v", name, addrs, ttl, err)
+		}
+		var dnsErr *net.DNSError
+		if _, _, err := r.LookupNetIP(context.Background(), "missing.test"); !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			t.Fatalf("%s: missing host: %v", name, err)
+		}
+		records, _, err := r.(SRVResolver).LookupSRV(context.Background(), "_api._tcp.test")
+		if err != nil || len(records) != 1 || *records[0] != (net.SRV{Target: "api.test.", Port: 8080, Priority: 1, Weight: 2}) {
+			t.Fatalf("%s: SRV %v %v", name, records, err)
+		}
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer ts.Close()
+	client := NewClient(WithTransport(ts.Client().Transport), WithResolver(resolvers["udp"]))
+	resp, err := client.Get(hostURL(ts, "api.test"))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("get: %v %v", resp, err)
+	}
+	resp.Body.Close()
+}
+
+func TestParseDNSResponse(t *testing.T) {
+	encode := func(name string) []byte {
+		var b []byte
+		for _, label := range strings.Split(name, ".") {
+			b = append(append(b, byte(len(label))), label...)
+		}
+		return append(b, 0)
+	}
+	record := func(owner string, typ uint16, data []byte) []byte {
+		b := append(encode(owner), byte(typ>>8), byte(typ), 0, 1, 0, 0, 0, 60, byte(len(data)>>8), byte(len(data)))
+		return append(b, data...)
+	}
+	answer := func(query []byte, records ...[]byte) []byte {
+		resp := append([]byte(nil), query...)
+		resp[2], resp[3], resp[7] = 0x81, 0x80, byte(len(records))
+		for _, r := range records {
+			resp = append(resp, r...)
+		}
+		return resp
+	}
+
+	query, _ := dnsQuery(7, "a.test", dnsTypeA)
+	resp, err := parseDNSResponse(answer(query,
+		record("evil.test", dnsTypeA, []byte{6, 6, 6, 6}),
+		record("c.test", dnsTypeA, []byte{1, 2, 3, 4}),
+		record("A.test", dnsTypeCNAME, encode("c.test")),
+	), 7, "a.test.", dnsTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addrs, _ := resp.addrs(); len(addrs) != 1 || addrs[0] != netip.MustParseAddr("1.2.3.4") {
+		t.Fatalf("addrs %v, want only the one the CNAME leads to", addrs)
+	}
+
+	other, _ := dnsQuery(7, "b.test", dnsTypeA)
+	for _, msg := range [][]byte{
+		answer(other, record("a.test", dnsTypeA, []byte{6, 6, 6, 6})),
+		answer(query[:len(query)-4]),
+	} {
+		if _, err := parseDNSResponse(msg, 7, "a.test", dnsTypeA); err == nil {
+			t.Fatalf("answer to another question accepted: %x", msg)
+		}
+	}
+	if _, err := parseDNSResponse(answer(query), 7, "a.test", dnsTypeAAAA); err == nil {
+		t.Fatal("answer for another type accepted")
+	}
+}
+
+func TestHostOverride(t *testing.T) {
+	var hosts []string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hosts = append(hosts, req.Host+" "+req.TLS.ServerName)
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}),
+		WithDialOverride("example.com:443", u.Host), WithDialOverride("other.example.com", "127.0.0.1"))
+	resp, err := client.Get("https://example.com/")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("get: %v %v", resp, err)
+	}
+	resp.Body.Close()
+	resp, err = client.Get("https://other.example.com:" + u.Port() + "/")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("get: %v %v", resp, err)
+	}
+	resp.Body.Close()
+	want := []string{"example.com example.com", "other.example.com:" + u.Port() + " other.example.com"}
+	if len(hosts) != 2 || hosts[0] != want[0] || hosts[1] != want[1] {
+		t.Fatalf("served %q, want %q", hosts, want)
+	}
+}
+
+func TestHappyEyeballs(t *testing.T) {
+	v4a, v4b := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("192.0.2.2")
+	v6a, v6b := netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("2001:db8::2")
+	cases := []struct {
+		family int
+		want   []netip.Addr
+	}{
+		{familyAny, []netip.Addr{v4a, v6a, v4b, v6b}},
+		{familyIPv6Preferred, []netip.Addr{v6a, v4a, v6b, v4b}},
+	}
+	for _, tc := range cases {
+		if got := orderAddrs([]netip.Addr{v4a, v4b, v6a, v6b}, "tcp", tc.family); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("family %d: %v, want %v", tc.family, got, tc.want)
+		}
+	}
+	if got := orderAddrs([]netip.Addr{v6a, v4a}, "tcp4", familyIPv4Only); !reflect.DeepEqual(got, []netip.Addr{v4a}) {
+		t.Errorf("IPv4 only: %v", got)
+	}
+
+	// a refusing first address falls back to the next one at once
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer ts.Close()
+	res := &fakeResolver{hosts: map[string][]netip.Addr{"api.test": {netip.MustParseAddr("127.0.0.2"), netip.MustParseAddr("127.0.0.1")}}}
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}), WithResolver(res),
+		WithHappyEyeballs(time.Minute))
+	start := time.Now()
+	resp, err := client.Get(hostURL(ts, "api.test"))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("get: %v %v", resp, err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("connected after %s", elapsed)
+	}
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups[name]++
+	return r.srv, time.Minute, nil
+}
+
+func TestSRVDiscovery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	res := &fakeResolver{
+		hosts: map[string][]netip.Addr{
+			"a.test": {netip.MustParseAddr("127.0.0.2")},
+			"b.test": {netip.MustParseAddr("127.0.0.1")},
+		},
+		lookups: map[string]int{},
+		srv: []*net.SRV{
+			{Target: "b.test.", Port: uint16(port), Priority: 20, Weight: 1},
+			{Target: "a.test.", Port: uint16(port), Priority: 10, Weight: 5},
+		},
+	}
+	disc := &SRVDiscovery{Name: "_api._tcp.service.test", Resolver: res}
+	targets, err := disc.Targets(context.Background())
+	if err != nil || len(targets) != 2 || targets[0] != "a.test:"+u.Port() {
+		t.Fatalf("targets %q %v", targets, err)
+	}
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}), WithResolver(res), WithSRV("api", disc))
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get("http://api/")
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("get: %v %v", resp, err)
+		}
+		resp.Body.Close()
+		client.Inner.CloseIdleConnections()
+	}
+	if res.lookups["_api._tcp.service.test"] != 1 || res.lookups["a.test"] != 2 {
+		t.Fatalf("lookups %v", res.lookups)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, req.URL.Path)
+	}))
+	ts.Listener = ln
+	ts.Start()
+	defer ts.Close()
+
+	client := NewClient(WithUnixSocket(sock), WithRetry(Retry{}))
+	client.BaseURL = "http://daemon"
+	resp, err := client.Get("/v1/info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "/v1/info" {
+		t.Fatalf("body %q", body)
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+	host := ts.Listener.Addr().String()
+
+	// keep-alive: one connection serves every request
+	client := NewClient(WithTransport(ts.Client().Transport), WithPoolStats(), WithRetry(Retry{}))
+	var open *http.Response
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i < 2 {
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		} else {
+			open = resp
+		}
+	}
+	want := PoolStats{Active: 1, New: 1, Reused: 2, Handshakes: 1}
+	if got := client.PoolStats()[host]; got != want {
+		t.Fatalf("stats %+v, want %+v", got, want)
+	}
+	ioutil.ReadAll(open.Body)
+	open.Body.Close()
+	want.Idle, want.Active = 1, 0
+	if got := client.PoolStats()[host]; got != want {
+		t.Fatalf("stats %+v, want %+v", got, want)
+	}
+
+	// the default transport closes every connection after its request
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	client = NewClient(WithRootCAPool(pool), WithPoolStats(), WithRetry(Retry{}))
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	want = PoolStats{New: 3, Handshakes: 3}
+	if got := client.PoolStats()[host]; got != want {
+		t.Fatalf("stats %+v, want %+v", got, want)
+	}
+	if NewClient().PoolStats() != nil {
+		t.Fatal("stats without WithPoolStats")
+	}
+	// options cloning the transport in between keep the dial state
+	if NewClient(WithPoolStats(), WithRootCAPool(pool), WithIPv4Only()).PoolStats() == nil {
+		t.Fatal("stats lost by later transport options")
+	}
+}
+
+func TestPreconnect(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+	host := ts.Listener.Addr().String()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	client := NewClient(WithKeepAlives(2), WithRootCAPool(pool), WithPoolStats(), WithRetry(Retry{}))
+	client.BaseURL = ts.URL
+	if err := client.Preconnect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := client.PoolStats()[host], (PoolStats{Idle: 1, New: 1, Handshakes: 1}); got != want {
+		t.Fatalf("after preconnect %+v, want %+v", got, want)
+	}
+	resp, err := client.Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if got := client.PoolStats()[host]; got.Reused != 1 || got.Handshakes != 1 {
+		t.Fatalf("request did not reuse the warm connection: %+v", got)
+	}
+
+	if err := client.Preconnect(context.Background(), "127.0.0.1:1", host); err == nil {
+		t.Fatal("expected an error for the closed port")
+	}
+	if err := NewClient().Preconnect(context.Background(), host); err == nil {
+		t.Fatal("expected an error without keep-alives")
+	}
+
+	// hosts go through the host policy like requests
+	restricted := NewClient(WithKeepAlives(2), WithRootCAPool(pool), WithPoolStats())
+	restricted.AllowedHosts = []string{"example.org"}
+	if err := restricted.Preconnect(context.Background(), ts.URL); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("preconnect to a host not allowed: %v", err)
+	}
+	// names are checked as they are dialed
+	restricted.AllowedHosts = []string{"10.0.0.0/8"}
+	_, port, _ := net.SplitHostPort(host)
+	if err := restricted.Preconnect(context.Background(), "localhost:"+port); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("preconnect to an address not allowed: %v", err)
+	}
+	if got := restricted.PoolStats()[host]; got != (PoolStats{}) {
+		t.Fatalf("connection opened despite the policy: %+v", got)
+	}
+}
@@ -0,0 +1,116 @@
+package netgo
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// DigestAuthenticator answers Digest challenges (RFC 7616) with
+// credentials looked up by host and realm. It supports the MD5, SHA-256
+// and SHA-512-256 algorithms and their -sess variants, qop=auth and
+// servers predating qop. Cached by ChallengeAuth, later requests count
+// up the nonce without another challenge.
+type DigestAuthenticator struct {
+	Credentials func(host, realm string) (user, pass string, ok bool)
+}
+
+// Scheme implements Authenticator
+func (DigestAuthenticator) Scheme() string { return "Digest" }
+
+// Authorize implements Authenticator
+func (a DigestAuthenticator) Authorize(req *http.Request, ch *Challenge, nc uint32) (string, error) {
+	user, pass, ok := a.Credentials(req.URL.Host, ch.Realm())
+	if !ok {
+		return "", errNoCredentials
+	}
+	algorithm := ch.Params["algorithm"]
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+	newHash := digestHash(strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS"))
+	if newHash == nil {
+		return "", fmt.Errorf("netter: unsupported digest algorithm %q", algorithm)
+	}
+	h := func(s string) string {
+		d := newHash()
+		d.Write([]byte(s))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	qop := ""
+	if q, ok := ch.Params["qop"]; ok {
+		for _, v := range strings.Split(q, ",") {
+			if strings.TrimSpace(v) == "auth" {
+				qop = "auth"
+			}
+		}
+		if qop == "" {
+			return "", fmt.Errorf("netter: unsupported digest qop %q", q)
+		}
+	}
+	realm, nonce := ch.Realm(), ch.Params["nonce"]
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(buf)
+	ncs := fmt.Sprintf("%08x", nc)
+
+	ha1 := h(user + ":" + realm + ":" + pass)
+	if strings.HasSuffix(strings.ToUpper(algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + nonce + ":" + cnonce)
+	}
+	uri := req.URL.RequestURI()
+	ha2 := h(req.Method + ":" + uri)
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ncs + ":" + cnonce + ":" + qop + ":" + ha2)
+	}
+
+	if strings.EqualFold(ch.Params["userhash"], "true") {
+		user = h(user + ":" + realm)
+	}
+	params := []string{
+		"username=" + quoteParam(user),
+		"realm=" + quoteParam(realm),
+		"nonce=" + quoteParam(nonce),
+		"uri=" + quoteParam(uri),
+		"algorithm=" + algorithm,
+		"response=" + quoteParam(response),
+	}
+	if opaque, ok := ch.Params["opaque"]; ok {
+		params = append(params, "opaque="+quoteParam(opaque))
+	}
+	if qop != "" {
+		params = append(params, "qop="+qop, "nc="+ncs, "cnonce="+quoteParam(cnonce))
+	}
+	if strings.EqualFold(ch.Params["userhash"], "true") {
+		params = append(params, "userhash=true")
+	}
+	return "Digest " + strings.Join(params, ", "), nil
+}
+
+func digestHash(algorithm string) func() hash.Hash {
+	switch algorithm {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	}
+	return nil
+}
+
+func quoteParam(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,180 @@
+package netgo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Resolver looks up the addresses of host names
+type Resolver interface {
+	// LookupNetIP returns the addresses of host and how long they may be
+	// cached, zero when the resolver does not know
+	LookupNetIP(ctx context.Context, host string) ([]netip.Addr, time.Duration, error)
+}
+
+// SystemResolver resolves through a net.Resolver, net.DefaultResolver
+// when nil. It reports no TTLs.
+type SystemResolver struct {
+	Resolver *net.Resolver
+}
+
+// LookupNetIP implements Resolver
+func (r SystemResolver) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	res := r.Resolver
+	if res == nil {
+		res = net.DefaultResolver
+	}
+	addrs, err := res.LookupNetIP(ctx, "ip", host)
+	for i, a := range addrs {
+		addrs[i] = a.Unmap()
+	}
+	return addrs, 0, err
+}
+
+// WithDNSCache resolves the host names the client dials through cache
+func WithDNSCache(cache *DNSCache) Option {
+	return withDialer(func(d *dialer) {
+		d.cache = cache
+	})
+}
+
+// DNSCache caches the lookups of its Resolver for their TTL, clamped to
+// MinTTL and MaxTTL, and remembers names that do not exist for
+// NegativeTTL. Concurrent lookups of one name share a query.
+type DNSCache struct {
+	// Resolver answers misses, a SystemResolver by default
+	Resolver Resolver
+	// MinTTL also applies to answers without TTL, 30s by default
+	MinTTL time.Duration
+	// MaxTTL is 5 minutes by default
+	MaxTTL time.Duration
+	// NegativeTTL is 5s by default
+	NegativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+
+	hits, misses int64
+}
+
+type dnsEntry struct {
+	addrs   []netip.Addr
+	err     error
+	expires time.Time
+	// done is closed once the lookup finished
+	done chan struct{}
+}
+
+// DNSCacheStats counts how lookups were answered by a DNSCache
+type DNSCacheStats struct {
+	Hits   int64
+	Misses int64
+}
+
+// HitRatio is the share of lookups answered from the cache
+func (s DNSCacheStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// Stats returns the counters of the cache since it was created
+func (c *DNSCache) Stats() DNSCacheStats {
+	return DNSCacheStats{Hits: atomic.LoadInt64(&c.hits), Misses: atomic.LoadInt64(&c.misses)}
+}
+
+// LookupNetIP returns the addresses of host, from the cache when possible
+func (c *DNSCache) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*dnsEntry)
+	}
+	e, ok := c.entries[host]
+	if ok && e.expired(time.Now()) {
+		ok = false
+	}
+	if ok {
+		atomic.AddInt64(&c.hits, 1)
+	} else {
+		c.evict()
+		e = &dnsEntry{done: make(chan struct{})}
+		c.entries[host] = e
+		atomic.AddInt64(&c.misses, 1)
+		// the lookup outlives a caller giving up, the others share it
+		go c.lookup(host, e)
+	}
+	c.mu.Unlock()
+	select {
+	case <-e.done:
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// expired tells whether e is a finished lookup past its TTL
+func (e *dnsEntry) expired(now time.Time) bool {
+	select {
+	case <-e.done:
+		return !now.Before(e.expires)
+	default:
+		return false
+	}
+}
+
+// evict drops the expired entries; c.mu must be held
+func (c *DNSCache) evict() {
+	now := time.Now()
+	for host, e := range c.entries {
+		if e.expired(now) {
+			delete(c.entries, host)
+		}
+	}
+}
+
+// lookup fills e, bounded by a timeout of its own as callers share it
+func (c *DNSCache) lookup(host string, e *dnsEntry) {
+	res := c.Resolver
+	if res == nil {
+		res = SystemResolver{}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	addrs, ttl, err := res.LookupNetIP(ctx, host)
+
+	now := time.Now()
+	var dnsErr *net.DNSError
+	switch {
+	case err == nil:
+		e.addrs, e.expires = addrs, now.Add(c.clampTTL(ttl))
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		e.err, e.expires = err, now.Add(durationOr(c.NegativeTTL, 5*time.Second))
+	default:
+		// transient failures are not cached
+		e.err = err
+	}
+	close(e.done)
+}
+
+func (c *DNSCache) clampTTL(ttl time.Duration) time.Duration {
+	if min := durationOr(c.MinTTL, 30*time.Second); ttl < min {
+		ttl = min
+	}
+	if max := durationOr(c.MaxTTL, 5*time.Minute); ttl > max {
+		ttl = max
+	}
+	return ttl
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
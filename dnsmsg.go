@@ -0,0 +1,199 @@
+package netgo
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// DNS record types queried by the resolvers
+const (
+	dnsTypeA     = 1
+	dnsTypeCNAME = 5
+	dnsTypeAAAA  = 28
+	dnsTypeSRV   = 33
+)
+
+var errDNSMessage = errors.New("netter: malformed DNS message")
+
+// dnsQuery builds a recursive query for name
+func dnsQuery(id uint16, name string, qtype uint16) ([]byte, error) {
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], 0x0100) // RD
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return nil, &net.DNSError{Err: "invalid name", Name: name}
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, byte(qtype>>8), byte(qtype), 0, 1)
+	return msg, nil
+}
+
+// dnsRecord is an answer record; data points into the message, which
+// names within it may refer to
+type dnsRecord struct {
+	typ  uint16
+	ttl  uint32
+	data []byte
+	// off is the offset of data in msg
+	off int
+	msg []byte
+}
+
+// dnsResponse is a parsed answer to a dnsQuery
+type dnsResponse struct {
+	rcode     int
+	truncated bool
+	answers   []dnsRecord
+}
+
+// parseDNSResponse parses the answer to the query id for name and
+// qtype. The question must echo the query; answers are kept for name
+// and the names its CNAME chain leads to, others are dropped.
+func parseDNSResponse(msg []byte, id uint16, name string, qtype uint16) (*dnsResponse, error) {
+	if len(msg) < 12 || binary.BigEndian.Uint16(msg) != id || msg[2]&0x80 == 0 {
+		return nil, errDNSMessage
+	}
+	resp := &dnsResponse{rcode: int(msg[3] & 0x0f), truncated: msg[2]&0x02 != 0}
+	qd, an := int(binary.BigEndian.Uint16(msg[4:])), int(binary.BigEndian.Uint16(msg[6:]))
+	name = dnsCanonical(name)
+	if qd != 1 {
+		return nil, errDNSMessage
+	}
+	qname, off, err := dnsName(msg, 12)
+	if err != nil {
+		return nil, err
+	}
+	if off+4 > len(msg) || dnsCanonical(qname) != name ||
+		binary.BigEndian.Uint16(msg[off:]) != qtype || binary.BigEndian.Uint16(msg[off+2:]) != 1 {
+		return nil, errDNSMessage
+	}
+	off += 4
+
+	var owners []string
+	for i := 0; i < an; i++ {
+		owner, next, err := dnsName(msg, off)
+		if err != nil {
+			return nil, err
+		}
+		off = next
+		if off+10 > len(msg) {
+			return nil, errDNSMessage
+		}
+		typ := binary.BigEndian.Uint16(msg[off:])
+		ttl := binary.BigEndian.Uint32(msg[off+4:])
+		n := int(binary.BigEndian.Uint16(msg[off+8:]))
+		off += 10
+		if off+n > len(msg) {
+			return nil, errDNSMessage
+		}
+		resp.answers = append(resp.answers, dnsRecord{typ: typ, ttl: ttl, data: msg[off : off+n], off: off, msg: msg})
+		owners = append(owners, dnsCanonical(owner))
+		off += n
+	}
+
+	// follow the CNAME chain from name, in whatever order it was sent
+	names := map[string]bool{name: true}
+	for grew := true; grew; {
+		grew = false
+		for i, rr := range resp.answers {
+			if rr.typ != dnsTypeCNAME || !names[owners[i]] {
+				continue
+			}
+			target, _, err := dnsName(msg, rr.off)
+			if err != nil {
+				return nil, err
+			}
+			if target = dnsCanonical(target); !names[target] {
+				names[target] = true
+				grew = true
+			}
+		}
+	}
+	answers := resp.answers[:0]
+	for i, rr := range resp.answers {
+		if names[owners[i]] {
+			answers = append(answers, rr)
+		}
+	}
+	resp.answers = answers
+	return resp, nil
+}
+
+// dnsCanonical lowercases name and drops its trailing dot
+func dnsCanonical(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// dnsName reads the possibly compressed name at off and returns the
+// offset following it
+func dnsName(msg []byte, off int) (string, int, error) {
+	var (
+		labels []string
+		next   = -1
+	)
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errDNSMessage
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) || jumps > 16 {
+				return "", 0, errDNSMessage
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errDNSMessage
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// addrs returns the A and AAAA answers and their lowest TTL
+func (r *dnsResponse) addrs() ([]netip.Addr, uint32) {
+	var (
+		out []netip.Addr
+		ttl uint32
+	)
+	for _, rr := range r.answers {
+		var (
+			a  netip.Addr
+			ok bool
+		)
+		switch rr.typ {
+		case dnsTypeA:
+			a, ok = netip.AddrFromSlice(rr.data)
+			ok = ok && len(rr.data) == 4
+		case dnsTypeAAAA:
+			a, ok = netip.AddrFromSlice(rr.data)
+			ok = ok && len(rr.data) == 16
+		}
+		if !ok {
+			continue
+		}
+		if len(out) == 0 || rr.ttl < ttl {
+			ttl = rr.ttl
+		}
+		out = append(out, a)
+	}
+	return out, ttl
+}
@@ -0,0 +1,245 @@
+package netgo
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Download streams the body of a GET of url into a temporary file next
+// to path, syncs it, checks it against Content-Length and Content-MD5
+// when sent, and renames it onto path. path is left untouched if any
+// step fails. It returns the number of bytes written.
+//
+// A body cut off midway is resumed with a Range request, guarded by
+// If-Range, as often as the retry policy allows, provided the response
+// carries a strong ETag or Last-Modified. Should the file have changed
+// meanwhile, the download starts over.
+func (c *Client) Download(ctx context.Context, url, path string, opts ...RequestOption) (int64, error) {
+	tmp, err := createTemp(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	d := &download{c: c, ctx: ctx, url: url, opts: opts, file: tmp}
+	n, err := d.run()
+	if err != nil {
+		return n, err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return n, err
+	}
+	if err := tmp.Close(); err != nil {
+		return n, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return n, err
+	}
+	tmp = nil
+	syncDir(filepath.Dir(path))
+	return n, nil
+}
+
+type download struct {
+	c    *Client
+	ctx  context.Context
+	url  string
+	opts []RequestOption
+	file *os.File
+
+	policy    *Retry
+	written   int64
+	total     int64
+	validator string
+	sumWant   string
+	sum       hash.Hash
+}
+
+// get requests the body from offset on
+func (d *download) get(offset int64) (*http.Response, error) {
+	req, err := NewRequestWithContext(d.ctx, "GET", d.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", d.validator)
+	}
+	resp, err := d.c.Do(req, d.opts...)
+	if d.policy == nil {
+		d.policy = d.c.retryPolicy(req)
+	}
+	return resp, err
+}
+
+// start takes the body of a full response from the beginning
+func (d *download) start(resp *http.Response) error {
+	if err := d.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	d.written = 0
+	d.total = resp.ContentLength
+	d.validator = ""
+	if !resp.Uncompressed {
+		if etag := resp.Header.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			d.validator = etag
+		} else if lm := resp.Header.Get("Last-Modified"); lm != "" {
+			d.validator = lm
+		}
+	}
+	d.sum = nil
+	d.sumWant = resp.Header.Get("Content-Md5")
+	if d.sumWant != "" && !resp.Uncompressed {
+		d.sum = md5.New()
+	}
+	return nil
+}
+
+// resume checks that a ranged response continues at the written offset
+func (d *download) resume(resp *http.Response) error {
+	cr := strings.TrimPrefix(resp.Header.Get("Content-Range"), "bytes ")
+	dash := strings.IndexByte(cr, '-')
+	if dash < 0 {
+		return fmt.Errorf("netter: download %s: bad Content-Range %q", d.url, cr)
+	}
+	if start, err := strconv.ParseInt(cr[:dash], 10, 64); err != nil || start != d.written {
+		return fmt.Errorf("netter: download %s: resumed at %q, want %d", d.url, cr, d.written)
+	}
+	if slash := strings.IndexByte(cr, '/'); slash >= 0 {
+		if total, err := strconv.ParseInt(cr[slash+1:], 10, 64); err == nil {
+			d.total = total
+		}
+	}
+	return nil
+}
+
+func (d *download) run() (int64, error) {
+	resp, err := d.get(0)
+	if err == nil {
+		err = CheckStatus(resp)
+	}
+	if err == nil {
+		err = d.start(resp)
+	}
+	if err != nil {
+		CloseBody(resp)
+		return 0, err
+	}
+
+	for i := 0; ; i++ {
+		w := &trackedWriter{w: d.file}
+		var dst io.Writer = w
+		if d.sum != nil {
+			dst = io.MultiWriter(w, d.sum)
+		}
+		n, err := io.Copy(dst, resp.Body)
+		resp.Body.Close()
+		d.written += n
+		if w.err != nil {
+			return d.written, w.err
+		}
+		if err == nil {
+			break
+		}
+		if d.validator == "" || i >= d.policy.Max || d.ctx.Err() != nil {
+			return d.written, err
+		}
+
+		wait := d.policy.backoff(d.policy.WaitMin, d.policy.WaitMax, i)
+		d.c.log().Printf("netter: download %s cut off at %d bytes: %v, resuming in %s", d.url, d.written, err, wait)
+		timer := d.c.clock().NewTimer(wait)
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return d.written, d.ctx.Err()
+		case <-timer.C():
+		}
+
+		if resp, err = d.get(d.written); err != nil {
+			return d.written, err
+		}
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+			err = d.resume(resp)
+		case http.StatusOK:
+			// If-Range did not match: the file changed
+			err = d.start(resp)
+		default:
+			err = CheckStatus(resp)
+			if err == nil {
+				err = fmt.Errorf("netter: download %s: unexpected status %s", d.url, resp.Status)
+			}
+		}
+		if err != nil {
+			CloseBody(resp)
+			return d.written, err
+		}
+	}
+
+	if d.total >= 0 && d.written != d.total {
+		return d.written, fmt.Errorf("netter: download %s: got %d of %d bytes", d.url, d.written, d.total)
+	}
+	if d.sum != nil {
+		if got := base64.StdEncoding.EncodeToString(d.sum.Sum(nil)); got != d.sumWant {
+			return d.written, fmt.Errorf("netter: download %s: Content-MD5 mismatch, got %s want %s", d.url, got, d.sumWant)
+		}
+	}
+	return d.written, nil
+}
+
+// trackedWriter tells write errors apart from read errors of io.Copy
+type trackedWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (t *trackedWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if err != nil {
+		t.err = err
+	}
+	return n, err
+}
+
+// createTemp creates the temporary file for path next to it. Unlike
+// os.CreateTemp, which creates files 0600, it leaves the permissions to
+// the umask, as os.Create does, so they survive the rename onto path.
+func createTemp(path string) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".")
+	for {
+		name := prefix + strconv.FormatUint(rand.Uint64(), 36) + ".tmp"
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666)
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+}
+
+// syncDir makes a rename in dir durable where the platform allows it
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
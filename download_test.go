@@ -0,0 +1,157 @@
+package netgo
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownload(t *testing.T) {
+	payload := []byte("artifact contents")
+	sum := md5.Sum(payload)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/bad" {
+			w.Header().Set("Content-MD5", "AAAAAAAAAAAAAAAAAAAAAA==")
+		} else {
+			w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+		}
+		w.Write(payload)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "artifact")
+	client := NewClient(WithTransport(ts.Client().Transport))
+
+	n, err := client.Download(context.Background(), ts.URL+"/ok", path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if b, _ := ioutil.ReadFile(path); n != int64(len(payload)) || string(b) != string(payload) {
+		t.Fatalf("bad file: %d %q", n, b)
+	}
+	// permissions follow the umask like any created file
+	ref, err := os.Create(filepath.Join(dir, "ref"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.Close()
+	want, _ := os.Stat(ref.Name())
+	os.Remove(ref.Name())
+	if got, _ := os.Stat(path); got.Mode() != want.Mode() {
+		t.Fatalf("mode %v, want %v", got.Mode(), want.Mode())
+	}
+
+	if _, err := client.Download(context.Background(), ts.URL+"/bad", path+".2"); err == nil {
+		t.Fatal("checksum mismatch not detected")
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Fatal("failed download left a file")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("temp files left behind: %v", entries)
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	payload := []byte(strings.Repeat("0123456789", 1000))
+	var ranges []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		rng := req.Header.Get("Range")
+		ranges = append(ranges, rng)
+		if rng == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.Write(payload[:4000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		if req.Header.Get("If-Range") != `"v1"` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var start int
+		fmt.Sscanf(rng, "bytes=%d-", &start)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(payload)-1, len(payload)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(payload[start:])
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "artifact")
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	n, err := client.Download(context.Background(), ts.URL, path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if b, _ := ioutil.ReadFile(path); n != int64(len(payload)) || string(b) != string(payload) {
+		t.Fatalf("bad file: %d bytes", n)
+	}
+	if len(ranges) != 2 || ranges[1] != "bytes=4000-" {
+		t.Fatalf("bad ranges: %q", ranges)
+	}
+}
+
+func TestParallelDownload(t *testing.T) {
+	payload := []byte(strings.Repeat("abcdefghij", 1000))
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		rng := req.Header.Get("Range")
+		mu.Lock()
+		first := !failed[rng]
+		failed[rng] = true
+		mu.Unlock()
+		if req.URL.Path == "/unversioned" {
+			mu.Lock()
+			failed[req.URL.Path+rng] = true
+			mu.Unlock()
+			http.ServeContent(w, req, "f", time.Time{}, bytes.NewReader(payload))
+			return
+		}
+		w.Header().Set("Etag", `"v1"`)
+		if rng == "bytes=3000-4999" && first {
+			// cut the body of one chunk short once
+			w.Header().Set("Content-Range", "bytes 3000-4999/10000")
+			w.Header().Set("Content-Length", "2000")
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(payload[3000:3500])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, req, "f", time.Time{}, bytes.NewReader(payload))
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "artifact")
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	p := &ParallelDownload{ChunkSize: 1000, Parallelism: 3}
+	n, err := p.Download(context.Background(), client, ts.URL, path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if b, _ := ioutil.ReadFile(path); n != int64(len(payload)) || !bytes.Equal(b, payload) {
+		t.Fatalf("bad file: %d bytes", n)
+	}
+
+	// without a validator the ranges could mix versions of the file
+	n, err = p.Download(context.Background(), client, ts.URL+"/unversioned", path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if n != int64(len(payload)) || !failed["/unversioned"] || failed["/unversionedbytes=1000-1999"] {
+		t.Fatalf("unversioned file fetched in ranges: %v", failed)
+	}
+}
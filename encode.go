@@ -0,0 +1,202 @@
+package netgo
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EncodeForm encodes the exported fields of struct v as form values.
+// Field names come from the `form:"name,omitempty"` tag, or the field
+// name when untagged; "-" skips a field. Slices become repeated values,
+// time.Time is written as RFC 3339 and embedded structs are flattened.
+//
+// Tag options change the formatting: "comma", "space" and "semicolon"
+// join slices into one value, "brackets" names repeated values name[]
+// and "numbered" name0, name1 and so on. Times take "unix", "unixmilli"
+// or a layout from a separate `layout:"2006-01-02"` tag.
+func EncodeForm(v interface{}) (url.Values, error) {
+	return encodeValues(v, "form")
+}
+
+func encodeValues(v interface{}, tag string) (url.Values, error) {
+	values := url.Values{}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("netter: cannot encode %T as %s values", v, tag)
+	}
+	return values, encodeStruct(values, rv, tag)
+}
+
+func encodeStruct(values url.Values, rv reflect.Value, tag string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fv := rv.Field(i)
+
+		name, opts := field.Name, ""
+		if t, ok := field.Tag.Lookup(tag); ok {
+			if t == "-" {
+				continue
+			}
+			name, opts = t, ""
+			if j := strings.IndexByte(t, ','); j >= 0 {
+				name, opts = t[:j], t[j+1:]
+			}
+			if name == "" {
+				name = field.Name
+			}
+		}
+
+		if field.Anonymous && fv.Kind() == reflect.Struct && !hasTag(field, tag) {
+			if err := encodeStruct(values, fv, tag); err != nil {
+				return err
+			}
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		omitempty := hasOption(opts, "omitempty")
+		if omitempty && fv.IsZero() {
+			continue
+		}
+		for fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Ptr {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			if _, ok := fv.Interface().([]byte); !ok {
+				if err := encodeSlice(values, name, fv, field, opts); err != nil {
+					return fmt.Errorf("netter: field %s: %v", field.Name, err)
+				}
+				continue
+			}
+		}
+		s, err := formatField(fv, field, opts)
+		if err != nil {
+			return fmt.Errorf("netter: field %s: %v", field.Name, err)
+		}
+		values.Add(name, s)
+	}
+	return nil
+}
+
+func encodeSlice(values url.Values, name string, fv reflect.Value, field reflect.StructField, opts string) error {
+	items := make([]string, fv.Len())
+	for j := range items {
+		s, err := formatField(fv.Index(j), field, opts)
+		if err != nil {
+			return err
+		}
+		items[j] = s
+	}
+
+	sep := ""
+	switch {
+	case hasOption(opts, "comma"):
+		sep = ","
+	case hasOption(opts, "space"):
+		sep = " "
+	case hasOption(opts, "semicolon"):
+		sep = ";"
+	}
+	switch {
+	case sep != "":
+		if len(items) > 0 {
+			values.Add(name, strings.Join(items, sep))
+		}
+	case hasOption(opts, "brackets"):
+		values[name+"[]"] = append(values[name+"[]"], items...)
+	case hasOption(opts, "numbered"):
+		for j, s := range items {
+			values.Add(name+strconv.Itoa(j), s)
+		}
+	default:
+		values[name] = append(values[name], items...)
+	}
+	return nil
+}
+
+// formatField formats v honouring the time options of its field
+func formatField(v reflect.Value, field reflect.StructField, opts string) (string, error) {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if t, ok := v.Interface().(time.Time); ok {
+		switch {
+		case hasOption(opts, "unix"):
+			return strconv.FormatInt(t.Unix(), 10), nil
+		case hasOption(opts, "unixmilli"):
+			return strconv.FormatInt(t.UnixMilli(), 10), nil
+		}
+		if layout := field.Tag.Get("layout"); layout != "" {
+			return t.Format(layout), nil
+		}
+	}
+	return formatValue(v)
+}
+
+func hasTag(field reflect.StructField, tag string) bool {
+	_, ok := field.Tag.Lookup(tag)
+	return ok
+}
+
+func hasOption(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+func formatValue(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return x.Format(time.RFC3339), nil
+	case []byte:
+		return string(x), nil
+	case encoding.TextMarshaler:
+		b, err := x.MarshalText()
+		return string(b), err
+	case fmt.Stringer:
+		return x.String(), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("unsupported type %s", v.Type())
+}
@@ -0,0 +1,95 @@
+package netgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type formBase struct {
+	Token string `form:"token"`
+}
+
+type signupForm struct {
+	formBase
+	Name    string    `form:"name"`
+	Age     int       `form:"age,omitempty"`
+	Tags    []string  `form:"tag"`
+	Born    time.Time `form:"born"`
+	Nick    *string   `form:"nick,omitempty"`
+	Secret  string    `form:"-"`
+	Remarks string
+}
+
+func TestEncodeForm(t *testing.T) {
+	v, err := EncodeForm(&signupForm{
+		formBase: formBase{Token: "t"},
+		Name:     "ann",
+		Tags:     []string{"a", "b"},
+		Born:     time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC),
+		Secret:   "s",
+		Remarks:  "r",
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	want := "Remarks=r&born=2000-01-02T03%3A04%3A05Z&name=ann&tag=a&tag=b&token=t"
+	if got := v.Encode(); got != want {
+		t.Fatalf("bad form:\n got %s\nwant %s", got, want)
+	}
+
+	if _, err := EncodeForm("nope"); err == nil {
+		t.Fatal("expected error for non-struct")
+	}
+}
+
+func TestClientPostForm(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil || req.PostForm.Get("name") != "ann" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	form, _ := EncodeForm(signupForm{Name: "ann"})
+	resp, err := NewClient(WithTransport(ts.Client().Transport)).PostForm(ts.URL, form)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("form not decoded by server: %d", resp.StatusCode)
+	}
+}
+
+type listQuery struct {
+	Page   int       `query:"page,omitempty"`
+	IDs    []int     `query:"ids,comma"`
+	Sort   []string  `query:"sort,brackets"`
+	Since  time.Time `query:"since,unix"`
+	Before time.Time `query:"before" layout:"2006-01-02"`
+}
+
+func TestWithQueryStruct(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Query", req.URL.RawQuery)
+	}))
+	defer ts.Close()
+
+	day := time.Date(2020, 5, 6, 0, 0, 0, 0, time.UTC)
+	q := listQuery{IDs: []int{1, 2}, Sort: []string{"name"}, Since: day, Before: day}
+	resp, err := NewClient(WithTransport(ts.Client().Transport)).Get(ts.URL+"?x=1", WithQueryStruct(q))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	want := "before=2020-05-06&ids=1%2C2&since=1588723200&sort%5B%5D=name&x=1"
+	if got := resp.Header.Get("X-Query"); got != want {
+		t.Fatalf("bad query:\n got %s\nwant %s", got, want)
+	}
+
+	if _, err := Get(ts.URL, WithQueryStruct(42)); err == nil {
+		t.Fatal("expected error for non-struct")
+	}
+}
@@ -0,0 +1,95 @@
+package netgo
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContentDecoder unwraps a response body sent with a content encoding
+type ContentDecoder func(r io.Reader) (io.ReadCloser, error)
+
+var (
+	contentDecodersMu sync.RWMutex
+	contentDecoders   = map[string]ContentDecoder{
+		"gzip": func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	}
+)
+
+// RegisterContentDecoder adds a content encoding for clients with
+// Decompress set, or removes it when d is nil. Importing the netgozstd
+// or netgobrotli package registers zstd or br, keeping their
+// dependencies out of programs not needing them.
+func RegisterContentDecoder(encoding string, d ContentDecoder) {
+	contentDecodersMu.Lock()
+	defer contentDecodersMu.Unlock()
+	if d == nil {
+		delete(contentDecoders, strings.ToLower(encoding))
+		return
+	}
+	contentDecoders[strings.ToLower(encoding)] = d
+}
+
+// acceptEncoding lists the registered encodings, preferring the
+// non-gzip ones, which are better compressors
+func acceptEncoding() string {
+	contentDecodersMu.RLock()
+	defer contentDecodersMu.RUnlock()
+	names := make([]string, 0, len(contentDecoders))
+	for name := range contentDecoders {
+		if name != "gzip" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(append(names, "gzip"), ", ")
+}
+
+// advertiseEncodings sets Accept-Encoding unless the request chose one
+func advertiseEncodings(req *Request) {
+	if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding())
+	}
+}
+
+// decodeContent replaces an encoded body of resp by its decoded form
+func decodeContent(resp *http.Response) error {
+	if resp == nil || resp.Body == nil || resp.Body == http.NoBody {
+		return nil
+	}
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if enc == "" || enc == "identity" {
+		return nil
+	}
+	contentDecodersMu.RLock()
+	d, ok := contentDecoders[enc]
+	contentDecodersMu.RUnlock()
+	if !ok {
+		return nil
+	}
+	r, err := d(resp.Body)
+	if err != nil {
+		CloseBody(resp)
+		return err
+	}
+	resp.Body = &decodedBody{ReadCloser: r, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody closes both the decoder and the raw body
+type decodedBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (b *decodedBody) Close() error {
+	b.ReadCloser.Close()
+	return b.raw.Close()
+}
@@ -0,0 +1,91 @@
+package netgo
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ContentTypeError is returned for a response of an unexpected media type
+type ContentTypeError struct {
+	Got  string
+	Want []string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("netter: unexpected content type %q, want %s", e.Got, strings.Join(e.Want, " or "))
+}
+
+// ExpectStatus returns an *HTTPError, consuming and closing the body,
+// unless the response status is one of codes
+func ExpectStatus(resp *http.Response, codes ...int) error {
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	return newHTTPError(resp)
+}
+
+// ExpectContentType returns a *ContentTypeError unless the media type of
+// the response is one of mediaTypes; parameters such as charset are
+// ignored
+func ExpectContentType(resp *http.Response, mediaTypes ...string) error {
+	got := resp.Header.Get("Content-Type")
+	mt, _, err := mime.ParseMediaType(got)
+	if err == nil {
+		for _, want := range mediaTypes {
+			if strings.EqualFold(mt, want) {
+				return nil
+			}
+		}
+	}
+	return &ContentTypeError{Got: got, Want: mediaTypes}
+}
+
+// ResponseCheck validates a response before Do returns it
+type ResponseCheck func(*http.Response) error
+
+// StatusIn is the ResponseCheck form of ExpectStatus
+func StatusIn(codes ...int) ResponseCheck {
+	return func(resp *http.Response) error {
+		return ExpectStatus(resp, codes...)
+	}
+}
+
+// ContentTypeIn is the ResponseCheck form of ExpectContentType
+func ContentTypeIn(mediaTypes ...string) ResponseCheck {
+	return func(resp *http.Response) error {
+		return ExpectContentType(resp, mediaTypes...)
+	}
+}
+
+// WithResponseCheck makes Do run checks, in order, on the final response
+// and return the first error. The response is returned as well; an
+// *HTTPError leaves its captured body readable, other failures an empty
+// one.
+func WithResponseCheck(checks ...ResponseCheck) RequestOption {
+	return func(r *Request) {
+		r.checks = append(r.checks, checks...)
+	}
+}
+
+func runChecks(resp *http.Response, checks []ResponseCheck) error {
+	for _, check := range checks {
+		err := check(resp)
+		if err == nil {
+			continue
+		}
+		if e, ok := err.(*HTTPError); ok {
+			resp.Body = ioutil.NopCloser(bytes.NewReader(e.Body))
+		} else {
+			CloseBody(resp)
+			resp.Body = http.NoBody
+		}
+		return err
+	}
+	return nil
+}
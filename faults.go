@@ -0,0 +1,75 @@
+package netgo
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInjectedFault is returned for attempts dropped by a FaultInjector
+var ErrInjectedFault = errors.New("netter: injected fault")
+
+// FaultInjector randomly delays, drops or fails attempts inside the
+// client, to exercise callers' resilience in staging. Fractions are
+// independent probabilities from 0 to 1.
+type FaultInjector struct {
+	// DelayFraction of attempts are held back for Delay before sending
+	DelayFraction float64
+	Delay         time.Duration
+	// DropFraction of attempts are never sent and fail with ErrInjectedFault
+	DropFraction float64
+	// ErrorFraction of attempts are answered locally with ErrorStatus,
+	// 503 by default
+	ErrorFraction float64
+	ErrorStatus   int
+	// Rand returns numbers in [0, 1), math/rand by default
+	Rand func() float64
+}
+
+func (f *FaultInjector) roll(fraction float64) bool {
+	if fraction <= 0 {
+		return false
+	}
+	r := rand.Float64
+	if f.Rand != nil {
+		r = f.Rand
+	}
+	return r() < fraction
+}
+
+// inject returns a synthesized outcome for req, or false to send it
+func (f *FaultInjector) inject(req *http.Request) (*http.Response, bool, error) {
+	if f.roll(f.DelayFraction) {
+		timer := time.NewTimer(f.Delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, true, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	if f.roll(f.DropFraction) {
+		return nil, true, ErrInjectedFault
+	}
+	if f.roll(f.ErrorFraction) {
+		code := f.ErrorStatus
+		if code == 0 {
+			code = http.StatusServiceUnavailable
+		}
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+			StatusCode: code,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{"X-Netgo-Fault": {"injected"}},
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+			Request:    req,
+		}, true, nil
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,58 @@
+package netgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFaultInjector(t *testing.T) {
+	var hits int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+	}))
+	defer ts.Close()
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}))
+	// attempt errors come back as they are
+	client.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) { return false, nil }
+
+	client.Faults = &FaultInjector{DropFraction: 1}
+	if _, err := client.Get(ts.URL); !errors.Is(err, ErrInjectedFault) {
+		t.Fatalf("drop: %v", err)
+	}
+
+	client.Faults = &FaultInjector{ErrorFraction: 1, ErrorStatus: http.StatusTooManyRequests}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("X-Netgo-Fault") != "injected" {
+		t.Fatalf("error: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	client.Faults = &FaultInjector{DelayFraction: 1, Delay: time.Hour}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("delay: %v", err)
+	}
+	if hits != 0 {
+		t.Fatalf("%d faulty attempts reached the server", hits)
+	}
+
+	// rolls at or above a fraction leave the attempt alone
+	client.Faults = &FaultInjector{DelayFraction: 0.5, DropFraction: 0.5, ErrorFraction: 0.5, Rand: func() float64 { return 0.5 }}
+	resp, err = client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || hits != 1 {
+		t.Fatalf("unfaulted attempt: %d, %d hits", resp.StatusCode, hits)
+	}
+}
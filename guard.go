@@ -0,0 +1,96 @@
+package netgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"syscall"
+)
+
+// ErrBlockedDestination is matched by errors.Is for connections refused
+// by a DialGuard
+var ErrBlockedDestination = errors.New("netter: destination blocked")
+
+// blockedRanges are private, loopback, link-local (cloud metadata
+// included) and otherwise non-public networks
+var blockedRanges = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// DialGuard refuses connections to private, loopback, link-local and
+// metadata addresses. It checks the address actually dialed, after DNS
+// resolution, so rebinding a name to an internal address does not get
+// past it. Behind a proxy the proxy address is checked, which then has
+// to be allowed.
+type DialGuard struct {
+	// Allow lists networks reachable even though blocked
+	Allow []netip.Prefix
+	// Block lists networks refused in addition to the default ones
+	Block []netip.Prefix
+}
+
+func (g *DialGuard) blocked(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, p := range g.Allow {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	for _, p := range blockedRanges {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	for _, p := range g.Block {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Control implements net.Dialer.Control
+func (g *DialGuard) Control(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrBlockedDestination, address, err)
+	}
+	if g.blocked(ap.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedDestination, address)
+	}
+	return nil
+}
+
+// WithDialGuard makes the client transport dial through g. Host
+// overrides, resolvers and discovery set up by other options still
+// apply, whatever their order; the addresses they produce are dialed by
+// a guarded dialer using the default timeouts.
+func WithDialGuard(g *DialGuard) Option {
+	return withDialer(func(d *dialer) {
+		guarded := *baseDialer
+		guarded.ControlContext = func(ctx context.Context, network, address string, conn syscall.RawConn) error {
+			if err := g.Control(network, address, conn); err != nil {
+				return err
+			}
+			return controlHostPolicy(ctx, network, address, conn)
+		}
+		d.next, d.checksHosts = guarded.DialContext, true
+	})
+}
@@ -0,0 +1,48 @@
+package netgo
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestDialGuard(t *testing.T) {
+	g := &DialGuard{}
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "169.254.169.254", "::1", "::ffff:192.168.0.1", "fd00:ec2::254"} {
+		if !g.blocked(netip.MustParseAddr(addr)) {
+			t.Fatalf("%s should be blocked", addr)
+		}
+	}
+	if g.blocked(netip.MustParseAddr("93.184.216.34")) {
+		t.Fatal("public address blocked")
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer ts.Close()
+
+	client := NewClient(WithDialGuard(g))
+	if _, err := client.Get(ts.URL); !errors.Is(err, ErrBlockedDestination) {
+		t.Fatalf("loopback not blocked: %v", err)
+	}
+
+	allowed := NewClient(WithDialGuard(&DialGuard{Allow: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}}))
+	resp, err := allowed.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+
+	// the guard keeps host overrides applied before or after it
+	guard := WithDialGuard(&DialGuard{Allow: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}})
+	override := WithDialOverride("api.internal", ts.Listener.Addr().String())
+	for _, opts := range [][]Option{{override, guard}, {guard, override}} {
+		client := NewClient(append(opts, WithRetry(Retry{}))...)
+		resp, err := client.Get("http://api.internal/")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		resp.Body.Close()
+	}
+}
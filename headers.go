@@ -0,0 +1,23 @@
+package netgo
+
+import (
+	"net/http"
+)
+
+// applyHeaders sets the client default headers that req does not set
+func (c *Client) applyHeaders(req *Request) {
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	for k, vs := range c.Headers {
+		k = http.CanonicalHeaderKey(k)
+		if _, ok := req.Header[k]; !ok {
+			req.Header[k] = append([]string(nil), vs...)
+		}
+	}
+	if c.UserAgent != "" {
+		if _, ok := req.Header["User-Agent"]; !ok {
+			req.Header.Set("User-Agent", c.UserAgent)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package netgo
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Hedge sends a backup attempt when an in-flight request to a host
+// takes longer than the given percentile of its recent latencies.
+// Only safe methods (GET, HEAD, OPTIONS) are hedged.
+type Hedge struct {
+	// Percentile of observed latency, e.g. 0.99 for p99
+	Percentile float64
+	// MinSamples required per host before hedging starts
+	MinSamples int
+	// Window is the number of latest samples kept per host
+	Window int
+
+	mu    sync.Mutex
+	hosts map[string]*latencyWindow
+}
+
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+	full    bool
+}
+
+func (h *Hedge) window() int {
+	if h.Window <= 0 {
+		return 256
+	}
+	return h.Window
+}
+
+func (h *Hedge) observe(host string, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.hosts == nil {
+		h.hosts = make(map[string]*latencyWindow)
+	}
+	w, ok := h.hosts[host]
+	if !ok {
+		w = &latencyWindow{samples: make([]time.Duration, h.window())}
+		h.hosts[host] = w
+	}
+	w.samples[w.next] = d
+	w.next++
+	if w.next == len(w.samples) {
+		w.next = 0
+		w.full = true
+	}
+}
+
+// threshold returns the hedging delay for host, false while there
+// are not enough samples yet
+func (h *Hedge) threshold(host string) (time.Duration, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w, ok := h.hosts[host]
+	if !ok {
+		return 0, false
+	}
+	n := w.next
+	if w.full {
+		n = len(w.samples)
+	}
+	if n == 0 || n < h.MinSamples {
+		return 0, false
+	}
+
+	sorted := make([]time.Duration, n)
+	copy(sorted, w.samples[:n])
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	p := h.Percentile
+	if p <= 0 || p > 1 {
+		p = 0.99
+	}
+	idx := int(p*float64(n)+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= n {
+		idx = n - 1
+	}
+	return sorted[idx], true
+}
+
+func hedgeable(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+type hedgeResult struct {
+	resp *http.Response
+	err  error
+	idx  int
+}
+
+// hedged runs the attempt and, once the host's latency threshold passes
+// without an answer, a backup attempt; the first success wins. Every
+// attempt gets its own copy of the request, as attempts write headers.
+func (c *Client) hedged(req *Request) (*http.Response, error) {
+	host := req.URL.Host
+	delay, ok := c.Hedge.threshold(host)
+	if !ok || !hedgeable(req.Method) {
+		start := time.Now()
+		resp, err := c.attempt(req, req.Request)
+		if hedgeWon(resp, err) {
+			c.Hedge.observe(host, time.Since(start))
+		}
+		return resp, err
+	}
+
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	launch := func(r *http.Request) {
+		ctx, cancel := context.WithCancel(req.Context())
+		idx := len(cancels)
+		cancels = append(cancels, cancel)
+		r = r.Clone(ctx)
+		go func() {
+			start := time.Now()
+			resp, err := c.attempt(req, r)
+			if hedgeWon(resp, err) {
+				c.Hedge.observe(host, time.Since(start))
+			}
+			results <- hedgeResult{resp, err, idx}
+		}()
+	}
+
+	launch(req.Request)
+	pending := 1
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var last hedgeResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			backup := req.Request.Clone(req.Context())
+			if req.body != nil {
+				body, err := req.body()
+				if err != nil {
+					continue
+				}
+				backup.Body = toReadCloser(body)
+			}
+			c.logger(req).Printf("netter: %s slower than %s, sending backup request", req.URL, delay)
+			launch(backup)
+			pending++
+		case res := <-results:
+			pending--
+			if !hedgeWon(res.resp, res.err) {
+				// kept for when no attempt succeeds
+				if last.resp != nil {
+					c.drainBody(last.resp.Body)
+					cancels[last.idx]()
+				}
+				if res.err != nil {
+					cancels[res.idx]()
+				}
+				last = res
+				continue
+			}
+			for i, cancel := range cancels {
+				if i != res.idx {
+					cancel()
+				}
+			}
+			if last.resp != nil {
+				c.drainBody(last.resp.Body)
+			}
+			if pending > 0 {
+				go c.discardHedge(results, pending)
+			}
+			res.resp.Body = &cancelBody{res.resp.Body, cancels[res.idx]}
+			return res.resp, nil
+		}
+	}
+	if last.resp != nil {
+		last.resp.Body = &cancelBody{last.resp.Body, cancels[last.idx]}
+	}
+	return last.resp, last.err
+}
+
+// hedgeWon tells whether an attempt may win the race: errors and server
+// errors leave it to the other attempt
+func hedgeWon(resp *http.Response, err error) bool {
+	return err == nil && resp.StatusCode < http.StatusInternalServerError
+}
+
+// discardHedge cleans up attempts that lost the race
+func (c *Client) discardHedge(results chan hedgeResult, pending int) {
+	for ; pending > 0; pending-- {
+		if res := <-results; res.resp != nil {
+			c.drainBody(res.resp.Body)
+		}
+	}
+}
+
+// cancelBody releases the attempt context when the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
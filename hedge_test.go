@@ -0,0 +1,101 @@
+package netgo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgeThreshold(t *testing.T) {
+	h := &Hedge{Percentile: 0.9, MinSamples: 10}
+	if _, ok := h.threshold("a"); ok {
+		t.Fatal("should not hedge without samples")
+	}
+	for i := 1; i <= 10; i++ {
+		h.observe("a", time.Duration(i)*time.Millisecond)
+	}
+	d, ok := h.threshold("a")
+	if !ok || d != 9*time.Millisecond {
+		t.Fatalf("bad threshold: %v %v", d, ok)
+	}
+}
+
+func TestClientHedge(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&n, 1) == 2 {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger, Hedge: &Hedge{MinSamples: 1}}
+
+	for i := 0; i < 2; i++ {
+		start := time.Now()
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "ok" {
+			t.Fatalf("bad body: %q", body)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("backup request was not sent")
+		}
+	}
+	if atomic.LoadInt32(&n) != 3 {
+		t.Fatalf("expected 3 server hits, got %d", n)
+	}
+}
+
+func TestClientHedgeServerError(t *testing.T) {
+	var n int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch atomic.AddInt32(&n, 1) {
+		case 2:
+			// the primary answers late with a server error
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusBadGateway)
+		case 3:
+			time.Sleep(300 * time.Millisecond)
+			w.Write([]byte("backup"))
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}), WithDefaultBearerToken("secret"))
+	client.Hedge = &Hedge{MinSamples: 1}
+	client.DeadlineHeader = &DeadlineHeader{Name: "X-Deadline"}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, want := range []string{"ok", "backup"} {
+		req, _ := NewRequestWithContext(ctx, "GET", ts.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Fatalf("got %d %q, want %q", resp.StatusCode, body, want)
+		}
+	}
+	// the server error is no latency sample
+	if w := client.Hedge.hosts[ts.Listener.Addr().String()]; w.next != 2 {
+		t.Fatalf("%d latency samples, want 2", w.next)
+	}
+}
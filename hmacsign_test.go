@@ -0,0 +1,51 @@
+package netgo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHMACSigner(t *testing.T) {
+	key := []byte("secret")
+	var nonces []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		digest := sha256.Sum256(body)
+		signed := strings.Join([]string{req.Method, req.URL.RequestURI(), req.Header.Get("X-Timestamp"),
+			req.Header.Get("X-Nonce"), hex.EncodeToString(digest[:])}, "\n")
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		if req.Header.Get("Webhook-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		nonces = append(nonces, req.Header.Get("X-Nonce"))
+		if len(nonces) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	s := &HMACSigner{
+		Key:    key,
+		Header: "Webhook-Signature",
+		Prefix: "sha256=",
+		Nonces: &NonceGenerator{Store: NewMemoryNonceStore()},
+	}
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	client.Use(AroundAttempt, s.Middleware())
+	resp, err := client.Post(ts.URL+"/hook?a=1", "application/json", `{"event":"ping"}`)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("post: %v %v", resp, err)
+	}
+	if len(nonces) != 2 || nonces[0] == nonces[1] || nonces[0] == "" {
+		t.Fatalf("nonces %q, want two distinct", nonces)
+	}
+}
@@ -0,0 +1,62 @@
+package netgo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+)
+
+// HookError reports a panic recovered from a user supplied hook
+type HookError struct {
+	Hook  string
+	Value interface{}
+	Stack []byte
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("netter: %s hook panicked: %v", e.Hook, e.Value)
+}
+
+// recoverHook turns a panic of the named hook into a *HookError in err
+func recoverHook(name string, err *error) {
+	if v := recover(); v != nil {
+		*err = &HookError{Hook: name, Value: v, Stack: debug.Stack()}
+	}
+}
+
+func (c *Client) checkRetry(ctx context.Context, policy *Retry, resp *http.Response, err error) (retry bool, checkErr error) {
+	if c.CheckRetry == nil {
+		return policy.isRetry(ctx, resp, err)
+	}
+	defer recoverHook("CheckRetry", &checkErr)
+	return c.CheckRetry(ctx, resp, err)
+}
+
+func (c *Client) onRetry(req *Request, resp *http.Response, err error, attempt int) (hookErr error) {
+	if c.OnRetry == nil {
+		return nil
+	}
+	defer recoverHook("OnRetry", &hookErr)
+	c.OnRetry(req, resp, err, attempt)
+	return nil
+}
+
+func (c *Client) prepareRetry(req *Request) (err error) {
+	if c.PrepareRetry == nil {
+		return nil
+	}
+	defer recoverHook("PrepareRetry", &err)
+	return c.PrepareRetry(req)
+}
+
+func (c *Client) proxyFunc(req *Request) (proxy *url.URL, err error) {
+	defer recoverHook("ProxyFunc", &err)
+	return c.ProxyFunc(req)
+}
+
+func (c *Client) fallback(req *Request, cause error) (resp *http.Response, err error) {
+	defer recoverHook("Fallback", &err)
+	return c.Fallback(req, cause)
+}
@@ -0,0 +1,212 @@
+package netgo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// hostRule is one AllowedHosts or DeniedHosts entry
+type hostRule struct {
+	name   string // exact name, or ".example.com" for "*.example.com"
+	prefix netip.Prefix
+	isCIDR bool
+}
+
+func parseHostRule(s string) hostRule {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return hostRule{prefix: p.Masked(), isCIDR: true}
+	}
+	if ip, err := netip.ParseAddr(s); err == nil {
+		return hostRule{prefix: netip.PrefixFrom(ip, ip.BitLen()), isCIDR: true}
+	}
+	return hostRule{name: strings.TrimPrefix(normalizeHost(s), "*")}
+}
+
+func (r hostRule) matchName(host string) bool {
+	if r.isCIDR {
+		return false
+	}
+	if strings.HasPrefix(r.name, ".") {
+		return strings.HasSuffix(host, r.name)
+	}
+	return host == r.name
+}
+
+// hostRules splits entries into name rules and CIDR rules
+func hostRules(entries []string) (names, cidrs []hostRule) {
+	for _, e := range entries {
+		r := parseHostRule(e)
+		if r.isCIDR {
+			cidrs = append(cidrs, r)
+		} else {
+			names = append(names, r)
+		}
+	}
+	return names, cidrs
+}
+
+func inCIDRs(ip netip.Addr, rules []hostRule) bool {
+	for _, r := range rules {
+		if r.prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostPolicy holds the parsed AllowedHosts and DeniedHosts of a client
+type hostPolicy struct {
+	allowAny               bool
+	allowNames, allowCIDRs []hostRule
+	denyNames, denyCIDRs   []hostRule
+}
+
+func (c *Client) hostPolicy() *hostPolicy {
+	if len(c.AllowedHosts) == 0 && len(c.DeniedHosts) == 0 {
+		return nil
+	}
+	p := &hostPolicy{allowAny: len(c.AllowedHosts) == 0}
+	p.allowNames, p.allowCIDRs = hostRules(c.AllowedHosts)
+	p.denyNames, p.denyCIDRs = hostRules(c.DeniedHosts)
+	return p
+}
+
+// name tells whether host is denied or allowed by its name
+func (p *hostPolicy) name(host string) (denied, allowed bool) {
+	for _, r := range p.denyNames {
+		if r.matchName(host) {
+			return true, false
+		}
+	}
+	allowed = p.allowAny
+	for _, r := range p.allowNames {
+		if r.matchName(host) {
+			allowed = true
+		}
+	}
+	return false, allowed
+}
+
+// allows tells whether ip may be connected to for a host whose name is
+// allowed or not
+func (p *hostPolicy) allows(ip netip.Addr, allowed bool) bool {
+	ip = ip.Unmap()
+	return !inCIDRs(ip, p.denyCIDRs) && (allowed || inCIDRs(ip, p.allowCIDRs))
+}
+
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// checkHostPolicy enforces AllowedHosts and DeniedHosts on u. Names are
+// matched first; CIDR entries are checked against the addresses the host
+// resolves to, every one of which must pass. When the transport dials
+// through controlHostPolicy the addresses are checked as they are dialed
+// instead, so rebinding the name in between gains nothing.
+func (c *Client) checkHostPolicy(ctx context.Context, u *url.URL) error {
+	p := c.hostPolicy()
+	if p == nil {
+		return nil
+	}
+	host := normalizeHost(u.Hostname())
+	reject := &InvalidRequestError{URL: u.Redacted(), Reason: "host " + host + " not allowed"}
+
+	denied, allowed := p.name(host)
+	if denied {
+		return reject
+	}
+	if len(p.denyCIDRs) == 0 && (allowed || len(p.allowCIDRs) == 0) {
+		if !allowed {
+			return reject
+		}
+		return nil
+	}
+
+	var ips []netip.Addr
+	if ip, err := netip.ParseAddr(host); err == nil {
+		ips = []netip.Addr{ip}
+	} else if c.dialChecksHosts() {
+		return nil
+	} else {
+		var err error
+		if ips, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host); err != nil {
+			if !allowed {
+				return reject
+			}
+			return err
+		}
+	}
+	for _, ip := range ips {
+		if !p.allows(ip, allowed) {
+			return reject
+		}
+	}
+	return nil
+}
+
+type (
+	hostPolicyKey struct{}
+	dialHostKey   struct{}
+)
+
+// withHostPolicy has the dials made for ctx check the addresses against
+// the CIDR entries
+func (c *Client) withHostPolicy(ctx context.Context) context.Context {
+	p := c.hostPolicy()
+	if p == nil || len(p.allowCIDRs) == 0 && len(p.denyCIDRs) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, hostPolicyKey{}, p)
+}
+
+// dialingHost records the host of addr for controlHostPolicy, unless an
+// outer dial function did already: overrides and resolvers hand on
+// other addresses than the one requested
+func dialingHost(ctx context.Context, addr string) context.Context {
+	if ctx.Value(hostPolicyKey{}) == nil || ctx.Value(dialHostKey{}) != nil {
+		return ctx
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return context.WithValue(ctx, dialHostKey{}, normalizeHost(host))
+}
+
+// controlHostPolicy implements net.Dialer.ControlContext, refusing
+// addresses the CIDR entries of the request policy do not allow. Behind a
+// proxy it is the proxy address that gets checked.
+func controlHostPolicy(ctx context.Context, network, address string, _ syscall.RawConn) error {
+	p, _ := ctx.Value(hostPolicyKey{}).(*hostPolicy)
+	if p == nil || strings.HasPrefix(network, "unix") {
+		return nil
+	}
+	host, _ := ctx.Value(dialHostKey{}).(string)
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return &InvalidRequestError{URL: host, Reason: "address " + address + " not allowed: " + err.Error()}
+	}
+	if _, allowed := p.name(host); !p.allows(ap.Addr(), allowed) {
+		return &InvalidRequestError{URL: host, Reason: "address " + address + " not allowed"}
+	}
+	return nil
+}
+
+// dialChecksHosts tells whether the client transport dials through
+// controlHostPolicy
+func (c *Client) dialChecksHosts() bool {
+	tr, ok := c.Inner.Transport.(*http.Transport)
+	switch {
+	case !ok:
+		return false
+	case isDialerFunc(tr.DialContext):
+		return c.dialer != nil && c.dialer.checksHosts
+	default:
+		return isBaseDial(tr.DialContext)
+	}
+}
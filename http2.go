@@ -0,0 +1,70 @@
+package netgo
+
+import (
+	"net/http"
+	"time"
+)
+
+// HTTP2 configures HTTP/2 on the client transport. Without WithHTTP2 the
+// default transport speaks HTTP/1.1 only.
+type HTTP2 struct {
+	// Disable keeps every connection on HTTP/1.1
+	Disable bool
+	// ReadIdleTimeout after which a connection that received no frame is
+	// health checked with a ping, so dead connections are dropped instead
+	// of holding requests until the OS gives up; 0 disables checks
+	ReadIdleTimeout time.Duration
+	// PingTimeout closes a connection whose health check ping went
+	// unanswered, 15 seconds by default
+	PingTimeout time.Duration
+	// WriteByteTimeout closes a connection that accepts no data for that
+	// long while there is some to write
+	WriteByteTimeout time.Duration
+	// StrictMaxConcurrentStreams makes the stream limit announced by the
+	// server a cap for the whole host: requests beyond it wait for a free
+	// stream instead of opening another connection
+	StrictMaxConcurrentStreams bool
+}
+
+// WithHTTP2 negotiates HTTP/2 over TLS, falling back to HTTP/1.1, or
+// disables it when h.Disable is set. HTTP/2 multiplexes requests over
+// long-lived connections, so enabling it enables keep-alives as
+// WithKeepAlives(0) does, unless they are on already.
+func WithHTTP2(h HTTP2) Option {
+	return func(c *Client) {
+		tr, ok := c.Inner.Transport.(*http.Transport)
+		if !ok {
+			return
+		}
+		tr = tr.Clone()
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(!h.Disable)
+		tr.Protocols = protocols
+		if h.Disable && tr.TLSClientConfig != nil {
+			// cloning a transport with HTTP/2 enabled leaves h2 in ALPN
+			var next []string
+			for _, p := range tr.TLSClientConfig.NextProtos {
+				if p != "h2" {
+					next = append(next, p)
+				}
+			}
+			tr.TLSClientConfig.NextProtos = next
+		}
+		if !h.Disable {
+			if tr.DisableKeepAlives {
+				keepAlive(tr, 0)
+			}
+			cfg := &http.HTTP2Config{}
+			if tr.HTTP2 != nil {
+				*cfg = *tr.HTTP2
+			}
+			cfg.SendPingTimeout = h.ReadIdleTimeout
+			cfg.PingTimeout = h.PingTimeout
+			cfg.WriteByteTimeout = h.WriteByteTimeout
+			cfg.StrictMaxConcurrentRequests = h.StrictMaxConcurrentStreams
+			tr.HTTP2 = cfg
+		}
+		c.Inner.Transport = tr
+	}
+}
@@ -0,0 +1,69 @@
+package netgo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// errorBodyLimit bounds the body kept by HTTPError
+const errorBodyLimit = 64 << 10
+
+// HTTPError is returned for responses with a non-2xx or otherwise
+// unexpected status
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body holds the first 64KB of the response body
+	Body []byte
+	// Problem is the decoded body of application/problem+json responses
+	Problem *ProblemDetails
+}
+
+func (e *HTTPError) Error() string {
+	if e.Problem != nil && e.Problem.Title != "" {
+		return fmt.Sprintf("netter: unexpected status %s: %s", e.Status, e.Problem.Title)
+	}
+	return fmt.Sprintf("netter: unexpected status %s", e.Status)
+}
+
+// CheckStatus returns an *HTTPError for a non-2xx response, consuming
+// and closing its body, and nil otherwise
+func CheckStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	return newHTTPError(resp)
+}
+
+func newHTTPError(resp *http.Response) *HTTPError {
+	e := &HTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header}
+	if e.Status == "" {
+		e.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	}
+	if resp.Body != nil {
+		e.Body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, errorBodyLimit))
+		CloseBody(resp)
+		e.Problem = parseProblem(resp.Header.Get("Content-Type"), e.Body)
+	}
+	return e
+}
+
+// WithCheckStatus makes Do return an *HTTPError for non-2xx responses.
+// The response is returned too, its body replaced by the captured bytes.
+func WithCheckStatus() RequestOption {
+	return func(r *Request) {
+		r.checkStatus = true
+	}
+}
+
+func checkStatus(resp *http.Response) error {
+	err := CheckStatus(resp)
+	if e, ok := err.(*HTTPError); ok {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(e.Body))
+	}
+	return err
+}
@@ -0,0 +1,202 @@
+package netgo
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JournalEntry is one attempt recorded in the journal
+type JournalEntry struct {
+	Time          time.Time `json:"time"`
+	Method        string    `json:"method"`
+	Host          string    `json:"host"`
+	Status        int       `json:"status"`
+	RequestBytes  int64     `json:"request_bytes"`
+	ResponseBytes int64     `json:"response_bytes"`
+}
+
+// Journal appends every attempt to time-bucketed files in Dir, one
+// JSON line per attempt, so usage can be reconciled against upstream
+// invoices. Files are named after the bucket start, e.g.
+// requests-2006010215.jsonl for hourly buckets.
+type Journal struct {
+	Dir string
+	// Bucket is the file rotation period, one hour by default;
+	// use 24 * time.Hour for daily files. File names carry the minutes
+	// for buckets under an hour and the seconds for those under a minute.
+	Bucket time.Duration
+	// SyncEvery entries the file is flushed and synced, 1 by default
+	SyncEvery int
+
+	mu      sync.Mutex
+	f       *os.File
+	w       *bufio.Writer
+	name    string
+	pending int
+}
+
+func (j *Journal) fileName(t time.Time) string {
+	bucket := j.Bucket
+	if bucket <= 0 {
+		bucket = time.Hour
+	}
+	layout := "2006010215"
+	switch {
+	case bucket >= 24*time.Hour:
+		layout = "20060102"
+	case bucket < time.Minute:
+		layout = "20060102150405"
+	case bucket < time.Hour:
+		layout = "200601021504"
+	}
+	return filepath.Join(j.Dir, "requests-"+t.UTC().Truncate(bucket).Format(layout)+".jsonl")
+}
+
+// Record appends e to the journal
+func (j *Journal) Record(e JournalEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if name := j.fileName(e.Time); name != j.name {
+		if err := j.closeFile(); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		j.f, j.w, j.name = f, bufio.NewWriter(f), name
+	}
+
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	j.pending++
+	if j.pending >= j.SyncEvery {
+		return j.sync()
+	}
+	return nil
+}
+
+func (j *Journal) sync() error {
+	j.pending = 0
+	if err := j.w.Flush(); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+func (j *Journal) closeFile() error {
+	if j.f == nil {
+		return nil
+	}
+	err := j.sync()
+	if cerr := j.f.Close(); err == nil {
+		err = cerr
+	}
+	j.f, j.w, j.name = nil, nil, ""
+	return err
+}
+
+// Flush writes and syncs buffered entries
+func (j *Journal) Flush() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	return j.sync()
+}
+
+// Close flushes and closes the current file
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.closeFile()
+}
+
+// JournalTotals aggregates journal entries of one host
+type JournalTotals struct {
+	Requests      int64
+	RequestBytes  int64
+	ResponseBytes int64
+}
+
+// ReadJournal sums the entries of a journal file per host. A torn last
+// line left by a crash is ignored.
+func ReadJournal(name string) (map[string]JournalTotals, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	totals := make(map[string]JournalTotals)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e JournalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		t := totals[e.Host]
+		t.Requests++
+		t.RequestBytes += e.RequestBytes
+		t.ResponseBytes += e.ResponseBytes
+		totals[e.Host] = t
+	}
+	return totals, sc.Err()
+}
+
+// journal records the attempt once its response body is closed
+func (c *Client) journal(req *http.Request, resp *http.Response, err error) {
+	e := JournalEntry{
+		Time:   time.Now(),
+		Method: req.Method,
+		Host:   req.URL.Host,
+	}
+	if req.ContentLength > 0 {
+		e.RequestBytes = req.ContentLength
+	}
+	if err != nil || resp == nil {
+		if err := c.Journal.Record(e); err != nil {
+			c.log().Printf("netter: journal: %v", err)
+		}
+		return
+	}
+	e.Status = resp.StatusCode
+	resp.Body = &journalBody{ReadCloser: resp.Body, c: c, entry: e}
+}
+
+type journalBody struct {
+	io.ReadCloser
+	c     *Client
+	entry JournalEntry
+	once  sync.Once
+}
+
+func (b *journalBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.entry.ResponseBytes += int64(n)
+	return n, err
+}
+
+func (b *journalBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() {
+		if err := b.c.Journal.Record(b.entry); err != nil {
+			b.c.log().Printf("netter: journal: %v", err)
+		}
+	})
+	return err
+}
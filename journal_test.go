@@ -0,0 +1,102 @@
+package netgo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	j := &Journal{Dir: t.TempDir()}
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	client.Journal = j
+	resp, err := client.Post(ts.URL, "text/plain", "body")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// every attempt is recorded, the retried one too
+	files, _ := filepath.Glob(filepath.Join(j.Dir, "requests-*.jsonl"))
+	var total JournalTotals
+	for _, name := range files {
+		totals, err := ReadJournal(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := totals[strings.TrimPrefix(ts.URL, "http://")]
+		total.Requests += got.Requests
+		total.RequestBytes += got.RequestBytes
+		total.ResponseBytes += got.ResponseBytes
+	}
+	if total != (JournalTotals{Requests: 2, RequestBytes: 8, ResponseBytes: 5}) {
+		t.Fatalf("totals %+v", total)
+	}
+}
+
+func TestJournalBuckets(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)
+	hourly := &Journal{Dir: dir, SyncEvery: 10}
+	for _, d := range []time.Duration{0, 20 * time.Minute, 40 * time.Minute} {
+		if err := hourly.Record(JournalEntry{Time: at.Add(d), Host: "api.test", RequestBytes: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := hourly.Close(); err != nil {
+		t.Fatal(err)
+	}
+	daily := &Journal{Dir: dir, Bucket: 24 * time.Hour}
+	daily.Record(JournalEntry{Time: at, Host: "api.test"})
+	daily.Close()
+	halfHourly := &Journal{Dir: dir, Bucket: 30 * time.Minute}
+	for _, d := range []time.Duration{0, 20 * time.Minute, 40 * time.Minute} {
+		halfHourly.Record(JournalEntry{Time: at.Add(d), Host: "api.test"})
+	}
+	halfHourly.Close()
+
+	for name, want := range map[string]int64{
+		"requests-2024030110.jsonl":   2,
+		"requests-2024030111.jsonl":   1,
+		"requests-20240301.jsonl":     1,
+		"requests-202403011030.jsonl": 2,
+		"requests-202403011100.jsonl": 1,
+	} {
+		totals, err := ReadJournal(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if totals["api.test"].Requests != want {
+			t.Fatalf("%s: %+v, want %d requests", name, totals, want)
+		}
+	}
+
+	// a line torn by a crash is skipped
+	name := filepath.Join(dir, "requests-2024030110.jsonl")
+	f, _ := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"time":"2024-03-01T10:59:00Z","host":"api.te`)
+	f.Close()
+	if totals, err := ReadJournal(name); err != nil || totals["api.test"].Requests != 2 {
+		t.Fatalf("torn journal: %+v %v", totals, err)
+	}
+}
@@ -0,0 +1,49 @@
+package netgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// GetJSON sends get request and decodes the JSON response into out
+func (c *Client) GetJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(req, out)
+}
+
+// PostJSON sends in encoded as JSON and decodes the JSON response into
+// out, which may be nil to discard it
+func (c *Client) PostJSON(ctx context.Context, url string, in, out interface{}) error {
+	buf, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := NewRequestWithContext(ctx, "POST", url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.doJSON(req, out)
+}
+
+// doJSON runs req, fails on non-2xx statuses and decodes the body
+func (c *Client) doJSON(req *Request, out interface{}) error {
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json")
+	}
+	_, err := DoAndClose(c, req, func(resp *http.Response) (struct{}, error) {
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return struct{}{}, newHTTPError(resp)
+		}
+		if out == nil || resp.StatusCode == http.StatusNoContent {
+			return struct{}{}, nil
+		}
+		return struct{}{}, json.NewDecoder(resp.Body).Decode(out)
+	})
+	return err
+}
@@ -0,0 +1,156 @@
+package netgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept") != "application/json" {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		if req.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var in map[string]int
+		if req.Method == "POST" {
+			if req.Header.Get("Content-Type") != "application/json" {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			_ = json.NewDecoder(req.Body).Decode(&in)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]int{"n": in["n"] + 1})
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	ctx := context.Background()
+
+	var out struct{ N int }
+	if err := client.GetJSON(ctx, ts.URL, &out); err != nil || out.N != 1 {
+		t.Fatalf("GetJSON: %v %+v", err, out)
+	}
+	if err := client.PostJSON(ctx, ts.URL, map[string]int{"n": 41}, &out); err != nil || out.N != 42 {
+		t.Fatalf("PostJSON: %v %+v", err, out)
+	}
+	if err := client.GetJSON(ctx, ts.URL+"/missing", &out); err == nil {
+		t.Fatal("expected status error")
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Reason", "quota")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("slow down"))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	err := client.GetJSON(context.Background(), ts.URL, nil)
+	var herr *HTTPError
+	if !errors.As(err, &herr) {
+		t.Fatalf("expected HTTPError, got %v", err)
+	}
+	if herr.StatusCode != http.StatusTooManyRequests || string(herr.Body) != "slow down" || herr.Header.Get("X-Reason") != "quota" {
+		t.Fatalf("bad error: %+v", herr)
+	}
+
+	resp, err := client.Get(ts.URL, WithCheckStatus())
+	if !errors.As(err, &herr) || resp == nil {
+		t.Fatalf("expected HTTPError and response, got %v", err)
+	}
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "slow down" {
+		t.Fatalf("body not kept: %q", b)
+	}
+}
+
+func TestHTTPErrorProblem(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"type":"https://example.com/probs/credit","title":"Not enough credit","status":403,"balance":30}`))
+	}))
+	defer ts.Close()
+
+	err := NewClient(WithTransport(ts.Client().Transport)).GetJSON(context.Background(), ts.URL, nil)
+	var herr *HTTPError
+	if !errors.As(err, &herr) || herr.Problem == nil {
+		t.Fatalf("expected problem details, got %v", err)
+	}
+	p := herr.Problem
+	if p.Title != "Not enough credit" || p.Status != 403 || p.Extensions["balance"] != float64(30) {
+		t.Fatalf("bad problem: %+v", p)
+	}
+	if err.Error() != "netter: unexpected status 403 Forbidden: Not enough credit" {
+		t.Fatalf("bad message: %s", err)
+	}
+}
+
+func TestResponseCheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html>"))
+	}))
+	defer ts.Close()
+	client := NewClient(WithTransport(ts.Client().Transport))
+
+	resp, err := client.Get(ts.URL, WithResponseCheck(StatusIn(200, 204), ContentTypeIn("text/html")))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+
+	_, err = client.Get(ts.URL, WithResponseCheck(StatusIn(200), ContentTypeIn("application/json")))
+	var cterr *ContentTypeError
+	if !errors.As(err, &cterr) || cterr.Got != "text/html; charset=utf-8" {
+		t.Fatalf("expected ContentTypeError, got %v", err)
+	}
+
+	_, err = client.Get(ts.URL, WithResponseCheck(StatusIn(201)))
+	var herr *HTTPError
+	if !errors.As(err, &herr) || string(herr.Body) != "<html>" {
+		t.Fatalf("expected HTTPError, got %v", err)
+	}
+}
+
+func TestStreamJSONLines(t *testing.T) {
+	type rec struct{ N int }
+	body := "{\"N\":1}\n\n{\"N\":2}\n{\"N\":"
+	resp := &http.Response{Body: ioutil.NopCloser(strings.NewReader(body))}
+
+	var got []int
+	var serr *StreamError
+	for v, err := range StreamJSONLines[rec](resp) {
+		if err != nil {
+			if !errors.As(err, &serr) {
+				t.Fatalf("expected StreamError, got %v", err)
+			}
+			break
+		}
+		got = append(got, v.N)
+	}
+	if len(got) != 2 || got[1] != 2 {
+		t.Fatalf("bad records: %v", got)
+	}
+	if serr == nil || serr.Line != 3 || serr.Offset != int64(len("{\"N\":1}\n\n{\"N\":2}\n")) || !serr.Retryable {
+		t.Fatalf("bad stream error: %+v", serr)
+	}
+
+	resp = &http.Response{Body: ioutil.NopCloser(strings.NewReader("{\"N\":1}\nnope\n"))}
+	for _, err := range StreamJSONLines[rec](resp) {
+		if err != nil && (!errors.As(err, &serr) || serr.Retryable) {
+			t.Fatalf("malformed record should not be retryable: %v", err)
+		}
+	}
+}
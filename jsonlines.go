@@ -0,0 +1,76 @@
+package netgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"net"
+	"net/http"
+)
+
+// StreamError reports where a JSON Lines stream broke off
+type StreamError struct {
+	// Line is the number of the record that failed, starting at 1
+	Line int
+	// Offset is the byte count of the records decoded before, e.g. to
+	// resume with a Range request
+	Offset int64
+	// Retryable is set when the connection failed or the stream was cut
+	// short, as opposed to a malformed record
+	Retryable bool
+	Err       error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("netter: json lines record %d at offset %d: %v", e.Line, e.Offset, e.Err)
+}
+
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// StreamJSONLines decodes a JSON Lines / NDJSON response body record by
+// record, without reading it whole. Blank lines are skipped. Iteration
+// ends at the first error, a *StreamError, and the body is closed once
+// the loop is done.
+func StreamJSONLines[T any](resp *http.Response) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		defer CloseBody(resp)
+		r := bufio.NewReader(resp.Body)
+		var offset int64
+		for line := 1; ; {
+			b, err := r.ReadBytes('\n')
+			if err != nil && err != io.EOF {
+				var zero T
+				yield(zero, &StreamError{Line: line, Offset: offset, Retryable: true, Err: err})
+				return
+			}
+			atEOF := err == io.EOF
+			if rec := bytes.TrimSpace(b); len(rec) > 0 {
+				var v T
+				if derr := json.Unmarshal(rec, &v); derr != nil {
+					// an unterminated last record was most likely cut short
+					yield(v, &StreamError{Line: line, Offset: offset, Retryable: atEOF || isNetError(derr), Err: derr})
+					return
+				}
+				if !yield(v, nil) {
+					return
+				}
+				line++
+			}
+			offset += int64(len(b))
+			if atEOF {
+				return
+			}
+		}
+	}
+}
+
+func isNetError(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) || errors.Is(err, io.ErrUnexpectedEOF)
+}
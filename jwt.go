@@ -0,0 +1,163 @@
+package netgo
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// JWTAssertion is a TokenSource authenticating with JWTs it mints and
+// signs with Key, as service accounts do. With TokenURL the JWT is
+// exchanged for an access token in the RFC 7523 jwt-bearer grant;
+// without, the JWT itself is the bearer token. Tokens are renewed
+// ahead of expiry like those of ClientCredentials.
+type JWTAssertion struct {
+	// Client sends the token requests, through its retry loop
+	Client   *Client
+	TokenURL string
+	// Key signs the JWT: RSA keys with RS256, P-256 keys with ES256 and
+	// Ed25519 keys with EdDSA
+	Key      crypto.Signer
+	KeyID    string
+	Issuer   string
+	Subject  string
+	Audience string
+	// Scopes are sent with the grant request; providers expecting them
+	// as a claim get them through Claims
+	Scopes []string
+	// Claims are added to the JWT, e.g. {"scope": ...} for Google
+	Claims map[string]interface{}
+	// Lifetime of minted JWTs, one hour by default
+	Lifetime time.Duration
+	// EarlyRefresh is the window before expiry in which the token is
+	// renewed, one minute by default
+	EarlyRefresh time.Duration
+
+	cache refreshCache
+}
+
+// Token implements TokenSource
+func (j *JWTAssertion) Token() (*Token, error) {
+	return j.tokenContext(context.Background())
+}
+
+func (j *JWTAssertion) tokenContext(ctx context.Context) (*Token, error) {
+	return j.cache.get(ctx, j.EarlyRefresh, func(ctx context.Context) (*Token, error) {
+		now := time.Now()
+		lifetime := j.Lifetime
+		if lifetime <= 0 {
+			lifetime = time.Hour
+		}
+		assertion, err := j.sign(now, now.Add(lifetime))
+		if err != nil {
+			return nil, fmt.Errorf("netter: jwt assertion: %w", err)
+		}
+		if j.TokenURL == "" {
+			return &Token{AccessToken: assertion, TokenType: "Bearer", Expiry: now.Add(lifetime)}, nil
+		}
+		form := url.Values{
+			"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+			"assertion":  {assertion},
+		}
+		if len(j.Scopes) > 0 {
+			form.Set("scope", strings.Join(j.Scopes, " "))
+		}
+		tok, err := requestToken(ctx, j.Client, j.TokenURL, form, "")
+		if err != nil {
+			return nil, fmt.Errorf("netter: jwt bearer grant: %w", err)
+		}
+		return tok, nil
+	})
+}
+
+func (j *JWTAssertion) invalidate() {
+	j.cache.invalidate()
+}
+
+// sign mints a JWT valid from iat until exp
+func (j *JWTAssertion) sign(iat, exp time.Time) (string, error) {
+	var alg string
+	switch pub := j.Key.Public().(type) {
+	case *rsa.PublicKey:
+		alg = "RS256"
+	case *ecdsa.PublicKey:
+		if pub.Curve.Params().BitSize != 256 {
+			return "", fmt.Errorf("unsupported curve %s", pub.Curve.Params().Name)
+		}
+		alg = "ES256"
+	case ed25519.PublicKey:
+		alg = "EdDSA"
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if j.KeyID != "" {
+		header["kid"] = j.KeyID
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	claims := map[string]interface{}{
+		"iat": iat.Unix(),
+		"exp": exp.Unix(),
+		"jti": hex.EncodeToString(jti),
+	}
+	for name, v := range map[string]string{"iss": j.Issuer, "sub": j.Subject, "aud": j.Audience} {
+		if v != "" {
+			claims[name] = v
+		}
+	}
+	for k, v := range j.Claims {
+		claims[k] = v
+	}
+
+	var parts []string
+	for _, v := range []interface{}{header, claims} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, base64.RawURLEncoding.EncodeToString(b))
+	}
+	signed := strings.Join(parts, ".")
+
+	var (
+		sig []byte
+		err error
+	)
+	if alg == "EdDSA" {
+		sig, err = j.Key.Sign(rand.Reader, []byte(signed), crypto.Hash(0))
+	} else {
+		digest := sha256.Sum256([]byte(signed))
+		sig, err = j.Key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return "", err
+	}
+	if alg == "ES256" {
+		// JWS wants r and s concatenated, not ASN.1
+		var rs struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+			return "", err
+		}
+		sig = make([]byte, 64)
+		rs.R.FillBytes(sig[:32])
+		rs.S.FillBytes(sig[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
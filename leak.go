@@ -0,0 +1,65 @@
+//go:build netgodebug
+
+package netgo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// Built with the netgodebug tag, every response body handed out is
+// watched by a finalizer that logs when it is collected unclosed.
+
+var packagePrefix = func() string {
+	name := runtime.FuncForPC(reflect.ValueOf(packageMarker).Pointer()).Name()
+	return name[:strings.LastIndex(name, ".")+1]
+}()
+
+func packageMarker() {}
+
+type leakBody struct {
+	io.ReadCloser
+	closed int32
+}
+
+func (b *leakBody) Close() error {
+	atomic.StoreInt32(&b.closed, 1)
+	return b.ReadCloser.Close()
+}
+
+// callSite returns the first caller outside this package
+func callSite() string {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, packagePrefix) {
+			return fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+func trackBody(c *Client, req *Request, resp *http.Response) *http.Response {
+	lb := &leakBody{ReadCloser: resp.Body}
+	site := callSite()
+	url := req.URL.String()
+	logger := c.log()
+	runtime.SetFinalizer(lb, func(lb *leakBody) {
+		if atomic.LoadInt32(&lb.closed) == 0 {
+			logger.Printf("netter: response body of %s requested at %s was never closed", url, site)
+		}
+	})
+	// the transport holds on to resp until its body is read, the caller
+	// gets a copy so an abandoned body becomes unreachable
+	tracked := *resp
+	tracked.Body = lb
+	return &tracked
+}
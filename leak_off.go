@@ -0,0 +1,10 @@
+//go:build !netgodebug
+
+package netgo
+
+import "net/http"
+
+// trackBody watches for unclosed response bodies in netgodebug builds
+func trackBody(c *Client, req *Request, resp *http.Response) *http.Response {
+	return resp
+}
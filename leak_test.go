@@ -0,0 +1,73 @@
+//go:build netgodebug
+
+package netgo_test
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anabiozz/netgo"
+)
+
+// syncBuffer is written by finalizers running on their own goroutine
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func leakResponse(t *testing.T, client *netgo.Client, url string) {
+	if _, err := client.Get(url); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func closeResponse(t *testing.T, client *netgo.Client, url string) {
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestLeakedBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("body"))
+	}))
+	defer ts.Close()
+
+	var logs syncBuffer
+	client := netgo.NewClient(netgo.WithTransport(ts.Client().Transport), netgo.WithLogger(log.New(&logs, "", 0)))
+	closeResponse(t, client, ts.URL+"/closed")
+	leakResponse(t, client, ts.URL+"/leaked")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(logs.String(), "never closed") && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := logs.String()
+	if !strings.Contains(got, "/leaked requested at github.com/anabiozz/netgo_test.leakResponse") {
+		t.Fatalf("leak not reported at its call site: %q", got)
+	}
+	if strings.Contains(got, "/closed") {
+		t.Fatalf("closed body reported: %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+package netgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrClientClosed is returned by requests on a closed client
+var ErrClientClosed = errors.New("netter: client closed")
+
+// lifecycle tracks in-flight requests of a client
+type lifecycle struct {
+	mu       sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+	// abort wakes up pending retry waits once Close gave up waiting
+	abort chan struct{}
+}
+
+func (c *Client) lifecycle() *lifecycle {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if c.life == nil {
+		c.life = &lifecycle{abort: make(chan struct{})}
+	}
+	return c.life
+}
+
+func (l *lifecycle) enter() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.inflight.Add(1)
+	return true
+}
+
+func (l *lifecycle) leave() {
+	l.inflight.Done()
+}
+
+// Close stops accepting new requests and waits for in-flight ones,
+// including their pending retries, until ctx is done. Pending retries
+// are then abandoned with ErrClientClosed. Idle connections are closed
+// in both cases.
+func (c *Client) Close(ctx context.Context) error {
+	l := c.lifecycle()
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrClientClosed
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		l.inflight.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		close(l.abort)
+		err = ctx.Err()
+	}
+	c.CloseIdleConnections()
+	return err
+}
+
+// CloseIdleConnections closes idle connections of the client's transports
+// without affecting other clients
+func (c *Client) CloseIdleConnections() {
+	c.Inner.CloseIdleConnections()
+	c.sniCloseIdle()
+}
@@ -0,0 +1,64 @@
+package netgo
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrResponseTooLarge is returned while reading a response body longer
+// than the MaxResponseBytes limit
+var ErrResponseTooLarge = errors.New("netter: response body too large")
+
+// WithMaxResponseBytes overrides Client.MaxResponseBytes for this
+// request; a negative n lifts the limit
+func WithMaxResponseBytes(n int64) RequestOption {
+	return func(r *Request) {
+		r.maxResponse = n
+	}
+}
+
+func (c *Client) maxResponseBytes(req *Request) int64 {
+	if req.maxResponse != 0 {
+		return req.maxResponse
+	}
+	return c.MaxResponseBytes
+}
+
+// limitResponse caps the body of resp at n bytes. A declared length over
+// the limit fails right away.
+func limitResponse(resp *http.Response, n int64) error {
+	if n <= 0 || resp == nil || resp.Body == nil {
+		return nil
+	}
+	if resp.ContentLength > n {
+		CloseBody(resp)
+		return ErrResponseTooLarge
+	}
+	resp.Body = &limitBody{ReadCloser: resp.Body, n: n}
+	return nil
+}
+
+// limitBody fails with ErrResponseTooLarge instead of returning more
+// than n bytes
+type limitBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *limitBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		var probe [1]byte
+		n, err := b.ReadCloser.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,130 @@
+package netgo
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrLoadShed is returned when a request was rejected locally because
+// its host is failing too often
+var ErrLoadShed = errors.New("netter: request shed, upstream error rate too high")
+
+// LoadShedPolicy configures when requests to a host are shed
+type LoadShedPolicy struct {
+	// Threshold error rate, from 0 to 1, above which requests are shed
+	Threshold float64
+	// Fraction of new requests rejected while above the threshold
+	Fraction float64
+	// MinRequests in the window before the error rate is trusted
+	MinRequests int
+}
+
+// LoadShed rejects a fraction of requests to hosts whose rolling
+// error rate exceeds the policy threshold, giving them room to recover
+type LoadShed struct {
+	Default LoadShedPolicy
+	// Hosts overrides Default per host (host[:port] as in the URL)
+	Hosts map[string]LoadShedPolicy
+	// Window of the rolling error rate, one minute by default
+	Window time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*rollingWindow
+}
+
+func (l *LoadShed) policy(host string) LoadShedPolicy {
+	if p, ok := l.Hosts[host]; ok {
+		return p
+	}
+	return l.Default
+}
+
+func (l *LoadShed) window(host string) *rollingWindow {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stats == nil {
+		l.stats = make(map[string]*rollingWindow)
+	}
+	w, ok := l.stats[host]
+	if !ok {
+		span := l.Window
+		if span <= 0 {
+			span = time.Minute
+		}
+		w = newRollingWindow(span, 10)
+		l.stats[host] = w
+	}
+	return w
+}
+
+// ErrorRate returns the rolling error rate and request count for host
+func (l *LoadShed) ErrorRate(host string) (float64, int) {
+	total, failed := l.window(host).counts(time.Now())
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(failed) / float64(total), total
+}
+
+func (l *LoadShed) shed(host string) bool {
+	p := l.policy(host)
+	if p.Threshold <= 0 || p.Fraction <= 0 {
+		return false
+	}
+	rate, total := l.ErrorRate(host)
+	if total < p.MinRequests || rate <= p.Threshold {
+		return false
+	}
+	return rand.Float64() < p.Fraction
+}
+
+func (l *LoadShed) record(host string, resp *http.Response, err error) {
+	failed := err != nil || resp == nil || resp.StatusCode >= 500
+	l.window(host).add(time.Now(), failed)
+}
+
+// rollingWindow counts outcomes over a sliding span split into buckets
+type rollingWindow struct {
+	mu      sync.Mutex
+	width   time.Duration
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	slot          int64
+	total, failed int
+}
+
+func newRollingWindow(span time.Duration, n int) *rollingWindow {
+	return &rollingWindow{width: span / time.Duration(n), buckets: make([]windowBucket, n)}
+}
+
+func (w *rollingWindow) add(now time.Time, failed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	slot := now.UnixNano() / int64(w.width)
+	b := &w.buckets[slot%int64(len(w.buckets))]
+	if b.slot != slot {
+		*b = windowBucket{slot: slot}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+}
+
+func (w *rollingWindow) counts(now time.Time) (total, failed int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	slot := now.UnixNano() / int64(w.width)
+	for _, b := range w.buckets {
+		if slot-b.slot < int64(len(w.buckets)) {
+			total += b.total
+			failed += b.failed
+		}
+	}
+	return total, failed
+}
@@ -0,0 +1,40 @@
+package netgo
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRollingWindow(t *testing.T) {
+	w := newRollingWindow(time.Second, 10)
+	now := time.Unix(100, 0)
+	w.add(now, true)
+	w.add(now.Add(500*time.Millisecond), false)
+	if total, failed := w.counts(now.Add(900 * time.Millisecond)); total != 2 || failed != 1 {
+		t.Fatalf("bad counts: %d %d", total, failed)
+	}
+	if total, _ := w.counts(now.Add(1200 * time.Millisecond)); total != 1 {
+		t.Fatalf("expired bucket still counted: %d", total)
+	}
+}
+
+func TestClientLoadShed(t *testing.T) {
+	client := newDefaultClient()
+	client.LoadShed = &LoadShed{
+		Default: LoadShedPolicy{Threshold: 0.5, Fraction: 1, MinRequests: 3},
+		Hosts:   map[string]LoadShedPolicy{"healthy.example": {}},
+	}
+	for i := 0; i < 3; i++ {
+		client.LoadShed.record("failing.example", nil, errors.New("boom"))
+		client.LoadShed.record("healthy.example", nil, errors.New("boom"))
+	}
+
+	_, err := client.Get("http://failing.example/")
+	if err != ErrLoadShed {
+		t.Fatalf("expected ErrLoadShed, got %v", err)
+	}
+	if client.LoadShed.shed("healthy.example") {
+		t.Fatal("host override should disable shedding")
+	}
+}
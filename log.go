@@ -0,0 +1,60 @@
+package netgo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WithRequestLogger sends the log lines of this request to l instead of
+// the client logger
+func WithRequestLogger(l Logger) RequestOption {
+	return func(r *Request) {
+		r.logger = l
+	}
+}
+
+// WithLogFields appends fields, as sorted key=value pairs, to every log
+// line of this request, e.g. a tenant or request ID
+func WithLogFields(fields map[string]interface{}) RequestOption {
+	return func(r *Request) {
+		if r.logFields == nil {
+			r.logFields = make(map[string]interface{}, len(fields))
+		}
+		for k, v := range fields {
+			r.logFields[k] = v
+		}
+	}
+}
+
+// logger returns the logger for the lines about req
+func (c *Client) logger(req *Request) Logger {
+	l := c.Logger
+	if req.logger != nil {
+		l = req.logger
+	}
+	l = c.redactLogger(l)
+	if len(req.logFields) == 0 {
+		return l
+	}
+	keys := make([]string, 0, len(req.logFields))
+	for k := range req.logFields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, req.logFields[k])
+	}
+	return fieldLogger{l, b.String()}
+}
+
+// fieldLogger appends preformatted fields to every line
+type fieldLogger struct {
+	Logger
+	fields string
+}
+
+func (l fieldLogger) Printf(format string, args ...interface{}) {
+	l.Logger.Printf(format+"%s", append(args, l.fields)...)
+}
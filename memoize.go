@@ -0,0 +1,128 @@
+package netgo
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithMemoize serves this GET from a copy of an earlier 2xx response to
+// the same URL fetched within ttl, whatever its cache headers say.
+// Concurrent misses share one request. Memoized bodies are kept in
+// memory by the client, up to 64MB in total.
+func WithMemoize(ttl time.Duration) RequestOption {
+	return func(r *Request) {
+		r.memoize = ttl
+	}
+}
+
+type memoizer struct {
+	store MemoryCacheStore
+
+	mu    sync.Mutex
+	calls map[string]chan struct{}
+}
+
+func (c *Client) memoizer() *memoizer {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if c.memo == nil {
+		c.memo = &memoizer{calls: make(map[string]chan struct{})}
+	}
+	return c.memo
+}
+
+// middleware memoizes for ttl the responses to r, which are buffered up
+// to its response size limit
+func (m *memoizer) middleware(c *Client, r *Request, ttl time.Duration) Middleware {
+	limit := c.maxResponseBytes(r)
+	if limit <= 0 {
+		limit = m.store.maxBytes()
+	}
+	return func(next RoundTripperFunc) RoundTripperFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.Method != "GET" {
+				return next(req)
+			}
+			return m.roundTrip(c, partitionKey(CacheKey(req), r.credentials), limit, ttl, req, next)
+		}
+	}
+}
+
+func (m *memoizer) roundTrip(c *Client, key string, limit int64, ttl time.Duration, req *http.Request, next RoundTripperFunc) (*http.Response, error) {
+	for {
+		m.mu.Lock()
+		entry, body, ok := m.store.Get(key)
+		if ok {
+			if age := time.Since(entry.ResponseTime); age < ttl && varyMatches(entry, req) {
+				m.mu.Unlock()
+				return entry.response(req, body, age), nil
+			}
+			body.Close()
+		}
+		wait, busy := m.calls[key]
+		if !busy {
+			m.calls[key] = make(chan struct{})
+		}
+		m.mu.Unlock()
+		if !busy {
+			break
+		}
+		select {
+		case <-wait:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+	defer func() {
+		m.mu.Lock()
+		close(m.calls[key])
+		delete(m.calls, key)
+		m.mu.Unlock()
+	}()
+
+	reqTime := time.Now()
+	resp, err := next(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, err
+	}
+	buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(buf)) > limit {
+		// too large to keep: hand the body on, the limit of the client
+		// applies to it as to any other
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(buf), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(buf))
+
+	w, err := m.store.Put(key, &CacheEntry{
+		URL:          req.URL.String(),
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		VaryHeader:   varyHeader(req, resp),
+		RequestTime:  reqTime,
+		ResponseTime: time.Now(),
+	})
+	if err == nil {
+		if _, err = w.Write(buf); err == nil {
+			err = w.Commit()
+		} else {
+			w.Abort()
+		}
+	}
+	if err != nil {
+		c.log().Printf("netter: memoize %s: %v", req.URL, err)
+	}
+	return resp, nil
+}
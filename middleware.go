@@ -0,0 +1,45 @@
+package netgo
+
+import "net/http"
+
+// RoundTripperFunc is an http.RoundTripper implemented by a function
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps a round trip with cross-cutting behaviour such as
+// auth, tracing or logging
+type Middleware func(next RoundTripperFunc) RoundTripperFunc
+
+// Placement selects where middleware runs
+type Placement int
+
+const (
+	// AroundAttempt runs middleware for every single attempt
+	AroundAttempt Placement = iota
+	// AroundRetries runs middleware once around the whole retry loop
+	AroundRetries
+)
+
+// Use appends middleware at the given placement. The first middleware
+// added is the outermost. Clients derived by With keep their own lists.
+func (c *Client) Use(p Placement, mw ...Middleware) {
+	// appending past the length could write into the array shared with
+	// another client
+	switch p {
+	case AroundRetries:
+		c.RetryMiddleware = append(c.RetryMiddleware[:len(c.RetryMiddleware):len(c.RetryMiddleware)], mw...)
+	default:
+		c.AttemptMiddleware = append(c.AttemptMiddleware[:len(c.AttemptMiddleware):len(c.AttemptMiddleware)], mw...)
+	}
+}
+
+func chain(mw []Middleware, rt RoundTripperFunc) RoundTripperFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		rt = mw[i](rt)
+	}
+	return rt
+}
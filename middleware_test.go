@@ -0,0 +1,61 @@
+package netgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientMiddleware(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		if n == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(req.Header.Get("X-Trace")))
+	}))
+	defer ts.Close()
+
+	var log []string
+	tag := func(name string) Middleware {
+		return func(next RoundTripperFunc) RoundTripperFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				log = append(log, name)
+				req.Header.Set("X-Trace", req.Header.Get("X-Trace")+name)
+				return next(req)
+			}
+		}
+	}
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 1}))
+	client.Use(AroundRetries, tag("r"))
+	client.Use(AroundAttempt, tag("a"), tag("b"))
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if got := strings.Join(log, ","); got != "r,a,b,a,b" {
+		t.Fatalf("bad middleware order: %s", got)
+	}
+}
+
+func TestUseAfterWith(t *testing.T) {
+	nop := func(next RoundTripperFunc) RoundTripperFunc { return next }
+	base := NewClient()
+	base.AttemptMiddleware = make([]Middleware, 1, 4)
+	base.AttemptMiddleware[0] = nop
+
+	a, b := base.With(), base.With()
+	a.Use(AroundAttempt, nop)
+	b.Use(AroundAttempt, nop, nop)
+	a.AttemptMiddleware[1] = nil
+	if b.AttemptMiddleware[1] == nil || len(base.AttemptMiddleware) != 1 {
+		t.Fatal("clients share their middleware")
+	}
+}
@@ -0,0 +1,75 @@
+package netgo
+
+import (
+	"net/http"
+	"time"
+)
+
+// RetryableHTTPConfig mirrors the fields of a go-retryablehttp client
+// that have a netgo equivalent
+type RetryableHTTPConfig struct {
+	HTTPClient   *http.Client
+	Logger       Logger
+	RetryWaitMin time.Duration
+	RetryWaitMax time.Duration
+	// RetryMax of 0 keeps the netgo default, negative disables retries
+	RetryMax int
+}
+
+// FromRetryableHTTP represents new client configured like go-retryablehttp.
+// Zero fields keep netgo defaults.
+func FromRetryableHTTP(cfg RetryableHTTPConfig) *Client {
+	c := newDefaultClient()
+	if cfg.HTTPClient != nil {
+		c.Inner = cfg.HTTPClient
+	}
+	if cfg.Logger != nil {
+		c.Logger = cfg.Logger
+	}
+	if cfg.RetryWaitMin > 0 {
+		c.WaitMin = cfg.RetryWaitMin
+	}
+	if cfg.RetryWaitMax > 0 {
+		c.WaitMax = cfg.RetryWaitMax
+	}
+	if cfg.RetryMax != 0 {
+		c.Max = max(cfg.RetryMax, 0)
+	}
+	return c
+}
+
+// HeimdallConfig mirrors heimdall httpclient options and its
+// exponential backoff parameters
+type HeimdallConfig struct {
+	Timeout time.Duration
+	// RetryCount of 0 disables retries, as in heimdall
+	RetryCount     int
+	InitialTimeout time.Duration
+	MaxTimeout     time.Duration
+	// ExponentFactor multiplies the backoff after every retry
+	ExponentFactor float64
+	Logger         Logger
+}
+
+// FromHeimdall represents new client configured like a heimdall client.
+// Zero fields keep netgo defaults, except RetryCount.
+func FromHeimdall(cfg HeimdallConfig) *Client {
+	c := newDefaultClient()
+	if cfg.Timeout > 0 {
+		c.Inner.Timeout = cfg.Timeout
+	}
+	if cfg.Logger != nil {
+		c.Logger = cfg.Logger
+	}
+	c.Max = max(cfg.RetryCount, 0)
+	if cfg.InitialTimeout > 0 {
+		c.WaitMin = cfg.InitialTimeout
+	}
+	if cfg.MaxTimeout > 0 {
+		c.WaitMax = cfg.MaxTimeout
+	}
+	if cfg.ExponentFactor > 0 {
+		c.Multiplier = cfg.ExponentFactor
+	}
+	return c
+}
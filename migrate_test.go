@@ -0,0 +1,37 @@
+package netgo
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFromRetryableHTTP(t *testing.T) {
+	inner := &http.Client{}
+	logger := log.New(os.Stderr, "", 0)
+	c := FromRetryableHTTP(RetryableHTTPConfig{HTTPClient: inner, Logger: logger, RetryWaitMin: time.Second, RetryWaitMax: time.Minute, RetryMax: 7})
+	if c.Inner != inner || c.Logger != logger || c.WaitMin != time.Second || c.WaitMax != time.Minute || c.Max != 7 {
+		t.Fatalf("config not carried over: %+v", c.Retry)
+	}
+
+	defaults := NewClient()
+	if c := FromRetryableHTTP(RetryableHTTPConfig{}); c.Retry.WaitMin != defaults.WaitMin || c.Max != defaults.Max {
+		t.Fatalf("zero config changed defaults: %+v", c.Retry)
+	}
+	if c := FromRetryableHTTP(RetryableHTTPConfig{RetryMax: -1}); c.Max != 0 {
+		t.Fatalf("retries not disabled: %d", c.Max)
+	}
+}
+
+func TestFromHeimdall(t *testing.T) {
+	c := FromHeimdall(HeimdallConfig{Timeout: time.Second, RetryCount: 2, InitialTimeout: time.Millisecond, MaxTimeout: time.Second, ExponentFactor: 1.5})
+	if c.Inner.Timeout != time.Second || c.Max != 2 || c.WaitMin != time.Millisecond || c.WaitMax != time.Second || c.Multiplier != 1.5 {
+		t.Fatalf("config not carried over: %v %+v", c.Inner.Timeout, c.Retry)
+	}
+	// heimdall does not retry without a retry count
+	if c := FromHeimdall(HeimdallConfig{}); c.Max != 0 || c.Inner.Timeout != NewClient().Inner.Timeout {
+		t.Fatalf("retries not disabled: %+v", c.Retry)
+	}
+}
@@ -0,0 +1,89 @@
+package netgo
+
+import (
+	"context"
+	"math/rand"
+	"net/url"
+	"path"
+	"sync/atomic"
+)
+
+// Mirror asynchronously copies a fraction of requests to a secondary
+// backend. Mirrored responses are discarded and failures are only
+// logged and counted.
+type Mirror struct {
+	// BaseURL of the secondary backend, e.g. "https://canary.internal/api"
+	BaseURL string
+	// Fraction of requests mirrored, from 0 to 1
+	Fraction float64
+
+	sent, failed int64
+}
+
+// Sent returns the number of mirrored requests
+func (m *Mirror) Sent() int64 {
+	return atomic.LoadInt64(&m.sent)
+}
+
+// Failed returns the number of mirrored requests that failed
+func (m *Mirror) Failed() int64 {
+	return atomic.LoadInt64(&m.failed)
+}
+
+func (m *Mirror) target(u *url.URL) (*url.URL, error) {
+	base, err := url.Parse(m.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+	target := *u
+	target.Scheme = base.Scheme
+	target.Host = base.Host
+	if base.Path != "" && base.Path != "/" {
+		target.Path = path.Join(base.Path, u.Path)
+		target.RawPath = ""
+	}
+	return &target, nil
+}
+
+// mirror sends a copy of req to the mirror backend in the background
+func (c *Client) mirror(req *Request) {
+	m := c.Mirror
+	if m.Fraction <= 0 || (m.Fraction < 1 && rand.Float64() >= m.Fraction) {
+		return
+	}
+
+	target, err := m.target(req.URL)
+	if err != nil {
+		atomic.AddInt64(&m.failed, 1)
+		c.log().Printf("netter: mirror %s: %v", m.BaseURL, err)
+		return
+	}
+
+	shadow := req.Request.Clone(context.WithoutCancel(req.Context()))
+	shadow.URL = target
+	shadow.Host = ""
+	shadow.Body = nil
+	if req.body != nil {
+		body, err := req.body()
+		if err != nil {
+			atomic.AddInt64(&m.failed, 1)
+			c.log().Printf("netter: mirror %s: %v", target, err)
+			return
+		}
+		shadow.Body = toReadCloser(body)
+	}
+
+	atomic.AddInt64(&m.sent, 1)
+	go func() {
+		resp, err := c.Inner.Do(shadow)
+		if err != nil {
+			atomic.AddInt64(&m.failed, 1)
+			c.log().Printf("netter: mirror %s request failed: %v", target, err)
+			return
+		}
+		if resp.StatusCode >= 500 {
+			atomic.AddInt64(&m.failed, 1)
+		}
+		c.drainBody(resp.Body)
+	}()
+}
@@ -0,0 +1,61 @@
+package netgo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMirror(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer primary.Close()
+	type copied struct{ path, body string }
+	copies := make(chan copied, 4)
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		if string(b) == "fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		copies <- copied{req.URL.Path, string(b)}
+	}))
+	defer canary.Close()
+
+	m := &Mirror{BaseURL: canary.URL + "/shadow", Fraction: 1}
+	client := NewClient(WithTransport(primary.Client().Transport), WithRetry(Retry{}))
+	client.Mirror = m
+	for _, body := range []string{"ok", "fail"} {
+		resp, err := client.Post(primary.URL+"/v1/items", "text/plain", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		select {
+		case c := <-copies:
+			if c.path != "/shadow/v1/items" || c.body != body {
+				t.Fatalf("mirrored %+v", c)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("request not mirrored")
+		}
+	}
+	// the failure is counted once its response is in
+	deadline := time.Now().Add(5 * time.Second)
+	for m.Failed() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if m.Sent() != 2 || m.Failed() != 1 {
+		t.Fatalf("sent %d, failed %d", m.Sent(), m.Failed())
+	}
+
+	m.Fraction = 0
+	resp, err := client.Get(primary.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if m.Sent() != 2 {
+		t.Fatalf("mirrored with fraction 0: %d", m.Sent())
+	}
+}
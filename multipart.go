@@ -0,0 +1,181 @@
+package netgo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+// Multipart builds a multipart/form-data body that streams its parts,
+// e.g. files from disk, without buffering them. It is a BodyProvider,
+// so every attempt reopens the parts:
+//
+//	m := netgo.NewMultipart().Field("name", "x").File("upload", path)
+//	resp, err := client.Post(u, m.ContentType(), m)
+type Multipart struct {
+	// Progress, when set, is called as the body is read with the bytes
+	// sent by the current attempt and the total, -1 when unknown
+	Progress func(sent, total int64)
+
+	boundary string
+	parts    []multipartPart
+}
+
+type multipartPart struct {
+	header textproto.MIMEHeader
+	body   BodyProvider
+}
+
+// NewMultipart returns an empty form with a random boundary
+func NewMultipart() *Multipart {
+	return &Multipart{boundary: multipart.NewWriter(nil).Boundary()}
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// Field adds a form field
+func (m *Multipart) Field(name, value string) *Multipart {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(name)))
+	m.parts = append(m.parts, multipartPart{header: h, body: BodyString(value)})
+	return m
+}
+
+// File adds the file at path as a file part named after its base name,
+// with the content type guessed from its extension
+func (m *Multipart) File(field, path string) *Multipart {
+	ct := mime.TypeByExtension(filepath.Ext(path))
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	return m.Part(field, filepath.Base(path), ct, BodyFile(path))
+}
+
+// Part adds a file part with contents read from body
+func (m *Multipart) Part(field, filename, contentType string, body BodyProvider) *Multipart {
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(field), quoteEscaper.Replace(filename)))
+	h.Set("Content-Type", contentType)
+	m.parts = append(m.parts, multipartPart{header: h, body: body})
+	return m
+}
+
+// ContentType returns the Content-Type header value for the body
+func (m *Multipart) ContentType() string {
+	return "multipart/form-data; boundary=" + m.boundary
+}
+
+// framing returns the bytes written before every part and the trailer
+func (m *Multipart) framing() ([][]byte, []byte) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	w.SetBoundary(m.boundary)
+	heads := make([][]byte, len(m.parts))
+	for i, p := range m.parts {
+		buf.Reset()
+		w.CreatePart(p.header)
+		heads[i] = append([]byte(nil), buf.Bytes()...)
+	}
+	buf.Reset()
+	w.Close()
+	return heads, buf.Bytes()
+}
+
+// Len implements BodyProvider
+func (m *Multipart) Len() int64 {
+	heads, trailer := m.framing()
+	n := int64(len(trailer))
+	for i, p := range m.parts {
+		size := p.body.Len()
+		if size < 0 {
+			return -1
+		}
+		n += int64(len(heads[i])) + size
+	}
+	return n
+}
+
+// Open implements BodyProvider; parts are opened only once reached
+func (m *Multipart) Open() (io.Reader, error) {
+	heads, trailer := m.framing()
+	r := &multipartReader{m: m, heads: heads, trailer: trailer}
+	if m.Progress != nil {
+		r.total = m.Len()
+	}
+	return r, nil
+}
+
+type multipartReader struct {
+	m       *Multipart
+	heads   [][]byte
+	trailer []byte
+
+	i     int
+	cur   io.Reader
+	sent  int64
+	total int64
+}
+
+func (r *multipartReader) Read(p []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if err := r.next(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := r.cur.Read(p)
+		if n > 0 && r.m.Progress != nil {
+			r.sent += int64(n)
+			r.m.Progress(r.sent, r.total)
+		}
+		if err == io.EOF {
+			r.closeCur()
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// next moves to the following segment: a part header, its body, or the
+// trailer
+func (r *multipartReader) next() error {
+	part := r.i / 2
+	switch {
+	case part < len(r.m.parts) && r.i%2 == 0:
+		r.cur = bytes.NewReader(r.heads[part])
+	case part < len(r.m.parts):
+		body, err := r.m.parts[part].body.Open()
+		if err != nil {
+			return err
+		}
+		r.cur = body
+	case part == len(r.m.parts) && r.i%2 == 0:
+		r.cur = bytes.NewReader(r.trailer)
+	default:
+		return io.EOF
+	}
+	r.i++
+	return nil
+}
+
+func (r *multipartReader) closeCur() {
+	if c, ok := r.cur.(io.Closer); ok {
+		c.Close()
+	}
+	r.cur = nil
+}
+
+// Close closes the part being read, if any
+func (r *multipartReader) Close() error {
+	r.closeCur()
+	return nil
+}
@@ -0,0 +1,57 @@
+package netgo
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMultipartRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := ioutil.WriteFile(path, []byte("file contents"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f, fh, err := req.FormFile("upload")
+		if err != nil || req.FormValue("name") != "x" || fh.Filename != "notes.txt" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := ioutil.ReadAll(f)
+		if string(b) != "file contents" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	var sent, total int64
+	m := NewMultipart().Field("name", "x").File("upload", path)
+	m.Progress = func(s, t int64) { sent, total = s, t }
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	resp, err := client.Post(ts.URL, m.ContentType(), m)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || n != 2 {
+		t.Fatalf("bad outcome: %d after %d attempts", resp.StatusCode, n)
+	}
+	if total != m.Len() || sent != total {
+		t.Fatalf("bad progress: %d of %d, body is %d", sent, total, m.Len())
+	}
+}
@@ -0,0 +1,50 @@
+package netgo
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+// NegotiateTokenProvider produces SPNEGO tokens for Negotiate
+// authentication (RFC 4559), typically backed by a Kerberos library
+type NegotiateTokenProvider interface {
+	// Token returns the token for the service principal spn; input is
+	// the token of the server's challenge, nil for the initial one
+	Token(req *http.Request, spn string, input []byte) ([]byte, error)
+}
+
+// NegotiateAuthenticator answers Negotiate challenges with tokens of
+// Provider, e.g. for Kerberos-protected services
+type NegotiateAuthenticator struct {
+	Provider NegotiateTokenProvider
+	// SPN names the service principal of a host, "HTTP/" + host by
+	// default
+	SPN func(host string) string
+}
+
+// Scheme implements Authenticator
+func (NegotiateAuthenticator) Scheme() string { return "Negotiate" }
+
+// Authorize implements Authenticator
+func (a NegotiateAuthenticator) Authorize(req *http.Request, ch *Challenge, nc uint32) (string, error) {
+	host := req.URL.Hostname()
+	spn := "HTTP/" + host
+	if a.SPN != nil {
+		spn = a.SPN(host)
+	}
+	// a server token continues the context of the answered challenge
+	// only; pre-emptive answers start a new one
+	var input []byte
+	if t := ch.Params[""]; t != "" && nc == 1 {
+		var err error
+		if input, err = base64.StdEncoding.DecodeString(t); err != nil {
+			return "", errors.New("netter: malformed negotiate token")
+		}
+	}
+	token, err := a.Provider.Token(req, spn, input)
+	if err != nil {
+		return "", err
+	}
+	return "Negotiate " + base64.StdEncoding.EncodeToString(token), nil
+}
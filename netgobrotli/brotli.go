@@ -0,0 +1,19 @@
+// Package netgobrotli registers the br content encoding with netgo.
+// Import it for its side effect:
+//
+//	import _ "github.com/anabiozz/netgo/netgobrotli"
+package netgobrotli
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/anabiozz/netgo"
+	"github.com/andybalholm/brotli"
+)
+
+func init() {
+	netgo.RegisterContentDecoder("br", func(r io.Reader) (io.ReadCloser, error) {
+		return ioutil.NopCloser(brotli.NewReader(r)), nil
+	})
+}
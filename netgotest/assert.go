@@ -0,0 +1,43 @@
+package netgotest
+
+import (
+	"testing"
+	"time"
+)
+
+// AssertRetrySchedule fails t unless the clock recorded exactly the
+// backoff waits in want, in order
+func AssertRetrySchedule(t testing.TB, rec *FakeClock, want []time.Duration) {
+	t.Helper()
+	got := rec.Waits()
+	if len(got) != len(want) {
+		t.Errorf("retry schedule: got %d waits %v, want %d waits %v", len(got), got, len(want), want)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("retry schedule: wait %d is %s, want %s (got %v)", i, got[i], want[i], got)
+			return
+		}
+	}
+}
+
+// AssertRetryCount fails t unless the clock recorded n waits
+func AssertRetryCount(t testing.TB, rec *FakeClock, n int) {
+	t.Helper()
+	if got := len(rec.Waits()); got != n {
+		t.Errorf("retry count: got %d, want %d", got, n)
+	}
+}
+
+// AssertTotalBackoff fails t unless the recorded waits add up to want
+func AssertTotalBackoff(t testing.TB, rec *FakeClock, want time.Duration) {
+	t.Helper()
+	var total time.Duration
+	for _, d := range rec.Waits() {
+		total += d
+	}
+	if total != want {
+		t.Errorf("total backoff: got %s, want %s", total, want)
+	}
+}
@@ -0,0 +1,64 @@
+// Package netgotest provides helpers for testing code built on netgo
+package netgotest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anabiozz/netgo"
+)
+
+// FakeClock is a netgo.Clock whose timers fire immediately. It records
+// every requested wait so backoff schedules can be asserted.
+type FakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	waits []time.Duration
+}
+
+// NewFakeClock represents new fake clock starting at now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// NewTimer implements netgo.Clock; the clock advances by d
+func (c *FakeClock) NewTimer(d time.Duration) netgo.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return fakeTimer{ch}
+}
+
+// Now returns the fake current time
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Waits returns the waits requested so far
+func (c *FakeClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+type fakeTimer struct {
+	c chan time.Time
+}
+
+func (t fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t fakeTimer) Stop() bool {
+	select {
+	case <-t.c:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,41 @@
+package netgotest
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/anabiozz/netgo"
+)
+
+func TestAssertRetrySchedule(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		if n < 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	clock := NewFakeClock(time.Unix(0, 0))
+	client := &netgo.Client{
+		Inner:  ts.Client(),
+		Logger: log.New(ioutil.Discard, "", 0),
+		Retry:  netgo.Retry{Max: 5, WaitMin: time.Second, WaitMax: 3 * time.Second},
+		Clock:  clock,
+	}
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+
+	AssertRetrySchedule(t, clock, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second})
+	AssertRetryCount(t, clock, 3)
+	AssertTotalBackoff(t, clock, 6*time.Second)
+}
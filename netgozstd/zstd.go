@@ -0,0 +1,22 @@
+// Package netgozstd registers the zstd content encoding with netgo.
+// Import it for its side effect:
+//
+//	import _ "github.com/anabiozz/netgo/netgozstd"
+package netgozstd
+
+import (
+	"io"
+
+	"github.com/anabiozz/netgo"
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	netgo.RegisterContentDecoder("zstd", func(r io.Reader) (io.ReadCloser, error) {
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	})
+}
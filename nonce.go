@@ -0,0 +1,324 @@
+package netgo
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NonceStore records used nonces so none is accepted twice before it expires
+type NonceStore interface {
+	// Use marks nonce as used until expires; it reports false when
+	// the nonce was already used and has not expired yet
+	Use(nonce string, expires time.Time) (bool, error)
+}
+
+// NonceGenerator hands out nonces that are unique within its store
+type NonceGenerator struct {
+	Store NonceStore
+	// TTL of generated nonces, five minutes by default
+	TTL time.Duration
+	// Size of the random part in bytes, 16 by default
+	Size int
+}
+
+// Nonce returns a fresh nonce registered in the store
+func (g *NonceGenerator) Nonce() (string, error) {
+	size := g.Size
+	if size <= 0 {
+		size = 16
+	}
+	ttl := g.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	buf := make([]byte, size)
+	for i := 0; i < 8; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		nonce := hex.EncodeToString(buf)
+		if g.Store == nil {
+			return nonce, nil
+		}
+		ok, err := g.Store.Use(nonce, time.Now().Add(ttl))
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return nonce, nil
+		}
+	}
+	return "", errors.New("netter: could not generate unique nonce")
+}
+
+// MemoryNonceStore keeps used nonces in process memory
+type MemoryNonceStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewMemoryNonceStore represents new in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{used: make(map[string]time.Time)}
+}
+
+// Use implements NonceStore
+func (s *MemoryNonceStore) Use(nonce string, expires time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used == nil {
+		s.used = make(map[string]time.Time)
+	}
+	now := time.Now()
+	if exp, ok := s.used[nonce]; ok && exp.After(now) {
+		return false, nil
+	}
+	if len(s.used) > 0 && len(s.used)%1024 == 0 {
+		for n, exp := range s.used {
+			if !exp.After(now) {
+				delete(s.used, n)
+			}
+		}
+	}
+	s.used[nonce] = expires
+	return true, nil
+}
+
+// FileNonceStore keeps used nonces in a file shared by several processes.
+// Access is serialized with a lock file next to it; a lock left behind by
+// a crashed process is taken over once older than StaleLock. The nonces
+// are indexed in memory, reading only what other processes appended.
+type FileNonceStore struct {
+	Path string
+	// LockTimeout bounds waiting for other processes, 5s by default
+	LockTimeout time.Duration
+	// StaleLock is the age past which a lock counts as abandoned, 30s by
+	// default; locks are held for a single update
+	StaleLock time.Duration
+
+	mu sync.Mutex
+	// live indexes the file up to offset, which has lines lines; file
+	// identifies the file read, replaced when compacted
+	live   map[string]int64
+	offset int64
+	lines  int
+	file   os.FileInfo
+}
+
+// NewFileNonceStore represents new file-backed nonce store
+func NewFileNonceStore(path string) *FileNonceStore {
+	return &FileNonceStore{Path: path}
+}
+
+func (s *FileNonceStore) lock() (func(), error) {
+	timeout := s.LockTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	name := s.Path + ".lock"
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	owner := fmt.Sprintf("%d %x\n", os.Getpid(), token)
+	deadline := time.Now().Add(timeout)
+	for {
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(name)
+				return nil, err
+			}
+			return func() {
+				// only remove the lock while it is still ours
+				if b, err := os.ReadFile(name); err == nil && string(b) == owner {
+					os.Remove(name)
+				}
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if s.breakStale(name) {
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("netter: timeout locking %s", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// breakStale removes the lock file name when it is older than StaleLock
+// and still has the owner it had when its age was checked
+func (s *FileNonceStore) breakStale(name string) bool {
+	stale := durationOr(s.StaleLock, 30*time.Second)
+	owner, err := os.ReadFile(name)
+	if err != nil {
+		return false
+	}
+	fi, err := os.Stat(name)
+	if err != nil || time.Since(fi.ModTime()) < stale {
+		return false
+	}
+	if again, err := os.ReadFile(name); err != nil || string(again) != string(owner) {
+		return false
+	}
+	return os.Remove(name) == nil
+}
+
+// Use implements NonceStore
+func (s *FileNonceStore) Use(nonce string, expires time.Time) (bool, error) {
+	if strings.ContainsAny(nonce, " \n") {
+		return false, fmt.Errorf("netter: invalid nonce %q", nonce)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lock()
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	if err := s.catchUp(); err != nil {
+		return false, err
+	}
+	now := time.Now().Unix()
+	if exp, ok := s.live[nonce]; ok && exp > now {
+		return false, nil
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return false, err
+	}
+	n, err := fmt.Fprintf(f, "%s %d\n", nonce, expires.Unix())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// the next catchUp reads what made it to the file
+		s.file = nil
+		return false, err
+	}
+	s.live[nonce] = expires.Unix()
+	s.offset += int64(n)
+	s.lines++
+	if s.file == nil {
+		s.file, _ = os.Stat(s.Path)
+	}
+	return true, s.compact(now)
+}
+
+// catchUp brings the index up to date with the file, reading it all
+// again only when another process replaced it
+func (s *FileNonceStore) catchUp() error {
+	fi, err := os.Stat(s.Path)
+	if os.IsNotExist(err) {
+		s.live, s.offset, s.lines, s.file = make(map[string]int64), 0, 0, nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.live == nil || s.file == nil || !os.SameFile(fi, s.file) || fi.Size() < s.offset {
+		s.live, s.offset, s.lines = make(map[string]int64), 0, 0
+	}
+	s.file = fi
+	if fi.Size() == s.offset {
+		return nil
+	}
+
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Seek(s.offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			// a line without its newline is still being written
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.offset += int64(len(line))
+		s.lines++
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		exp, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if exp > s.live[fields[0]] {
+			s.live[fields[0]] = exp
+		}
+	}
+}
+
+// compact rewrites the file without expired nonces once they make up
+// more than half of it
+func (s *FileNonceStore) compact(now int64) error {
+	if s.lines < 1024 || s.lines <= 2*len(s.live) {
+		return nil
+	}
+	for nonce, exp := range s.live {
+		if exp <= now {
+			delete(s.live, nonce)
+		}
+	}
+	if s.lines <= 2*len(s.live) {
+		return nil
+	}
+	return s.rewrite()
+}
+
+func (s *FileNonceStore) rewrite() error {
+	tmp := s.Path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for nonce, exp := range s.live {
+		fmt.Fprintf(w, "%s %d\n", nonce, exp)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.Path); err != nil {
+		return err
+	}
+	fi, err := os.Stat(s.Path)
+	if err != nil {
+		s.file = nil
+		return err
+	}
+	s.file, s.offset, s.lines = fi, fi.Size(), len(s.live)
+	return nil
+}
@@ -0,0 +1,84 @@
+package netgo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNonceStores(t *testing.T) {
+	stores := map[string]NonceStore{
+		"memory": NewMemoryNonceStore(),
+		"file":   NewFileNonceStore(filepath.Join(t.TempDir(), "nonces")),
+	}
+	for name, store := range stores {
+		exp := time.Now().Add(time.Minute)
+		if ok, err := store.Use("abc", exp); !ok || err != nil {
+			t.Fatalf("%s: first use rejected: %v", name, err)
+		}
+		if ok, err := store.Use("abc", exp); ok || err != nil {
+			t.Fatalf("%s: replayed nonce accepted: %v", name, err)
+		}
+		if ok, err := store.Use("old", time.Now().Add(-time.Second)); !ok || err != nil {
+			t.Fatalf("%s: err: %v", name, err)
+		}
+		if ok, err := store.Use("old", exp); !ok || err != nil {
+			t.Fatalf("%s: expired nonce not reusable: %v", name, err)
+		}
+
+		g := &NonceGenerator{Store: store}
+		a, err := g.Nonce()
+		if err != nil {
+			t.Fatalf("%s: err: %v", name, err)
+		}
+		if ok, _ := store.Use(a, exp); ok {
+			t.Fatalf("%s: generated nonce not registered", name)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "shared")
+	if ok, _ := NewFileNonceStore(path).Use("x", time.Now().Add(time.Minute)); !ok {
+		t.Fatal("first use rejected")
+	}
+	if ok, _ := NewFileNonceStore(path).Use("x", time.Now().Add(time.Minute)); ok {
+		t.Fatal("nonce replayed through a second store on the same file")
+	}
+
+	// each store picks up what the other appended since
+	a, b := NewFileNonceStore(path), NewFileNonceStore(path)
+	for i, nonce := range []string{"y", "z", "w"} {
+		first, second := a, b
+		if i%2 == 1 {
+			first, second = b, a
+		}
+		if ok, _ := first.Use(nonce, time.Now().Add(time.Minute)); !ok {
+			t.Fatalf("%s: first use rejected", nonce)
+		}
+		if ok, _ := second.Use(nonce, time.Now().Add(time.Minute)); ok {
+			t.Fatalf("%s: replayed through the other store", nonce)
+		}
+	}
+}
+
+func TestFileNonceStoreLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nonces")
+	store := &FileNonceStore{Path: path, LockTimeout: 50 * time.Millisecond}
+	if err := os.WriteFile(path+".lock", []byte("1 held\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Use("a", time.Now().Add(time.Minute)); err == nil || !strings.Contains(err.Error(), "timeout locking") {
+		t.Fatalf("live lock ignored: %v", err)
+	}
+
+	// a crashed process left the lock behind
+	old := time.Now().Add(-time.Minute)
+	os.Chtimes(path+".lock", old, old)
+	if ok, err := store.Use("a", time.Now().Add(time.Minute)); !ok || err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Fatalf("lock left behind: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package netgo
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures a client created by NewClient
+type Option func(*Client)
+
+// WithTimeout sets the overall time limit of a single attempt
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.Inner.Timeout = d
+	}
+}
+
+// WithRetry sets the retry policy
+func WithRetry(r Retry) Option {
+	return func(c *Client) {
+		c.Retry = r
+	}
+}
+
+// WithTransport sets the transport used for every attempt
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.Inner.Transport = rt
+	}
+}
+
+// WithLogger sets the logger of the retry loop
+func WithLogger(l Logger) Option {
+	return func(c *Client) {
+		c.Logger = l
+	}
+}
+
+// WithDefaultHeader adds a header sent on every request not setting it
+func WithDefaultHeader(key, value string) Option {
+	return func(c *Client) {
+		c.Headers = c.Headers.Clone()
+		if c.Headers == nil {
+			c.Headers = http.Header{}
+		}
+		c.Headers.Add(key, value)
+	}
+}
+
+// WithUserAgent sets the default User-Agent
+func WithUserAgent(ua string) Option {
+	return func(c *Client) {
+		c.UserAgent = ua
+	}
+}
+
+// With returns a copy of the client with opts applied. The copy shares
+// the transport, and thus the connection pool, with c but has its own
+// retry, timeout and lifecycle state; Close on either leaves the other
+// usable.
+func (c *Client) With(opts ...Option) *Client {
+	d := *c
+	inner := *c.Inner
+	d.Inner = &inner
+	d.life = nil
+	d.sni = nil
+	d.memo = nil
+	// the copy may be given another TokenSource
+	d.tokens = nil
+	d.pending = nil
+	for _, opt := range opts {
+		opt(&d)
+	}
+	d.applied()
+	return &d
+}
+
+// afterOptions defers fn until NewClient or With applied every option,
+// for options depending on settings that later ones may change
+func (c *Client) afterOptions(fn func(*Client)) {
+	c.pending = append(c.pending, fn)
+}
+
+func (c *Client) applied() {
+	for _, fn := range c.pending {
+		fn(c)
+	}
+	c.pending = nil
+}
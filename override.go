@@ -0,0 +1,106 @@
+package netgo
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// SetHostOverride sends host in the Host header instead of the URL host.
+// The connection is still dialed to the URL host and, unless SetSNI is
+// used as well, TLS still verifies the URL host, so the override only
+// changes which virtual host the upstream serves.
+func (r *Request) SetHostOverride(host string) {
+	r.Host = host
+}
+
+// WithHostOverride is the RequestOption form of SetHostOverride
+func WithHostOverride(host string) RequestOption {
+	return func(r *Request) {
+		r.SetHostOverride(host)
+	}
+}
+
+// SetSNI sends serverName in the TLS handshake and verifies the peer
+// certificate against it, independently of the dialed URL host and the
+// Host header. Attempts with an SNI override use a dedicated connection
+// pool per server name, cloned from the client's transport.
+func (r *Request) SetSNI(serverName string) {
+	r.sni = serverName
+}
+
+// WithSNI is the RequestOption form of SetSNI
+func WithSNI(serverName string) RequestOption {
+	return func(r *Request) {
+		r.SetSNI(serverName)
+	}
+}
+
+// sniClients holds the clones of a client's transport for attempts
+// overriding its SNI or proxy
+type sniClients struct {
+	mu      sync.Mutex
+	clients map[string]*http.Client
+}
+
+// variantClient returns the client for attempts sending serverName as
+// SNI and going through proxy, where set
+func (c *Client) variantClient(serverName string, proxy *url.URL) (*http.Client, error) {
+	stateMu.Lock()
+	if c.sni == nil {
+		c.sni = &sniClients{clients: make(map[string]*http.Client)}
+	}
+	s := c.sni
+	stateMu.Unlock()
+
+	key := serverName
+	if proxy != nil {
+		key += " " + proxy.String()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if inner, ok := s.clients[key]; ok {
+		return inner, nil
+	}
+
+	base := c.Inner.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	tr, ok := base.(*http.Transport)
+	if !ok {
+		return nil, errors.New("netter: SNI and proxy overrides need an *http.Transport")
+	}
+	tr = tr.Clone()
+	if serverName != "" {
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+		tr.TLSClientConfig.ServerName = serverName
+	}
+	if proxy != nil {
+		tr.Proxy = http.ProxyURL(proxy)
+		tr.OnProxyConnectResponse = refuseConnect(tr.OnProxyConnectResponse)
+	}
+
+	inner := *c.Inner
+	inner.Transport = tr
+	s.clients[key] = &inner
+	return &inner, nil
+}
+
+func (c *Client) sniCloseIdle() {
+	stateMu.Lock()
+	s := c.sni
+	stateMu.Unlock()
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, inner := range s.clients {
+		inner.CloseIdleConnections()
+	}
+}
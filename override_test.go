@@ -0,0 +1,52 @@
+package netgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestHostAndSNIOverride(t *testing.T) {
+	var host, sni string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host, sni = req.Host, req.TLS.ServerName
+	}))
+	defer ts.Close()
+
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger}
+
+	req, err := NewRequest("GET", ts.URL, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	req.SetHostOverride("vhost.test")
+	req.SetSNI("example.com")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if host != "vhost.test" || sni != "example.com" {
+		t.Fatalf("bad overrides: host %q sni %q", host, sni)
+	}
+
+	resp, err = client.Get(ts.URL, WithHostOverride("other.test"), WithSNI("www.example.com"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if host != "other.test" || sni != "www.example.com" {
+		t.Fatalf("bad override options: host %q sni %q", host, sni)
+	}
+
+	req, _ = NewRequest("GET", ts.URL, nil)
+	req.SetHostOverride("vhost.test")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if host != "vhost.test" || sni != "" {
+		t.Fatalf("host override changed SNI: host %q sni %q", host, sni)
+	}
+}
@@ -0,0 +1,153 @@
+package netgo
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Link is one entry of an RFC 8288 Link header
+type Link struct {
+	URL string
+	// Params holds link parameters with lowercase names, e.g. "rel"
+	Params map[string]string
+}
+
+// ParseLinks parses the Link headers of h
+func ParseLinks(h http.Header) []Link {
+	var links []Link
+	for _, v := range h.Values("Link") {
+		s := v
+		for {
+			s = strings.TrimLeft(s, " \t,")
+			if !strings.HasPrefix(s, "<") {
+				break
+			}
+			end := strings.IndexByte(s, '>')
+			if end < 0 {
+				break
+			}
+			link := Link{URL: s[1:end], Params: make(map[string]string)}
+			s = s[end+1:]
+			for {
+				s = strings.TrimLeft(s, " \t")
+				if !strings.HasPrefix(s, ";") {
+					break
+				}
+				name, rest := splitToken(strings.TrimLeft(s[1:], " \t"))
+				rest = strings.TrimLeft(rest, " \t")
+				var val string
+				if strings.HasPrefix(rest, "=") {
+					val, rest = parseParamValue(strings.TrimLeft(rest[1:], " \t"))
+				}
+				link.Params[strings.ToLower(name)] = val
+				s = rest
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// LinkNext returns the rel="next" target of the Link headers of resp,
+// resolved against the request URL, or "" on the last page
+func LinkNext(resp *http.Response) (string, error) {
+	for _, l := range ParseLinks(resp.Header) {
+		for _, rel := range strings.Fields(l.Params["rel"]) {
+			if !strings.EqualFold(rel, "next") {
+				continue
+			}
+			if resp.Request == nil {
+				return l.URL, nil
+			}
+			u, err := resp.Request.URL.Parse(l.URL)
+			if err != nil {
+				return "", err
+			}
+			return u.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// Paginator walks a paginated listing, one request per page
+type Paginator struct {
+	// Next returns the URL of the page after resp, or "" on the last one.
+	// It runs before the page is yielded, so if it reads the body it has
+	// to leave resp.Body readable again. LinkNext by default.
+	Next func(resp *http.Response) (string, error)
+	// MaxPages stops the walk after that many pages when positive
+	MaxPages int
+}
+
+// Paginate follows the Link rel="next" headers from req, see Paginator
+func (c *Client) Paginate(ctx context.Context, req *Request) iter.Seq2[*http.Response, error] {
+	return (&Paginator{}).Pages(ctx, c, req)
+}
+
+// Pages yields every page starting at req; each is sent through the
+// retry loop of c and its body closed once the loop body returns. The
+// walk ends at the first error, including non-2xx statuses reported as
+// *HTTPError. Later pages are GET requests with the headers of req; those
+// on another origin go without its credentials or those of the client.
+func (p *Paginator) Pages(ctx context.Context, c *Client, req *Request) iter.Seq2[*http.Response, error] {
+	next := p.Next
+	if next == nil {
+		next = LinkNext
+	}
+	return func(yield func(*http.Response, error) bool) {
+		first := *req
+		first.Request = req.Request.WithContext(ctx)
+		cur := &first
+		for n := 1; ; n++ {
+			resp, err := c.Do(cur)
+			if err == nil {
+				err = CheckStatus(resp)
+			}
+			if err != nil {
+				CloseBody(resp)
+				yield(nil, err)
+				return
+			}
+
+			u, err := next(resp)
+			if err != nil {
+				CloseBody(resp)
+				yield(nil, err)
+				return
+			}
+			more := yield(resp, nil)
+			CloseBody(resp)
+			if !more || u == "" || (p.MaxPages > 0 && n >= p.MaxPages) {
+				return
+			}
+
+			following, err := NewRequestWithContext(ctx, "GET", u, nil)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			following.Header = first.Header.Clone()
+			if !sameOrigin(following.URL, first.URL) {
+				c.stripCredentials(following.Header)
+				following.anonymous = true
+			}
+			cur = following
+		}
+	}
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// stripCredentials removes from h the credentials of a request or of c
+func (c *Client) stripCredentials(h http.Header) {
+	h.Del("Authorization")
+	h.Del("Cookie")
+	if c.apiKey != nil && c.apiKey.in == APIKeyInHeader {
+		h.Del(c.apiKey.name)
+	}
+}
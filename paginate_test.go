@@ -0,0 +1,105 @@
+package netgo
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseLinks(t *testing.T) {
+	h := http.Header{}
+	h.Add("Link", `<https://api.test/items?page=2>; rel="next", <https://api.test/items?page=9>; rel=last`)
+	h.Add("Link", `</help>; rel="help describedby"; title="a, b"`)
+	links := ParseLinks(h)
+	if len(links) != 3 {
+		t.Fatalf("bad links: %+v", links)
+	}
+	if links[0].URL != "https://api.test/items?page=2" || links[1].Params["rel"] != "last" || links[2].Params["title"] != "a, b" {
+		t.Fatalf("bad links: %+v", links)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Token") != "t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		page := req.URL.Query().Get("page")
+		switch page {
+		case "", "1":
+			w.Header().Set("Link", `</items?page=2>; rel="next"`)
+		case "2":
+			w.Header().Set("Link", `</items?page=3>; rel="next"`)
+		}
+		fmt.Fprintf(w, "page %s", page)
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	req, _ := NewRequest("GET", ts.URL+"/items", nil)
+	req.Header.Set("X-Token", "t")
+
+	var got []string
+	for resp, err := range client.Paginate(context.Background(), req) {
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		got = append(got, string(b))
+	}
+	if fmt.Sprint(got) != "[page  page 2 page 3]" {
+		t.Fatalf("bad pages: %q", got)
+	}
+
+	req, _ = NewRequest("GET", ts.URL+"/items", nil)
+	req.Header.Set("X-Token", "t")
+	n := 0
+	for _, err := range (&Paginator{MaxPages: 2}).Pages(context.Background(), client, req) {
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Fatalf("MaxPages not honoured: %d pages", n)
+	}
+}
+
+func TestPaginateOtherOrigin(t *testing.T) {
+	var leaked []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		for _, h := range []string{"Authorization", "Cookie", "X-Api-Key"} {
+			if req.Header.Get(h) != "" {
+				leaked = append(leaked, h)
+			}
+		}
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Link", `<`+other.URL+`/items?page=2>; rel="next"`)
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithDefaultBearerToken("secret"), WithAPIKey("key", APIKeyInHeader, "X-Api-Key"))
+	req, _ := NewRequest("GET", ts.URL+"/items", nil)
+	req.Header.Set("Cookie", "session=1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := 0
+	for _, err := range client.Paginate(ctx, req) {
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		n++
+	}
+	if n != 2 || len(leaked) > 0 {
+		t.Fatalf("%d pages, credentials sent to another origin: %v", n, leaked)
+	}
+	if req.Context() == ctx {
+		t.Fatal("caller's request was modified")
+	}
+}
@@ -0,0 +1,207 @@
+package netgo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ParallelDownload fetches a file as byte ranges over several
+// connections at once. Servers not answering range requests, or not
+// giving a strong ETag or Last-Modified to keep the ranges of one version
+// of the file, get a plain Download instead.
+type ParallelDownload struct {
+	// ChunkSize of each range request, 8MB by default
+	ChunkSize int64
+	// Parallelism is the number of concurrent requests, 4 by default
+	Parallelism int
+}
+
+// Download stores the file at url in path like Client.Download. Every
+// chunk is sent through the retry loop and refetched as a whole, as the
+// retry policy allows, when its body is cut off.
+func (p *ParallelDownload) Download(ctx context.Context, c *Client, url, path string, opts ...RequestOption) (int64, error) {
+	size, validator, ok, err := probeRanges(ctx, c, url, opts)
+	if err != nil {
+		return 0, err
+	}
+	if !ok || validator == "" {
+		return c.Download(ctx, url, path, opts...)
+	}
+
+	chunk, workers := p.ChunkSize, p.Parallelism
+	if chunk <= 0 {
+		chunk = 8 << 20
+	}
+	if workers <= 0 {
+		workers = 4
+	}
+
+	tmp, err := createTemp(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if err := tmp.Truncate(size); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	starts := make(chan int64)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range starts {
+				end := start + chunk - 1
+				if end >= size {
+					end = size - 1
+				}
+				if err := fetchChunk(ctx, c, url, validator, tmp, start, end, opts); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for start := int64(0); start < size; start += chunk {
+		select {
+		case starts <- start:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(starts)
+	wg.Wait()
+	if firstErr != nil {
+		return 0, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	tmp = nil
+	syncDir(filepath.Dir(path))
+	return size, nil
+}
+
+// probeRanges asks for the first byte to learn the size and validator
+// of url; ok is false when the server does not serve ranges
+func probeRanges(ctx context.Context, c *Client, url string, opts []RequestOption) (size int64, validator string, ok bool, err error) {
+	req, err := NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, "", false, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := c.Do(req, opts...)
+	if err != nil {
+		return 0, "", false, err
+	}
+	defer CloseBody(resp)
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, "", false, CheckStatus(resp)
+	}
+	cr := resp.Header.Get("Content-Range")
+	slash := strings.LastIndexByte(cr, '/')
+	if slash < 0 {
+		return 0, "", false, nil
+	}
+	size, err = strconv.ParseInt(cr[slash+1:], 10, 64)
+	if err != nil || size <= 0 {
+		return 0, "", false, nil
+	}
+	if etag := resp.Header.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		validator = etag
+	} else {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	return size, validator, true, nil
+}
+
+// fetchChunk writes bytes start to end of url at their offset in f
+func fetchChunk(ctx context.Context, c *Client, url, validator string, f *os.File, start, end int64, opts []RequestOption) error {
+	rng := fmt.Sprintf("bytes=%d-%d", start, end)
+	for i := 0; ; i++ {
+		req, err := NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Range", rng)
+		if validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+		resp, err := c.Do(req, opts...)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			// the status error reads the body
+			err := CheckStatus(resp)
+			CloseBody(resp)
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("netter: download %s: range %s not served, file changed?", url, rng)
+		}
+		if want := fmt.Sprintf("bytes %d-%d/", start, end); !strings.HasPrefix(resp.Header.Get("Content-Range"), want) {
+			CloseBody(resp)
+			return fmt.Errorf("netter: download %s: got %s for %s", url, resp.Header.Get("Content-Range"), rng)
+		}
+
+		w := &trackedWriter{w: io.NewOffsetWriter(f, start)}
+		n, err := io.Copy(w, io.LimitReader(resp.Body, end-start+1))
+		resp.Body.Close()
+		if w.err != nil {
+			return w.err
+		}
+		if err == nil && n == end-start+1 {
+			return nil
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+
+		policy := c.retryPolicy(req)
+		if i >= policy.Max || ctx.Err() != nil {
+			return err
+		}
+		wait := policy.backoff(policy.WaitMin, policy.WaitMax, i)
+		c.log().Printf("netter: download %s range %s cut off: %v, refetching in %s", url, rng, err, wait)
+		timer := c.clock().NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package netgo
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+)
+
+// Path fills the {name} placeholder of the request URL path with value,
+// escaped as a single path segment, e.g.
+//
+//	client.Get("/users/{id}/orders/{order}", netgo.Path("id", 42), netgo.Path("order", oid))
+//
+// Values are formatted like form fields, see EncodeForm.
+func Path(name string, value interface{}) RequestOption {
+	return func(r *Request) {
+		if r.params == nil {
+			r.params = make(map[string]interface{})
+		}
+		r.params[name] = value
+	}
+}
+
+// placeholders match {name} in an escaped path
+var placeholders = regexp.MustCompile(`(?:\{|%7[Bb])([A-Za-z0-9_.\-]+)(?:\}|%7[Dd])`)
+
+// expandPath substitutes the Path parameters of r into its URL
+func (r *Request) expandPath() error {
+	if r.params == nil {
+		return nil
+	}
+	var err error
+	used := make(map[string]bool, len(r.params))
+	raw := placeholders.ReplaceAllStringFunc(r.URL.EscapedPath(), func(m string) string {
+		name := placeholders.FindStringSubmatch(m)[1]
+		value, ok := r.params[name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("netter: missing path parameter %q", name)
+			}
+			return m
+		}
+		used[name] = true
+		if value == nil {
+			if err == nil {
+				err = fmt.Errorf("netter: path parameter %q is nil", name)
+			}
+			return m
+		}
+		s, ferr := formatValue(reflect.ValueOf(value))
+		if ferr != nil && err == nil {
+			err = fmt.Errorf("netter: path parameter %q: %v", name, ferr)
+		}
+		return url.PathEscape(s)
+	})
+	if err != nil {
+		return err
+	}
+	for name := range r.params {
+		if !used[name] {
+			return fmt.Errorf("netter: path parameter %q not in %s", name, r.URL.Path)
+		}
+	}
+
+	path, err := url.PathUnescape(raw)
+	if err != nil {
+		return err
+	}
+	u := *r.URL
+	u.Path, u.RawPath = path, raw
+	r.URL = &u
+	r.params = nil
+	return nil
+}
@@ -0,0 +1,92 @@
+package netgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrStopPoll is returned by a poll handler to end polling without error
+var ErrStopPoll = errors.New("netter: stop polling")
+
+// Poller repeats a long-poll request. A response is followed right away
+// by the next poll; failures back off as the retry policy of the client
+// does between attempts.
+type Poller struct {
+	// Next prepares req for the poll after resp, e.g. to carry a cursor
+	// over. It runs before the handler. PollETag by default.
+	Next func(req *Request, resp *http.Response) error
+	// MaxFailures ends polling after that many failures in a row when
+	// positive, returning the last error
+	MaxFailures int
+}
+
+// PollETag sends the ETag of resp as If-None-Match with the next poll
+func PollETag(req *Request, resp *http.Response) error {
+	if etag := resp.Header.Get("Etag"); etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	return nil
+}
+
+// Poll sends req again and again until ctx is done, passing every 2xx
+// response to handler, see Poller
+func (c *Client) Poll(ctx context.Context, req *Request, handler func(*http.Response) error) error {
+	return (&Poller{}).Poll(ctx, c, req, handler)
+}
+
+// Poll sends req through the retry loop of c until ctx is done and
+// hands every 2xx response to handler, closing its body afterwards. 304
+// Not Modified answers are polled again without calling handler. An
+// error of handler ends polling and is returned, unless it is
+// ErrStopPoll.
+func (p *Poller) Poll(ctx context.Context, c *Client, req *Request, handler func(*http.Response) error) error {
+	next := p.Next
+	if next == nil {
+		next = PollETag
+	}
+	req.Request = req.Request.WithContext(ctx)
+	policy := c.retryPolicy(req)
+
+	for failures := 0; ; {
+		resp, err := c.Do(req)
+		if err == nil && resp.StatusCode != http.StatusNotModified {
+			err = CheckStatus(resp)
+		}
+		if err == nil {
+			failures = 0
+			if err := next(req, resp); err != nil {
+				CloseBody(resp)
+				return err
+			}
+			if resp.StatusCode != http.StatusNotModified {
+				err = handler(resp)
+			}
+			CloseBody(resp)
+			if err == ErrStopPoll {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		failures++
+		if p.MaxFailures > 0 && failures >= p.MaxFailures {
+			return err
+		}
+		wait := policy.backoff(policy.WaitMin, policy.WaitMax, failures-1)
+		c.logger(req).Printf("netter: poll %s failed: %v, polling again in %s", req.URL, err, wait)
+		timer := c.clock().NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
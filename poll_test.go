@@ -0,0 +1,51 @@
+package netgo
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		switch n {
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		case 3:
+			if req.Header.Get("If-None-Match") != `"1"` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Etag", strconv.Quote(strconv.Itoa(n)))
+		w.Write([]byte(strconv.Itoa(n)))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	req, _ := NewRequest("GET", ts.URL, nil)
+	var got []string
+	err := client.Poll(context.Background(), req, func(resp *http.Response) error {
+		b, _ := ioutil.ReadAll(resp.Body)
+		got = append(got, string(b))
+		if len(got) == 2 {
+			return ErrStopPoll
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "1" || got[1] != "4" || n != 4 {
+		t.Fatalf("handled %v in %d polls", got, n)
+	}
+}
@@ -0,0 +1,181 @@
+package netgo
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+)
+
+// PoolStats describes the connections of a client to one host
+type PoolStats struct {
+	// Idle connections wait in the pool, Active ones serve a request
+	// until its response body is closed
+	Idle, Active int
+	// New and Reused count the requests sent over a fresh connection and
+	// over one taken from the pool
+	New, Reused int64
+	// Handshakes and HandshakeErrors count the TLS handshakes done
+	Handshakes, HandshakeErrors int64
+}
+
+// WithPoolStats tracks the connection pool of the client for PoolStats.
+// With keep-alives disabled, as by default, every request shows up as
+// New.
+func WithPoolStats() Option {
+	return withDialer(func(d *dialer) {
+		d.stats = &poolStats{
+			hosts: make(map[string]*PoolStats),
+			conns: make(map[*trackedConn]struct{}),
+		}
+	})
+}
+
+// PoolStats returns the connection pool statistics per host:port
+// connected to, or nil without WithPoolStats
+func (c *Client) PoolStats() map[string]PoolStats {
+	s := c.poolStats()
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]PoolStats, len(s.hosts))
+	for host, st := range s.hosts {
+		out[host] = *st
+	}
+	for tc := range s.conns {
+		st := out[tc.host]
+		if tc.inFlight > 0 {
+			st.Active++
+		} else {
+			st.Idle++
+		}
+		out[tc.host] = st
+	}
+	return out
+}
+
+func (c *Client) poolStats() *poolStats {
+	if c.dialer == nil {
+		return nil
+	}
+	return c.dialer.stats
+}
+
+type poolStats struct {
+	mu    sync.Mutex
+	hosts map[string]*PoolStats
+	// conns are the open connections that served a request
+	conns map[*trackedConn]struct{}
+}
+
+// trackedConn leaves the pool statistics once closed
+type trackedConn struct {
+	net.Conn
+	stats *poolStats
+	// host and inFlight are guarded by stats.mu
+	host     string
+	inFlight int
+}
+
+func (tc *trackedConn) Close() error {
+	tc.stats.mu.Lock()
+	delete(tc.stats.conns, tc)
+	tc.stats.mu.Unlock()
+	return tc.Conn.Close()
+}
+
+// tracked finds the trackedConn under the TLS or other wrappers of conn
+func tracked(conn net.Conn) *trackedConn {
+	for conn != nil {
+		if tc, ok := conn.(*trackedConn); ok {
+			return tc
+		}
+		inner, ok := conn.(interface{ NetConn() net.Conn })
+		if !ok {
+			return nil
+		}
+		conn = inner.NetConn()
+	}
+	return nil
+}
+
+func (s *poolStats) host(host string) *PoolStats {
+	st, ok := s.hosts[host]
+	if !ok {
+		st = &PoolStats{}
+		s.hosts[host] = st
+	}
+	return st
+}
+
+// scope records the connection use of req; finish must be called with
+// the attempt result
+func (s *poolStats) scope(req *http.Request) (*http.Request, func(*http.Response, error) (*http.Response, error)) {
+	if s == nil {
+		return req, func(resp *http.Response, err error) (*http.Response, error) { return resp, err }
+	}
+	var (
+		hostPort string
+		got      *trackedConn
+	)
+	release := func() {
+		s.mu.Lock()
+		if got != nil {
+			got.inFlight--
+			got = nil
+		}
+		s.mu.Unlock()
+	}
+
+	trace := &httptrace.ClientTrace{
+		GetConn: func(hp string) {
+			s.mu.Lock()
+			hostPort = hp
+			s.mu.Unlock()
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if st := s.host(hostPort); err == nil {
+				st.Handshakes++
+			} else {
+				st.HandshakeErrors++
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			// a redirect moves on from the connection of the previous hop
+			release()
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if st := s.host(hostPort); info.Reused {
+				st.Reused++
+			} else {
+				st.New++
+			}
+			if tc := tracked(info.Conn); tc != nil {
+				if tc.host == "" {
+					tc.host = hostPort
+					s.conns[tc] = struct{}{}
+				}
+				tc.inFlight++
+				got = tc
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	return req, func(resp *http.Response, err error) (*http.Response, error) {
+		if err != nil {
+			release()
+			return resp, err
+		}
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			// upgraded connections stay active until closed
+			resp.Body = &cancelBody{resp.Body, release}
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,74 @@
+package netgo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Preconnect dials, and for https completes the TLS handshake with,
+// every host at once and parks the connections in the pool, so the
+// first requests after a deploy or an idle period skip DNS, TCP and TLS.
+// Hosts are host[:port] for https or URLs of which only the scheme and
+// host count; without hosts the BaseURL host is warmed up. Hosts go
+// through the URLPolicy and host policy like any request. Connections
+// are primed with OPTIONS *, the HTTP no-op, and need keep-alives, which
+// the default transport disables: create the client WithKeepAlives.
+func (c *Client) Preconnect(ctx context.Context, hosts ...string) error {
+	life := c.lifecycle()
+	if !life.enter() {
+		return ErrClientClosed
+	}
+	defer life.leave()
+
+	if tr, ok := c.Inner.Transport.(*http.Transport); ok && tr.DisableKeepAlives {
+		return errors.New("netter: preconnect needs a transport with keep-alives")
+	}
+	if len(hosts) == 0 && c.BaseURL != "" {
+		hosts = []string{c.BaseURL}
+	}
+
+	errs := make([]error, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			errs[i] = c.preconnect(ctx, host)
+		}(i, host)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (c *Client) preconnect(ctx context.Context, host string) error {
+	u := &url.URL{Scheme: "https", Host: host}
+	if strings.Contains(host, "://") {
+		parsed, err := url.Parse(host)
+		if err != nil {
+			return err
+		}
+		u = &url.URL{Scheme: parsed.Scheme, Host: parsed.Host}
+	}
+	if err := c.checkURL(ctx, u); err != nil {
+		return err
+	}
+	u.Opaque = "*"
+
+	req, err := http.NewRequestWithContext(c.withHostPolicy(ctx), "OPTIONS", "/", nil)
+	if err != nil {
+		return err
+	}
+	req.URL, req.Host = u, u.Host
+	req, track := c.poolStats().scope(req)
+	resp, err := track(c.Inner.Do(req))
+	if err != nil {
+		return err
+	}
+	// whatever the status, the drained connection goes back to the pool
+	c.drainBody(resp.Body)
+	return nil
+}
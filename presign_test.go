@@ -0,0 +1,51 @@
+package netgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	s := &URLSigner{Secret: []byte("shared")}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch err := s.Verify(req); err {
+		case nil:
+		case ErrURLExpired:
+			w.WriteHeader(http.StatusGone)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	status := func(rawurl string) int {
+		resp, err := client.Get(rawurl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	link, err := s.Sign("GET", ts.URL+"/files/report.pdf?v=2", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := status(link); got != http.StatusOK {
+		t.Fatalf("valid link: %d", got)
+	}
+	if got := status(strings.Replace(link, "v=2", "v=3", 1)); got != http.StatusForbidden {
+		t.Fatalf("tampered link: %d", got)
+	}
+	expired, _ := s.Sign("GET", ts.URL+"/files/report.pdf", time.Now().Add(-time.Second))
+	if got := status(expired); got != http.StatusGone {
+		t.Fatalf("expired link: %d", got)
+	}
+	put, _ := s.Sign("PUT", ts.URL+"/files/report.pdf", time.Now().Add(time.Minute))
+	if got := status(put); got != http.StatusForbidden {
+		t.Fatalf("link for another method: %d", got)
+	}
+}
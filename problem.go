@@ -0,0 +1,49 @@
+package netgo
+
+import (
+	"encoding/json"
+	"mime"
+)
+
+// ProblemDetails is an RFC 7807 problem+json error body
+type ProblemDetails struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions holds the members not defined by RFC 7807
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler, keeping extension members
+func (p *ProblemDetails) UnmarshalJSON(b []byte) error {
+	type plain ProblemDetails
+	if err := json.Unmarshal(b, (*plain)(p)); err != nil {
+		return err
+	}
+	var all map[string]interface{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(all, k)
+	}
+	if len(all) > 0 {
+		p.Extensions = all
+	}
+	return nil
+}
+
+// parseProblem decodes body when contentType is application/problem+json
+func parseProblem(contentType string, body []byte) *ProblemDetails {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mt != "application/problem+json" {
+		return nil
+	}
+	p := new(ProblemDetails)
+	if json.Unmarshal(body, p) != nil {
+		return nil
+	}
+	return p
+}
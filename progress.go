@@ -0,0 +1,96 @@
+package netgo
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// ProgressFunc is told how many bytes of a body were transferred so far
+// and its total size, -1 when unknown
+type ProgressFunc func(written, total int64)
+
+// defaultProgressInterval spaces out progress calls unless
+// WithProgressInterval says otherwise
+const defaultProgressInterval = 100 * time.Millisecond
+
+type progress struct {
+	fn       ProgressFunc
+	interval time.Duration
+}
+
+// WithProgress reports the transfer of the request body while it is
+// sent and of the response body while it is read. fn is called at most
+// once per interval, see WithProgressInterval, and once more when a body
+// is complete. A retried attempt reports its body from zero again.
+func WithProgress(fn ProgressFunc) RequestOption {
+	return func(r *Request) {
+		if r.progress == nil {
+			r.progress = &progress{interval: defaultProgressInterval}
+		}
+		r.progress.fn = fn
+	}
+}
+
+// WithProgressInterval sets the minimum time between progress calls,
+// 100ms by default; zero reports every read
+func WithProgressInterval(d time.Duration) RequestOption {
+	return func(r *Request) {
+		if r.progress == nil {
+			r.progress = &progress{}
+		}
+		r.progress.interval = d
+	}
+}
+
+// watchUpload reports the body of every attempt of req
+func (p *progress) watchUpload(req *Request) {
+	if p == nil || p.fn == nil || req.body == nil {
+		return
+	}
+	open, total := req.body, req.ContentLength
+	if total == 0 {
+		total = -1
+	}
+	req.body = func() (io.Reader, error) {
+		src, err := open()
+		if err != nil {
+			return nil, err
+		}
+		return &progressReader{ReadCloser: toReadCloser(src), p: p, total: total}, nil
+	}
+	req.GetBody = getBody(req.body)
+}
+
+// watchDownload reports the body of resp as it is read
+func (p *progress) watchDownload(resp *http.Response) {
+	if p == nil || p.fn == nil || resp == nil || resp.Body == nil {
+		return
+	}
+	resp.Body = &progressReader{ReadCloser: resp.Body, p: p, total: resp.ContentLength}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	p       *progress
+	total   int64
+	written int64
+	last    time.Time
+	done    bool
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.written += int64(n)
+	if r.done {
+		return n, err
+	}
+	if err == io.EOF {
+		r.done = true
+		r.p.fn(r.written, r.total)
+	} else if now := time.Now(); n > 0 && now.Sub(r.last) >= r.p.interval {
+		r.last = now
+		r.p.fn(r.written, r.total)
+	}
+	return n, err
+}
@@ -0,0 +1,194 @@
+package netgo
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WithProxy sends this request through the http, https or socks5 proxy
+// u, overriding Client.ProxyFunc, Client.ProxyPool and the proxy of the
+// client transport. Such requests use a connection pool per proxy.
+func WithProxy(u *url.URL) RequestOption {
+	return func(r *Request) {
+		r.proxy = u
+	}
+}
+
+// DialViaProxy opens a TCP connection to addr through the proxy the
+// client's transport would use for it, tunnelling with CONNECT or SOCKS5,
+// so non-HTTP protocols can share the proxy configuration. Without a
+// proxy addr is dialed directly. addr is subject to the URLPolicy and
+// host policy as an https URL would be. Failed dials, 5xx proxy answers
+// and SOCKS5 server failures are retried with the client's backoff.
+func (c *Client) DialViaProxy(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("netter: cannot tunnel network %q", network)
+	}
+
+	life := c.lifecycle()
+	if !life.enter() {
+		return nil, ErrClientClosed
+	}
+	defer life.leave()
+
+	target := &url.URL{Scheme: "https", Host: addr}
+	if err := c.checkURL(ctx, target); err != nil {
+		return nil, err
+	}
+	ctx = c.withHostPolicy(ctx)
+
+	tr, _ := c.Inner.Transport.(*http.Transport)
+	if tr == nil {
+		tr = defaultTransport
+	}
+	dial := tr.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	var proxy *url.URL
+	if tr.Proxy != nil {
+		// the proxy is chosen as for a TLS request to addr
+		probe := &http.Request{Method: "CONNECT", URL: target, Header: http.Header{}}
+		var err error
+		if proxy, err = tr.Proxy(probe.WithContext(ctx)); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; ; i++ {
+		var (
+			conn      net.Conn
+			err       error
+			retryable = true
+		)
+		if proxy == nil {
+			conn, err = dial(ctx, network, addr)
+		} else if proxy.Scheme == "socks5" || proxy.Scheme == "socks5h" {
+			conn, retryable, err = socksTunnel(ctx, dial, proxy, addr)
+		} else {
+			conn, retryable, err = c.connectTunnel(ctx, tr, dial, proxy, addr)
+		}
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		c.log().Printf("netter: dial %s via proxy failed: %v", addr, err)
+
+		remain := c.Retry.Max - i
+		if !retryable || remain <= 0 {
+			return nil, err
+		}
+		wait := c.Retry.backoff(c.Retry.WaitMin, c.Retry.WaitMax, i)
+		c.log().Printf("netter: dial %s retrying in %s (%d left)", addr, wait, remain)
+
+		timer := c.clock().NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-life.abort:
+			timer.Stop()
+			return nil, ErrClientClosed
+		case <-timer.C():
+		}
+	}
+}
+
+func (c *Client) connectTunnel(ctx context.Context, tr *http.Transport,
+	dial func(context.Context, string, string) (net.Conn, error), proxy *url.URL, addr string) (net.Conn, bool, error) {
+
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		port := "80"
+		if proxy.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), port)
+	}
+
+	conn, err := dial(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, true, err
+	}
+	if proxy.Scheme == "https" {
+		cfg := &tls.Config{}
+		if tr.TLSClientConfig != nil {
+			cfg = tr.TLSClientConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = proxy.Hostname()
+		}
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, true, err
+		}
+		conn = tlsConn
+	}
+
+	connect := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: http.Header{},
+	}
+	for k, v := range tr.ProxyConnectHeader {
+		connect.Header[k] = v
+	}
+	if u := proxy.User; u != nil {
+		pass, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + pass))
+		connect.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	// the handshake must not outlive ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	if err := connect.Write(conn); err != nil {
+		conn.Close()
+		return nil, true, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, connect)
+	if err != nil {
+		conn.Close()
+		return nil, true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, resp.StatusCode >= 500, fmt.Errorf("netter: proxy %s refused CONNECT %s: %s", proxy.Host, addr, resp.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, false, nil
+	}
+	return conn, false, nil
+}
+
+// bufferedConn serves bytes read ahead while parsing the CONNECT answer
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
@@ -0,0 +1,360 @@
+package netgo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDialViaProxy(t *testing.T) {
+	echo, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer echo.Close()
+	go func() {
+		for {
+			conn, err := echo.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "CONNECT" || req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		upstream, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+		go func() {
+			_, _ = io.Copy(upstream, conn)
+			upstream.Close()
+		}()
+		_, _ = io.Copy(conn, upstream)
+		conn.Close()
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "pass")
+	client := &Client{
+		Inner:  &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}},
+		Logger: NewClient().Logger,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := client.DialViaProxy(ctx, "tcp", echo.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "hello\n" {
+		t.Fatalf("bad echo through tunnel: %q %v", line, err)
+	}
+
+	proxyURL.User = nil
+	if _, err := client.DialViaProxy(ctx, "tcp", echo.Addr().String()); err == nil {
+		t.Fatal("expected proxy auth error")
+	}
+}
+
+func TestDialViaProxyPolicy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	client := NewClient(WithRetry(Retry{}))
+	client.Inner.Transport.(*http.Transport).Proxy = nil
+	client.AllowedHosts = []string{"example.org"}
+	if _, err := client.DialViaProxy(context.Background(), "tcp", ln.Addr().String()); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("dial to a host not allowed: %v", err)
+	}
+	// names are checked as they are dialed
+	client.AllowedHosts = []string{"10.0.0.0/8"}
+	if _, err := client.DialViaProxy(context.Background(), "tcp", "localhost:"+port); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("dial to an address not allowed: %v", err)
+	}
+
+	client.AllowedHosts = nil
+	conn, err := client.DialViaProxy(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	client.Close(context.Background())
+	if _, err := client.DialViaProxy(context.Background(), "tcp", ln.Addr().String()); err != ErrClientClosed {
+		t.Fatalf("dial on a closed client: %v", err)
+	}
+}
+
+// socksServer runs a SOCKS5 proxy requiring user:pass that resolves the
+// names in hosts and records them
+func socksServer(t *testing.T, hosts map[string]string) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	names := make(chan string, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 512)
+				io.ReadFull(conn, buf[:2])
+				io.ReadFull(conn, buf[:buf[1]])
+				conn.Write([]byte{5, 2})
+				io.ReadFull(conn, buf[:2])
+				user := make([]byte, buf[1])
+				io.ReadFull(conn, user)
+				io.ReadFull(conn, buf[:1])
+				pass := make([]byte, buf[0])
+				io.ReadFull(conn, pass)
+				if string(user) != "user" || string(pass) != "pass" {
+					conn.Write([]byte{1, 1})
+					return
+				}
+				conn.Write([]byte{1, 0})
+
+				io.ReadFull(conn, buf[:5])
+				if buf[3] != 3 {
+					conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				name := make([]byte, buf[4])
+				io.ReadFull(conn, name)
+				io.ReadFull(conn, buf[:2])
+				names <- string(name)
+				upstream, err := net.Dial("tcp", hosts[string(name)])
+				if err != nil {
+					conn.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer upstream.Close()
+				conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+				go io.Copy(upstream, conn)
+				io.Copy(conn, upstream)
+			}()
+		}
+	}()
+	return ln.Addr().String(), names
+}
+
+func TestSOCKS5(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, req.Host)
+	}))
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	proxyAddr, names := socksServer(t, map[string]string{"api.internal": ts.Listener.Addr().String()})
+	target := "http://api.internal:" + port + "/"
+
+	get := func(client *Client, opts ...RequestOption) {
+		t.Helper()
+		resp, err := client.Get(target, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "api.internal:"+port {
+			t.Fatalf("body %q", body)
+		}
+		if name := <-names; name != "api.internal" {
+			t.Fatalf("proxy resolved %q", name)
+		}
+	}
+
+	// the name only resolves at the proxy
+	client := NewClient(WithSOCKS5(proxyAddr, "user", "pass"), WithRetry(Retry{}))
+	get(client)
+
+	client = NewClient(WithRetry(Retry{}))
+	get(client, WithSOCKS5Proxy(proxyAddr, "user", "pass"))
+	if _, err := client.Get(target, WithSOCKS5Proxy(proxyAddr, "user", "wrong")); err == nil {
+		t.Fatal("expected auth failure")
+	}
+
+	client = NewClient(WithSOCKS5(proxyAddr, "user", "pass"))
+	conn, err := client.DialViaProxy(context.Background(), "tcp", "api.internal:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.0\r\nHost: tunnel\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "tunnel" {
+		t.Fatalf("body %q", body)
+	}
+	if name := <-names; name != "api.internal" {
+		t.Fatalf("proxy resolved %q", name)
+	}
+}
+
+func TestProxyPool(t *testing.T) {
+	var proxies []*url.URL
+	for _, name := range []string{"dead", "a", "b"} {
+		name := name
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			io.WriteString(w, name)
+		}))
+		defer ts.Close()
+		u, _ := url.Parse(ts.URL)
+		proxies = append(proxies, u)
+		if name == "dead" {
+			ts.Close()
+		}
+	}
+	pool := &ProxyPool{Proxies: proxies, MaxFailures: 1}
+	client := NewClient(WithRetry(Retry{}))
+	client.ProxyPool = pool
+
+	via := func(target string) string {
+		t.Helper()
+		resp, err := client.Get(target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return string(body)
+	}
+
+	// the dead proxy fails over to the next one and then sits out
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, via("http://example.test/"))
+	}
+	if want := []string{"a", "b", "a", "b"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("proxies %v, want %v", got, want)
+	}
+	if healthy := pool.Healthy(); len(healthy) != 2 || healthy[0] != proxies[1] {
+		t.Fatalf("healthy %v", healthy)
+	}
+
+	pool.Rotation = ProxyStickyPerHost
+	first := via("http://one.test/")
+	for i := 0; i < 3; i++ {
+		if got := via("http://one.test/"); got != first {
+			t.Fatalf("sticky proxy changed from %s to %s", first, got)
+		}
+	}
+
+	client.ProxyPool = &ProxyPool{Proxies: proxies, Failover: -1}
+	if _, err := client.Get("http://example.test/"); err == nil {
+		t.Fatal("expected the dead proxy to fail without failover")
+	}
+
+	// proxies added after first use join the rotation
+	pool = &ProxyPool{Proxies: proxies[1:2]}
+	client.ProxyPool = pool
+	via("http://example.test/")
+	pool.Proxies = append(pool.Proxies, proxies[2])
+	got = nil
+	for i := 0; i < 2; i++ {
+		got = append(got, via("http://example.test/"))
+	}
+	if want := []string{"b", "a"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("proxies %v, want %v", got, want)
+	}
+}
+
+func TestProxyFunc(t *testing.T) {
+	proxies := map[string]*url.URL{}
+	for _, name := range []string{"eu", "us", "pinned"} {
+		name := name
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			io.WriteString(w, name+" "+req.URL.String())
+		}))
+		defer ts.Close()
+		proxies[name], _ = url.Parse(ts.URL)
+	}
+
+	client := NewClient(WithRetry(Retry{}))
+	client.ProxyFunc = func(req *Request) (*url.URL, error) {
+		switch tenant := req.Header.Get("X-Tenant"); tenant {
+		case "":
+			return nil, nil
+		case "eu", "us":
+			return proxies[tenant], nil
+		default:
+			return nil, fmt.Errorf("unknown tenant %q", tenant)
+		}
+	}
+
+	for tenant, want := range map[string]string{"eu": "eu", "us": "us"} {
+		resp, err := client.Get("http://api.test/v1", WithHeader("X-Tenant", tenant))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want+" http://api.test/v1" {
+			t.Fatalf("tenant %s went through %q", tenant, body)
+		}
+	}
+
+	resp, err := client.Get("http://api.test/v1", WithHeader("X-Tenant", "eu"), WithProxy(proxies["pinned"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pinned http://api.test/v1" {
+		t.Fatalf("WithProxy ignored: %q", body)
+	}
+
+	if _, err := client.Get("http://api.test/v1", WithHeader("X-Tenant", "mars")); err == nil {
+		t.Fatal("expected ProxyFunc error")
+	}
+}
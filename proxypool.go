@@ -0,0 +1,245 @@
+package netgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ProxyRotation chooses the proxy of a ProxyPool for each attempt
+type ProxyRotation int
+
+const (
+	// ProxyRoundRobin takes the healthy proxies in turn
+	ProxyRoundRobin ProxyRotation = iota
+	// ProxyRandom takes a healthy proxy at random
+	ProxyRandom
+	// ProxyStickyPerHost keeps sending the requests to a host through
+	// the same proxy while it stays healthy
+	ProxyStickyPerHost
+)
+
+// ProxyPool spreads requests across proxies. Proxies failing
+// MaxFailures times in a row sit out a cooldown, after which a single
+// attempt decides whether they rejoin. An attempt failing at the proxy,
+// before reaching the upstream, is sent again at once through another
+// proxy. Set it as Client.ProxyPool; requests given a proxy by
+// WithProxy or Client.ProxyFunc bypass it.
+type ProxyPool struct {
+	// Proxies are http, https, socks5 or socks5h URLs. They may be
+	// changed between requests, not while requests are in flight.
+	Proxies  []*url.URL
+	Rotation ProxyRotation
+	// MaxFailures in a row mark a proxy unhealthy, 3 by default
+	MaxFailures int
+	// Cooldown of unhealthy proxies, 30 seconds by default
+	Cooldown time.Duration
+	// Failover bounds the other proxies an attempt moves on to, 2 by
+	// default; negative disables failover
+	Failover int
+
+	mu     sync.Mutex
+	health []proxyHealth
+	next   int
+	sticky map[string]int
+}
+
+type proxyHealth struct {
+	failures  int
+	downUntil time.Time
+}
+
+// proxyRefusedError reports a proxy answering CONNECT with an error
+type proxyRefusedError struct {
+	proxy, addr, status string
+}
+
+func (e *proxyRefusedError) Error() string {
+	return fmt.Sprintf("netter: proxy %s refused CONNECT %s: %s", e.proxy, e.addr, e.status)
+}
+
+// refuseConnect types the CONNECT refusals of a transport, which
+// http.Transport otherwise reports as bare status text
+func refuseConnect(next func(context.Context, *url.URL, *http.Request, *http.Response) error) func(context.Context, *url.URL, *http.Request, *http.Response) error {
+	return func(ctx context.Context, proxy *url.URL, connect *http.Request, resp *http.Response) error {
+		if next != nil {
+			if err := next(ctx, proxy, connect, resp); err != nil {
+				return err
+			}
+		}
+		if resp.StatusCode != http.StatusOK {
+			return &proxyRefusedError{proxy: proxy.Host, addr: connect.Host, status: resp.Status}
+		}
+		return nil
+	}
+}
+
+// proxyFailed tells whether an attempt failed at the proxy
+func proxyFailed(resp *http.Response, err error) bool {
+	if err == nil {
+		return resp.StatusCode == http.StatusProxyAuthRequired
+	}
+	var op *net.OpError
+	if errors.As(err, &op) && (op.Op == "proxyconnect" || op.Op == "socks connect") {
+		return true
+	}
+	var refused *proxyRefusedError
+	return errors.As(err, &refused)
+}
+
+// Healthy returns the proxies not sitting out a cooldown
+func (p *ProxyPool) Healthy() []*url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.init()
+	now := time.Now()
+	var healthy []*url.URL
+	for i, u := range p.Proxies {
+		if !now.Before(p.health[i].downUntil) {
+			healthy = append(healthy, u)
+		}
+	}
+	return healthy
+}
+
+// init sizes the health records to Proxies, which may have grown or
+// shrunk since; records follow their index
+func (p *ProxyPool) init() {
+	if p.sticky == nil {
+		p.sticky = make(map[string]int)
+	}
+	if n := len(p.Proxies); len(p.health) != n {
+		health := make([]proxyHealth, n)
+		copy(health, p.health)
+		p.health = health
+		for host, i := range p.sticky {
+			if i >= n {
+				delete(p.sticky, host)
+			}
+		}
+	}
+}
+
+// pick returns the index of the proxy for an attempt to host, skipping
+// those tried already
+func (p *ProxyPool) pick(host string, tried []bool) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.init()
+
+	now := time.Now()
+	var usable []int
+	for i := range p.Proxies {
+		if !tried[i] && !now.Before(p.health[i].downUntil) {
+			usable = append(usable, i)
+		}
+	}
+	if len(usable) == 0 {
+		// all are down: rather the one recovering first than none
+		best := -1
+		for i := range p.Proxies {
+			if !tried[i] && (best < 0 || p.health[i].downUntil.Before(p.health[best].downUntil)) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	switch p.Rotation {
+	case ProxyRandom:
+		return usable[rand.Intn(len(usable))]
+	case ProxyStickyPerHost:
+		if i, ok := p.sticky[host]; ok {
+			for _, j := range usable {
+				if i == j {
+					return i
+				}
+			}
+		}
+		i := p.roundRobin(usable)
+		p.sticky[host] = i
+		return i
+	default:
+		return p.roundRobin(usable)
+	}
+}
+
+// roundRobin returns the first usable proxy from the rotation position
+func (p *ProxyPool) roundRobin(usable []int) int {
+	i := usable[0]
+	for _, j := range usable {
+		if j >= p.next {
+			i = j
+			break
+		}
+	}
+	p.next = i + 1
+	return i
+}
+
+func (p *ProxyPool) record(i int, failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.init()
+	if i >= len(p.health) {
+		return
+	}
+	h := &p.health[i]
+	if !failed {
+		h.failures = 0
+		return
+	}
+	h.failures++
+	limit := p.MaxFailures
+	if limit <= 0 {
+		limit = 3
+	}
+	if h.failures >= limit {
+		h.downUntil = time.Now().Add(durationOr(p.Cooldown, 30*time.Second))
+	}
+}
+
+// roundTrip sends req through the pool, moving on to other proxies
+// while the chosen ones fail
+func (p *ProxyPool) roundTrip(c *Client, r *Request, req *http.Request) (*http.Response, error) {
+	if len(p.Proxies) == 0 {
+		return c.roundTripVia(r, req, nil)
+	}
+	failover := p.Failover
+	if failover == 0 {
+		failover = 2
+	}
+	tried := make([]bool, len(p.Proxies))
+	for n := 0; ; n++ {
+		i := p.pick(req.URL.Host, tried)
+		tried[i] = true
+		resp, err := c.roundTripVia(r, req, p.Proxies[i])
+		failed := proxyFailed(resp, err)
+		p.record(i, failed)
+		if !failed || n >= failover || n+1 == len(p.Proxies) || req.Context().Err() != nil {
+			return resp, err
+		}
+		cause := err
+		if cause == nil {
+			cause = errors.New(resp.Status)
+		}
+		c.logger(r).Printf("netter: proxy %s failed, trying another: %v", p.Proxies[i].Host, cause)
+
+		if r.body != nil {
+			body, err := r.body()
+			if err != nil {
+				return resp, err
+			}
+			req.Body = toReadCloser(body)
+		}
+		if resp != nil {
+			c.drainBody(resp.Body)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package netgo
+
+import (
+	"net/url"
+)
+
+// EncodeQuery encodes struct v as query values like EncodeForm, reading
+// names and options from the `query:"page,omitempty"` tag
+func EncodeQuery(v interface{}) (url.Values, error) {
+	return encodeValues(v, "query")
+}
+
+// WithQueryStruct adds the fields of struct v, encoded by EncodeQuery, to
+// the request query; encoding errors are returned by Do
+func WithQueryStruct(v interface{}) RequestOption {
+	return func(r *Request) {
+		values, err := EncodeQuery(v)
+		if err != nil {
+			r.fail(err)
+			return
+		}
+		q := r.URL.Query()
+		for k, vs := range values {
+			q[k] = append(q[k], vs...)
+		}
+		r.URL.RawQuery = q.Encode()
+	}
+}
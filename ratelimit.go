@@ -0,0 +1,122 @@
+package netgo
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitThrottle reads the rate limit headers of responses and holds
+// back requests to a host whose remaining quota is used up, until the
+// quota resets, rather than running into 429s. It understands
+// X-RateLimit-Remaining/Reset as well as the IETF draft RateLimit and
+// RateLimit-Remaining/Reset headers.
+type RateLimitThrottle struct {
+	// Reserve is the remaining quota at which requests start waiting
+	Reserve int
+	// MaxDelay bounds a single wait, one minute by default
+	MaxDelay time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*rateLimitState
+}
+
+type rateLimitState struct {
+	remaining int
+	reset     time.Time
+}
+
+// delay reserves a request to host and returns how long it has to wait
+func (t *RateLimitThrottle) delay(host string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.hosts[host]
+	if !ok || !now.Before(s.reset) {
+		return 0
+	}
+	if s.remaining > t.Reserve {
+		s.remaining--
+		return 0
+	}
+	d := s.reset.Sub(now)
+	max := t.MaxDelay
+	if max == 0 {
+		max = time.Minute
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+// wait blocks until host may be requested
+func (t *RateLimitThrottle) wait(ctx context.Context, c *Client, host string) error {
+	d := t.delay(host, time.Now())
+	if d <= 0 {
+		return nil
+	}
+	timer := c.clock().NewTimer(d)
+	select {
+	case <-ctx.Done():
+		timer.Stop()
+		return ctx.Err()
+	case <-timer.C():
+		return nil
+	}
+}
+
+// record keeps the quota announced by resp
+func (t *RateLimitThrottle) record(host string, resp *http.Response, now time.Time) {
+	if resp == nil {
+		return
+	}
+	remaining, reset, ok := parseRateLimit(resp.Header, now)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.hosts == nil {
+		t.hosts = make(map[string]*rateLimitState)
+	}
+	t.hosts[host] = &rateLimitState{remaining: remaining, reset: reset}
+}
+
+// parseRateLimit returns the remaining quota and its reset time
+func parseRateLimit(h http.Header, now time.Time) (int, time.Time, bool) {
+	remaining, reset := h.Get("X-RateLimit-Remaining"), h.Get("X-RateLimit-Reset")
+	if remaining == "" {
+		remaining, reset = h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset")
+	}
+	if remaining == "" {
+		// RateLimit: limit=100, remaining=50, reset=5
+		for _, part := range strings.Split(h.Get("RateLimit"), ",") {
+			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch strings.ToLower(kv[0]) {
+			case "remaining", "r":
+				remaining = kv[1]
+			case "reset", "t":
+				reset = kv[1]
+			}
+		}
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(remaining))
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	secs, err := strconv.ParseInt(strings.TrimSpace(reset), 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	// epoch timestamps, as sent by GitHub, versus delta seconds
+	if secs > 1e9 {
+		return n, time.Unix(secs, 0), true
+	}
+	return n, now.Add(time.Duration(secs) * time.Second), true
+}
@@ -0,0 +1,48 @@
+package netgo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		header    http.Header
+		remaining int
+		reset     time.Time
+	}{
+		{http.Header{"X-Ratelimit-Remaining": {"7"}, "X-Ratelimit-Reset": {"1700000060"}}, 7, now.Add(time.Minute)},
+		{http.Header{"Ratelimit-Remaining": {"3"}, "Ratelimit-Reset": {"10"}}, 3, now.Add(10 * time.Second)},
+		{http.Header{"Ratelimit": {"limit=100, remaining=0, reset=5"}}, 0, now.Add(5 * time.Second)},
+	}
+	for _, c := range cases {
+		n, reset, ok := parseRateLimit(c.header, now)
+		if !ok || n != c.remaining || !reset.Equal(c.reset) {
+			t.Fatalf("%v: got %d %v %v", c.header, n, reset, ok)
+		}
+	}
+	if _, _, ok := parseRateLimit(http.Header{}, now); ok {
+		t.Fatal("no headers should not parse")
+	}
+}
+
+func TestRateLimitThrottle(t *testing.T) {
+	now := time.Now()
+	th := &RateLimitThrottle{Reserve: 1}
+	th.record("api", &http.Response{Header: http.Header{"X-Ratelimit-Remaining": {"2"}, "X-Ratelimit-Reset": {"30"}}}, now)
+
+	if d := th.delay("api", now); d != 0 {
+		t.Fatalf("quota left, got delay %s", d)
+	}
+	if d := th.delay("api", now); d != 30*time.Second {
+		t.Fatalf("reserve reached, got delay %s", d)
+	}
+	if d := th.delay("api", now.Add(time.Minute)); d != 0 {
+		t.Fatalf("quota reset, got delay %s", d)
+	}
+	if d := th.delay("other", now); d != 0 {
+		t.Fatalf("unknown host delayed %s", d)
+	}
+}
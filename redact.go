@@ -0,0 +1,151 @@
+package netgo
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces masked values
+const redacted = "REDACTED"
+
+// Redaction masks secrets in the log lines and captures of a client:
+// header values, query parameter values, the userinfo of URLs and
+// literal secrets
+type Redaction struct {
+	// Headers whose values are masked in captures
+	Headers []string
+	// QueryParams are case-insensitive path.Match patterns of query
+	// parameter names whose values are masked
+	QueryParams []string
+	// Secrets are masked wherever they appear
+	Secrets []string
+}
+
+// DefaultRedaction is used by clients without Redaction
+var DefaultRedaction = &Redaction{
+	Headers:     []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+	QueryParams: []string{"*token*", "*key*", "*secret*", "*password*", "*signature*", "sig"},
+}
+
+func (c *Client) redaction() *Redaction {
+	if c.Redaction != nil {
+		return c.Redaction
+	}
+	return DefaultRedaction
+}
+
+// log returns the client logger masking secrets
+func (c *Client) log() Logger {
+	return c.redactLogger(c.Logger)
+}
+
+func (c *Client) redactLogger(l Logger) Logger {
+	return redactLogger{Logger: l, r: c.redaction(), secrets: c.secrets}
+}
+
+// URL returns u as a string with the password of its userinfo, or a
+// lone user name, and the values of matching query parameters masked
+func (r *Redaction) URL(u *url.URL) string {
+	s, _ := r.url(u)
+	return s
+}
+
+// url reports whether anything was masked
+func (r *Redaction) url(u *url.URL) (string, bool) {
+	cp := *u
+	changed := false
+	if cp.User != nil {
+		if _, ok := cp.User.Password(); ok {
+			cp.User = url.UserPassword(cp.User.Username(), redacted)
+		} else {
+			cp.User = url.User(redacted)
+		}
+		changed = true
+	}
+	if cp.RawQuery != "" {
+		parts := strings.Split(cp.RawQuery, "&")
+		for i, part := range parts {
+			name, _, ok := strings.Cut(part, "=")
+			if !ok {
+				continue
+			}
+			if unescaped, err := url.QueryUnescape(name); err == nil && r.maskedParam(unescaped) {
+				parts[i] = name + "=" + redacted
+				changed = true
+			}
+		}
+		cp.RawQuery = strings.Join(parts, "&")
+	}
+	return cp.String(), changed
+}
+
+func (r *Redaction) maskedParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range r.QueryParams {
+		if ok, _ := path.Match(strings.ToLower(p), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Header returns a copy of h with the values of masked headers replaced
+func (r *Redaction) Header(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range r.Headers {
+		name = http.CanonicalHeaderKey(name)
+		for i := range out[name] {
+			out[name][i] = redacted
+		}
+	}
+	return out
+}
+
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.\-]*://[^\s"'<>]+`)
+
+// String masks the URLs and secrets within s
+func (r *Redaction) String(s string, secrets ...string) string {
+	s = urlPattern.ReplaceAllStringFunc(s, func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return raw
+		}
+		if masked, changed := r.url(u); changed {
+			return masked
+		}
+		return raw
+	})
+	for _, list := range [][]string{r.Secrets, secrets} {
+		for _, secret := range list {
+			if secret != "" {
+				s = strings.ReplaceAll(s, secret, redacted)
+			}
+		}
+	}
+	return s
+}
+
+// request returns a shallow copy of req with masked URL and headers
+func (r *Redaction) request(req *http.Request) *http.Request {
+	cp := *req
+	cp.Header = r.Header(req.Header)
+	if masked, changed := r.url(req.URL); changed {
+		cp.URL, _ = url.Parse(masked)
+	}
+	return &cp
+}
+
+// redactLogger masks secrets in every line
+type redactLogger struct {
+	Logger
+	r       *Redaction
+	secrets []string
+}
+
+func (l redactLogger) Printf(format string, args ...interface{}) {
+	l.Logger.Printf("%s", l.r.String(fmt.Sprintf(format, args...), l.secrets...))
+}
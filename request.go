@@ -2,11 +2,14 @@ package netgo
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
 // ReaderFunc represents request body type
@@ -16,80 +19,150 @@ type ReaderFunc func() (io.Reader, error)
 type Request struct {
 	body ReaderFunc
 	*http.Request
+
+	// sni overrides the TLS server name, see SetSNI
+	sni string
+	// retry overrides the client policy, see WithRetryPolicy
+	retry *Retry
+	// params fill the URL path template, see Path
+	params map[string]interface{}
+	// logger and logFields override the client logger, see WithLogFields
+	logger    Logger
+	logFields map[string]interface{}
+	// maxResponse overrides the client body limit, see WithMaxResponseBytes
+	maxResponse int64
+	// checkStatus turns non-2xx responses into errors, see WithCheckStatus
+	checkStatus bool
+	// checks validate the final response, see WithResponseCheck
+	checks []ResponseCheck
+	// upgrade hands a 101 response over untouched, see DialWebSocket
+	upgrade bool
+	// bandwidth limits the bodies, see WithBandwidth
+	bandwidth *Bandwidth
+	// authorization is set on every attempt, see WithBearerToken
+	authorization string
+	// proxy replaces the proxy of the client transport when set
+	proxy *url.URL
+	// skipTokenSource keeps token requests from asking for tokens
+	skipTokenSource bool
+	// anonymous sends none of the client credentials, see Paginator
+	anonymous bool
+	// reauthorized is set once a 401 was retried with fresh credentials
+	reauthorized bool
+	// credentials digests the credentials of the request, partitioning
+	// the cached responses it is served from
+	credentials string
+	// memoize serves GETs from earlier responses, see WithMemoize
+	memoize time.Duration
+	// progress reports body transfers, see WithProgress
+	progress *progress
+	// err is the first error of a request option, see fail
+	err error
+	// cancels release contexts derived by request options
+	cancels []context.CancelFunc
+}
+
+// fail records an error of a request option for Do to return
+func (r *Request) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
 }
 
 type lenner interface {
 	Len() int
 }
 
-// NewRequest ..
+// NewRequest creates a request whose body is replayed for every
+// attempt. rawBody may be nil, a BodyProvider, a ReaderFunc, []byte, a
+// string, or any io.Reader, which is read into memory first.
 func NewRequest(method, url string, rawBody interface{}) (*Request, error) {
+	return NewRequestWithContext(context.Background(), method, url, rawBody)
+}
+
+// NewRequestWithContext is NewRequest with a context controlling the
+// whole retry loop, including waits between attempts
+func NewRequestWithContext(ctx context.Context, method, url string, rawBody interface{}) (*Request, error) {
 	bodyReader, contentLength, err := getBodyReader(rawBody)
 	if err != nil {
 		return nil, err
 	}
 
-	httpReq, err := http.NewRequest(method, url, nil)
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
 	httpReq.ContentLength = contentLength
+	if bodyReader != nil {
+		// lets the transport replay the body on redirects
+		httpReq.GetBody = getBody(bodyReader)
+	}
 
-	return &Request{bodyReader, httpReq}, nil
+	return &Request{body: bodyReader, Request: httpReq}, nil
 }
 
-func getBodyReader(body interface{}) (bodyReader ReaderFunc, contentLength int64, err error) {
-	if body != nil {
-		switch bodyType := body.(type) {
-		case ReaderFunc:
-			bodyReader = bodyType
-			tmp, err := bodyType()
-			if err != nil {
-				return nil, 0, err
-			}
-			if lr, ok := tmp.(lenner); ok {
-				contentLength = int64(lr.Len())
-			}
-			if c, ok := tmp.(io.Closer); ok {
-				err := c.Close()
-				if err != nil {
-					return nil, 0, err
-				}
-			}
-
-		case *bytes.Reader:
-			bodyReader = func() (io.Reader, error) {
-				return ioutil.NopCloser(bodyType), nil
-			}
-			contentLength = int64(bodyType.Len())
-
-		case *bytes.Buffer:
-			buf := bodyType.Bytes()
-			bodyReader = func() (io.Reader, error) {
-				r := bytes.NewReader(buf)
-				return ioutil.NopCloser(r), nil
-			}
-			contentLength = int64(bodyType.Len())
+func getBody(body ReaderFunc) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		r, err := body()
+		if err != nil {
+			return nil, err
+		}
+		return toReadCloser(r), nil
+	}
+}
 
-		case *strings.Reader:
-			bodyReader = func() (io.Reader, error) {
-				return ioutil.NopCloser(bodyType), nil
-			}
-			contentLength = int64(bodyType.Len())
+// bodyProvider maps the body types accepted by NewRequest to providers
+func bodyProvider(body interface{}) (BodyProvider, error) {
+	switch b := body.(type) {
+	case BodyProvider:
+		return b, nil
+	case []byte:
+		return BodyBytes(b), nil
+	case string:
+		return BodyString(b), nil
+	case *bytes.Buffer:
+		return BodyBytes(b.Bytes()), nil
+	case *bytes.Reader:
+		return BodySeeker(b), nil
+	case *strings.Reader:
+		return BodySeeker(b), nil
+	case io.Reader:
+		buf, err := ioutil.ReadAll(b)
+		if err != nil {
+			return nil, err
+		}
+		return BodyBytes(buf), nil
+	}
+	return nil, fmt.Errorf("cannot handle type %T", body)
+}
 
-		case io.Reader:
-			buf, err := ioutil.ReadAll(bodyType)
+func getBodyReader(body interface{}) (bodyReader ReaderFunc, contentLength int64, err error) {
+	if body == nil {
+		return nil, 0, nil
+	}
+	if fn, ok := body.(ReaderFunc); ok {
+		tmp, err := fn()
+		if err != nil {
+			return nil, 0, err
+		}
+		if lr, ok := tmp.(lenner); ok {
+			contentLength = int64(lr.Len())
+		}
+		if c, ok := tmp.(io.Closer); ok {
+			err := c.Close()
 			if err != nil {
 				return nil, 0, err
 			}
-			bodyReader = func() (io.Reader, error) {
-				return ioutil.NopCloser(bytes.NewReader(buf)), nil
-			}
-			contentLength = int64(len(buf))
-
-		default:
-			return nil, 0, fmt.Errorf("cannot handle type %T", bodyType)
 		}
+		return fn, contentLength, nil
+	}
+
+	p, err := bodyProvider(body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n := p.Len(); n > 0 {
+		contentLength = n
 	}
-	return bodyReader, contentLength, nil
+	return p.Open, contentLength, nil
 }
@@ -2,6 +2,11 @@ package netgo
 
 import (
 	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -31,3 +36,91 @@ func TestRequest(t *testing.T) {
 		t.Fatalf("bad ContentLength: %d", req.ContentLength)
 	}
 }
+
+func TestRequestWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := NewRequestWithContext(ctx, "GET", "http://foo", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if req.Context() != ctx {
+		t.Fatal("context not attached")
+	}
+
+	if _, err := NewRequestWithContext(nil, "GET", "http://foo", nil); err == nil {
+		t.Fatal("nil context should error")
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	req, err := R().Method("POST").URL("http://foo/bar?a=1").Query("q", "x").
+		Header("X-Test", "y").JSONBody(map[string]int{"n": 1}).Build(context.Background())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if req.Method != "POST" || req.URL.Query().Get("a") != "1" || req.URL.Query().Get("q") != "x" {
+		t.Fatalf("bad request: %s %s", req.Method, req.URL)
+	}
+	if req.Header.Get("X-Test") != "y" || req.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("bad headers: %v", req.Header)
+	}
+	if req.ContentLength != int64(len(`{"n":1}`)) {
+		t.Fatalf("bad ContentLength: %d", req.ContentLength)
+	}
+
+	if _, err := R().JSONBody(func() {}).Build(context.Background()); err == nil {
+		t.Fatal("expected marshal error")
+	}
+}
+
+func TestBodyProviders(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "body")
+	if err := ioutil.WriteFile(path, []byte("file"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	seeker := strings.NewReader("xxseek")
+	seeker.Seek(2, io.SeekStart)
+
+	cases := []struct {
+		body interface{}
+		want string
+	}{
+		{BodyBytes([]byte("bytes")), "bytes"},
+		{BodyString("string"), "string"},
+		{BodyFile(path), "file"},
+		{BodySeeker(seeker), "seek"},
+		{BodySeeker(onlySeeker{strings.NewReader("plain")}), "plain"},
+		{BodyFunc(func() (io.Reader, error) { return strings.NewReader("func"), nil }), "func"},
+		{[]byte("raw"), "raw"},
+		{bytes.NewReader([]byte("reader")), "reader"},
+	}
+	for _, c := range cases {
+		req, err := NewRequest("POST", "http://foo", c.body)
+		if err != nil {
+			t.Fatalf("%T: %v", c.body, err)
+		}
+		for i := 0; i < 2; i++ {
+			r, err := req.body()
+			if err != nil {
+				t.Fatalf("%T: %v", c.body, err)
+			}
+			got, _ := ioutil.ReadAll(r)
+			if rc, ok := r.(io.Closer); ok {
+				rc.Close()
+			}
+			if string(got) != c.want {
+				t.Fatalf("%T attempt %d: got %q, want %q", c.body, i, got, c.want)
+			}
+		}
+		if _, isFunc := c.body.(funcBody); !isFunc && req.ContentLength != int64(len(c.want)) {
+			t.Fatalf("%T: bad ContentLength %d", c.body, req.ContentLength)
+		}
+	}
+}
+
+type onlySeeker struct {
+	io.ReadSeeker
+}
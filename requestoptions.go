@@ -0,0 +1,83 @@
+package netgo
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// RequestOption adjusts a single request passed to Do
+type RequestOption func(*Request)
+
+// WithHeader sets a request header
+func WithHeader(key, value string) RequestOption {
+	return func(r *Request) {
+		r.Header.Set(key, value)
+	}
+}
+
+// WithQueryParam adds a query parameter to the request URL
+func WithQueryParam(key, value string) RequestOption {
+	return func(r *Request) {
+		q := r.URL.Query()
+		q.Add(key, value)
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
+// WithRequestTimeout bounds the whole request, retries and waits between
+// them included. Unlike the client option WithTimeout, which limits every
+// single attempt, it applies to this request only.
+func WithRequestTimeout(d time.Duration) RequestOption {
+	return func(r *Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		r.Request = r.Request.WithContext(ctx)
+		r.cancels = append(r.cancels, cancel)
+	}
+}
+
+// WithBasicAuth authorizes this request with basic auth credentials
+func WithBasicAuth(user, pass string) RequestOption {
+	return func(r *Request) {
+		r.authorization = basicAuth(user, pass)
+	}
+}
+
+// WithRetryPolicy overrides the client retry policy for this request
+func WithRetryPolicy(p Retry) RequestOption {
+	return func(r *Request) {
+		r.retry = &p
+	}
+}
+
+func (c *Client) retryPolicy(req *Request) *Retry {
+	if req.retry != nil {
+		return req.retry
+	}
+	return &c.Retry
+}
+
+// applyOptions runs opts on req; the returned function releases their
+// resources once the response is done with
+func (r *Request) applyOptions(opts []RequestOption) func(*http.Response, error) {
+	for _, opt := range opts {
+		opt(r)
+	}
+	cancels := r.cancels
+	r.cancels = nil
+	return func(resp *http.Response, err error) {
+		if len(cancels) == 0 {
+			return
+		}
+		release := func() {
+			for _, cancel := range cancels {
+				cancel()
+			}
+		}
+		if err != nil || resp == nil || resp.Body == nil {
+			release()
+			return
+		}
+		resp.Body = &cancelBody{resp.Body, release}
+	}
+}
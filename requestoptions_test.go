@@ -0,0 +1,507 @@
+package netgo
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoRequestOptions(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		user, pass, _ := req.BasicAuth()
+		if req.Header.Get("X-One") != "1" || req.URL.Query().Get("q") != "x" || user != "u" || pass != "p" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 5, WaitMin: time.Hour, WaitMax: time.Hour}))
+	req, _ := NewRequest("GET", ts.URL, nil)
+
+	_, err := client.Do(req,
+		WithHeader("X-One", "1"),
+		WithQueryParam("q", "x"),
+		WithBasicAuth("u", "p"),
+		WithRetryPolicy(Retry{Max: 2}),
+	)
+	if err == nil || n != 3 {
+		t.Fatalf("per-request retry policy not applied: %d attempts, %v", n, err)
+	}
+
+	req, _ = NewRequest("GET", ts.URL, nil)
+	start := time.Now()
+	_, err = client.Do(req,
+		WithHeader("X-One", "1"),
+		WithQueryParam("q", "x"),
+		WithBasicAuth("u", "p"),
+		WithRequestTimeout(100*time.Millisecond),
+	)
+	if err != context.DeadlineExceeded || time.Since(start) > time.Second {
+		t.Fatalf("request timeout not applied: %v after %v", err, time.Since(start))
+	}
+}
+
+func TestPathParams(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Path", req.URL.EscapedPath())
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.BaseURL = ts.URL
+	resp, err := client.Get("/users/{id}/orders/{order}", Path("id", 42), Path("order", "a/b c"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("X-Path"); got != "/users/42/orders/a%2Fb%20c" {
+		t.Fatalf("bad path: %s", got)
+	}
+
+	if _, err := client.Get("/users/{id}/orders/{order}", Path("id", 42)); err == nil {
+		t.Fatal("missing parameter should fail")
+	}
+	if _, err := client.Get("/users/{id}", Path("user", 42)); err == nil {
+		t.Fatal("unknown parameter should fail")
+	}
+}
+
+func TestWithCompressedBody(t *testing.T) {
+	payload := strings.Repeat("log line\n", 1000)
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		zr, err := gzip.NewReader(req.Body)
+		if err != nil || req.Header.Get("Content-Encoding") != "gzip" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := ioutil.ReadAll(zr)
+		if string(b) != payload {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	resp, err := client.Post(ts.URL, "text/plain", payload, WithCompressedBody())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || n != 2 {
+		t.Fatalf("bad outcome: %d after %d attempts", resp.StatusCode, n)
+	}
+}
+
+func TestRequestLogFields(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	var clientLog, tenantLog strings.Builder
+	client := NewClient(
+		WithTransport(ts.Client().Transport),
+		WithLogger(log.New(&clientLog, "", 0)),
+		WithRetry(Retry{Max: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}),
+	)
+	_, err := client.Get(ts.URL,
+		WithRequestLogger(log.New(&tenantLog, "", 0)),
+		WithLogFields(map[string]interface{}{"tenant": "acme", "request_id": 7}))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if clientLog.Len() != 0 {
+		t.Fatalf("request lines leaked to the client logger: %s", clientLog.String())
+	}
+	if !strings.Contains(tenantLog.String(), "(1 left) request_id=7 tenant=acme\n") {
+		t.Fatalf("fields missing: %s", tenantLog.String())
+	}
+}
+
+func TestProgress(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ioutil.ReadAll(req.Body)
+		w.Header().Set("Content-Length", "3000")
+		w.Write([]byte(strings.Repeat("x", 3000)))
+	}))
+	defer ts.Close()
+
+	type call struct{ written, total int64 }
+	var calls []call
+	client := NewClient(WithTransport(ts.Client().Transport))
+	resp, err := client.Post(ts.URL, "text/plain", strings.Repeat("y", 2000),
+		WithProgress(func(written, total int64) {
+			calls = append(calls, call{written, total})
+		}),
+		WithProgressInterval(0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) == 0 || calls[len(calls)-1] != (call{2000, 2000}) {
+		t.Fatalf("upload progress: %v", calls)
+	}
+	calls = nil
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(calls) == 0 || calls[len(calls)-1] != (call{3000, 3000}) {
+		t.Fatalf("download progress: %v", calls)
+	}
+}
+
+func TestBandwidth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ioutil.ReadAll(req.Body)
+		w.Write([]byte(strings.Repeat("x", 300)))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.Bandwidth = &Bandwidth{Rate: 1 << 20}
+	start := time.Now()
+	resp, err := client.Post(ts.URL, "text/plain", strings.Repeat("y", 300), WithBandwidth(1000, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	// both bodies share the request limit: 100 bytes of burst, the
+	// remaining 500 take 500ms
+	if elapsed := time.Since(start); len(b) != 300 || elapsed < 450*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("read %d bytes in %v", len(b), elapsed)
+	}
+}
+
+func TestAuthOptions(t *testing.T) {
+	var seen []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = append(seen, req.Header.Get("Authorization"))
+		if len(seen)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(
+		WithTransport(ts.Client().Transport),
+		WithRetry(Retry{Max: 1}),
+		WithDefaultBearerToken("t0k"),
+	)
+	client.PrepareRetry = func(req *Request) error {
+		req.Header.Del("Authorization")
+		return nil
+	}
+	if _, err := client.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get(ts.URL, WithBasicAuth("u", "p")); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Bearer t0k", "Bearer t0k", "Basic dTpw", "Basic dTpw"}
+	for i := range want {
+		if i >= len(seen) || seen[i] != want[i] {
+			t.Fatalf("Authorization headers %q, want %q", seen, want)
+		}
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	var seen []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = append(seen, req.Header.Get("Authorization"))
+		if req.Header.Get("Authorization") == "Bearer 1" && len(seen) == 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	var issued int
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}))
+	client.TokenSource = TokenSourceFunc(func() (*Token, error) {
+		issued++
+		return &Token{AccessToken: strconv.Itoa(issued), Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("get %d: %v %v", i, resp, err)
+		}
+	}
+	want := []string{"Bearer 1", "Bearer 1", "Bearer 2", "Bearer 2"}
+	if strings.Join(seen, ",") != strings.Join(want, ",") || issued != 2 {
+		t.Fatalf("Authorization headers %q from %d tokens, want %q", seen, issued, want)
+	}
+
+	// a derived client with its own source does not see the cached token
+	other := client.With()
+	other.TokenSource = TokenSourceFunc(func() (*Token, error) {
+		return &Token{AccessToken: "other", Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	seen = nil
+	if _, err := other.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1 || seen[0] != "Bearer other" {
+		t.Fatalf("derived client sent %q", seen)
+	}
+}
+
+func TestReauthorizeReusedRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}))
+	fresh := false
+	client.TokenSource = TokenSourceFunc(func() (*Token, error) {
+		tok := "stale"
+		if fresh {
+			tok = "fresh"
+		}
+		fresh = !fresh
+		return &Token{AccessToken: tok, Expiry: time.Now().Add(time.Hour)}, nil
+	})
+	req, _ := NewRequest("GET", ts.URL, nil)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Do(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("do %d: %v %v", i, resp, err)
+		}
+		resp.Body.Close()
+		// the next Do starts from a stale token again
+		client.invalidateToken("Bearer fresh")
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	var grants int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			id, secret, _ := req.BasicAuth()
+			req.ParseForm()
+			if id != "id" || secret != "secret" || req.Form.Get("grant_type") != "client_credentials" || req.Form.Get("scope") != "a b" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			grants++
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"t%d","token_type":"Bearer","expires_in":3600}`, grants)
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer t1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.TokenSource = &ClientCredentials{
+		Client:       client,
+		TokenURL:     ts.URL + "/token",
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Scopes:       []string{"a", "b"},
+	}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("get %d: %v %v", i, resp, err)
+		}
+	}
+	if grants != 1 {
+		t.Fatalf("%d grants, want 1", grants)
+	}
+}
+
+func TestClientCredentialsSingleFlight(t *testing.T) {
+	var grants int32
+	started, release := make(chan struct{}), make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			if atomic.AddInt32(&grants, 1) == 1 {
+				close(started)
+			}
+			<-release
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token":"t","expires_in":3600}`)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}))
+	client.TokenSource = &ClientCredentials{Client: client, TokenURL: ts.URL + "/token"}
+	done := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			resp, err := client.Get(ts.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			done <- err
+		}()
+	}
+	<-started
+
+	// a caller does not queue behind the fetch in flight past its deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("waiting for the token: %v", err)
+	}
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&grants); n != 1 {
+		t.Fatalf("%d grants, want 1", n)
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		cookie, _ := req.Cookie("key")
+		if req.Header.Get("X-Api-Key") != "s3cr3t" && req.URL.Query().Get("api_key") != "s3cr3t" &&
+			(cookie == nil || cookie.Value != "s3cr3t") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if n%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	for _, in := range []APIKeyLocation{APIKeyInHeader, APIKeyInQuery, APIKeyInCookie} {
+		var logs strings.Builder
+		name := map[APIKeyLocation]string{APIKeyInHeader: "X-Api-Key", APIKeyInQuery: "api_key", APIKeyInCookie: "key"}[in]
+		client := NewClient(WithTransport(ts.Client().Transport), WithLogger(log.New(&logs, "", 0)),
+			WithRetry(Retry{Max: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}), WithAPIKey("s3cr3t", in, name))
+		resp, err := client.Get(ts.URL + "/?q=1")
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: %v %v", name, resp, err)
+		}
+		if logs.Len() == 0 || strings.Contains(logs.String(), "s3cr3t") {
+			t.Fatalf("%s: key not redacted from %q", name, logs.String())
+		}
+	}
+}
+
+func TestOnUnauthorized(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		if req.Header.Get("X-Session") != "fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	var calls int
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.OnUnauthorized = func(ctx context.Context, req *Request) error {
+		calls++
+		if req.Header.Get("X-Session") == "stale" {
+			return errors.New("session expired for good")
+		}
+		req.Header.Set("X-Session", "fresh")
+		return nil
+	}
+	resp, err := client.Get(ts.URL)
+	if err != nil || resp.StatusCode != http.StatusOK || calls != 1 || n != 2 {
+		t.Fatalf("refresh: %v %v after %d calls, %d attempts", resp, err, calls, n)
+	}
+	resp, err = client.Get(ts.URL, WithHeader("X-Session", "stale"))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || calls != 2 || n != 3 {
+		t.Fatalf("failed refresh: %v %v after %d calls, %d attempts", resp, err, calls, n)
+	}
+}
+
+func TestJWTAssertion(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var grants int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/token" {
+			if req.Header.Get("Authorization") != "Bearer access-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+			return
+		}
+		req.ParseForm()
+		parts := strings.Split(req.Form.Get("assertion"), ".")
+		if req.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || len(parts) != 3 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]interface{}
+		json.Unmarshal(payload, &claims)
+		if len(sig) != 64 || !ecdsa.Verify(&key.PublicKey, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) ||
+			claims["iss"] != "svc@example.com" || claims["aud"] != "https://oauth.example.com/token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		grants++
+		fmt.Fprintf(w, `{"access_token":"access-%d","expires_in":3600}`, grants)
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.TokenSource = &JWTAssertion{
+		Client:   client,
+		TokenURL: ts.URL + "/token",
+		Key:      key,
+		KeyID:    "k1",
+		Issuer:   "svc@example.com",
+		Audience: "https://oauth.example.com/token",
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL + "/api")
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("get %d: %v %v", i, resp, err)
+		}
+	}
+	if grants != 1 {
+		t.Fatalf("%d grants, want 1", grants)
+	}
+}
@@ -0,0 +1,224 @@
+package netgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// WithResolver resolves the host names the client dials through r.
+// Combined with WithDNSCache, set the Resolver of the cache instead.
+func WithResolver(r Resolver) Option {
+	return withDialer(func(d *dialer) {
+		d.resolver = r
+	})
+}
+
+// dnsExchange sends a query and returns the raw response
+type dnsExchange func(ctx context.Context, query []byte) ([]byte, error)
+
+// lookupAddrs queries the AAAA and A records of host concurrently and
+// returns the IPv6 addresses first
+func lookupAddrs(ctx context.Context, host string, randomID bool, exchange dnsExchange) ([]netip.Addr, time.Duration, error) {
+	type result struct {
+		addrs []netip.Addr
+		ttl   uint32
+		err   error
+	}
+	types := []uint16{dnsTypeAAAA, dnsTypeA}
+	results := make([]result, len(types))
+	var wg sync.WaitGroup
+	for i, qtype := range types {
+		wg.Add(1)
+		go func(i int, qtype uint16) {
+			defer wg.Done()
+			resp, err := queryDNS(ctx, host, qtype, randomID, exchange)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			results[i].addrs, results[i].ttl = resp.addrs()
+		}(i, qtype)
+	}
+	wg.Wait()
+
+	var (
+		addrs []netip.Addr
+		ttl   uint32
+		err   error
+	)
+	for _, r := range results {
+		if r.err != nil {
+			if err == nil {
+				err = r.err
+			}
+			continue
+		}
+		if len(r.addrs) > 0 && (len(addrs) == 0 || r.ttl < ttl) {
+			ttl = r.ttl
+		}
+		addrs = append(addrs, r.addrs...)
+	}
+	if len(addrs) == 0 {
+		if err == nil {
+			err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return nil, 0, err
+	}
+	return addrs, time.Duration(ttl) * time.Second, nil
+}
+
+// queryDNS sends one query for host and checks the response code
+func queryDNS(ctx context.Context, host string, qtype uint16, randomID bool, exchange dnsExchange) (*dnsResponse, error) {
+	var id uint16
+	if randomID {
+		var b [2]byte
+		rand.Read(b[:])
+		id = binary.BigEndian.Uint16(b[:])
+	}
+	query, err := dnsQuery(id, host, qtype)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := exchange(ctx, query)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host, IsTemporary: true}
+	}
+	resp, err := parseDNSResponse(raw, id, host, qtype)
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: host}
+	}
+	switch resp.rcode {
+	case 0:
+		return resp, nil
+	case 3:
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	default:
+		return nil, &net.DNSError{Err: "server failure", Name: host, IsTemporary: resp.rcode == 2}
+	}
+}
+
+// DNSServerResolver queries a specific DNS server over UDP, falling back
+// to TCP for truncated answers, or over TCP only
+type DNSServerResolver struct {
+	// Addr of the server as host:port
+	Addr string
+	// TCP sends every query over TCP
+	TCP bool
+	// Timeout of a query, 5s by default
+	Timeout time.Duration
+}
+
+// LookupNetIP implements Resolver
+func (r *DNSServerResolver) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	return lookupAddrs(ctx, host, true, r.exchange)
+}
+
+func (r *DNSServerResolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, durationOr(r.Timeout, 5*time.Second))
+	defer cancel()
+	if !r.TCP {
+		resp, err := r.exchangeUDP(ctx, query)
+		if err != nil || len(resp) < 3 || resp[2]&0x02 == 0 {
+			return resp, err
+		}
+	}
+	return r.exchangeTCP(ctx, query)
+}
+
+func (r *DNSServerResolver) exchangeUDP(ctx context.Context, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", r.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// skip stray datagrams answering other queries
+		if n >= 2 && buf[0] == query[0] && buf[1] == query[1] {
+			return buf[:n], nil
+		}
+	}
+}
+
+func (r *DNSServerResolver) exchangeTCP(ctx context.Context, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", r.Addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(size[:]))
+	_, err = io.ReadFull(conn, resp)
+	return resp, err
+}
+
+// DoHResolver resolves over DNS-over-HTTPS (RFC 8484)
+type DoHResolver struct {
+	// URL of the endpoint, e.g. https://dns.example/dns-query
+	URL string
+	// Client sends the queries, with its retries; it must not resolve
+	// through this resolver. A new default client when nil.
+	Client *Client
+
+	once   sync.Once
+	client *Client
+}
+
+// LookupNetIP implements Resolver
+func (r *DoHResolver) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, time.Duration, error) {
+	// ID 0 keeps the answers cacheable by HTTP caches
+	return lookupAddrs(ctx, host, false, r.exchange)
+}
+
+func (r *DoHResolver) exchange(ctx context.Context, query []byte) ([]byte, error) {
+	r.once.Do(func() {
+		r.client = r.Client
+		if r.client == nil {
+			r.client = NewClient()
+		}
+	})
+	req, err := NewRequestWithContext(ctx, "POST", r.URL, query)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	return DoAndClose(r.client, req, func(resp *http.Response) ([]byte, error) {
+		if resp.StatusCode != http.StatusOK {
+			return nil, newHTTPError(resp)
+		}
+		return ioutil.ReadAll(io.LimitReader(resp.Body, 65535))
+	})
+}
@@ -3,6 +3,7 @@ package netgo
 import (
 	"context"
 	"crypto/x509"
+	"errors"
 	"math"
 	"net/http"
 	"net/url"
@@ -18,13 +19,20 @@ var (
 type Retry struct {
 	Max              int
 	WaitMin, WaitMax time.Duration
+	// Multiplier of the exponential backoff, 2 when zero
+	Multiplier float64
+	// StatusCodes retried instead of the default 5xx (except 501) rule
+	StatusCodes []int
 }
 
-func (*Retry) isRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
+func (r *Retry) isRetry(ctx context.Context, resp *http.Response, err error) (bool, error) {
 	if ctx.Err() != nil {
 		return false, ctx.Err()
 	}
 	if err != nil {
+		if errors.Is(err, ErrBlockedDestination) || errors.Is(err, ErrInvalidRequest) {
+			return false, nil
+		}
 		if v, ok := err.(*url.Error); ok {
 			if redirectsErrorRe.MatchString(v.Error()) {
 				return false, nil
@@ -38,14 +46,29 @@ func (*Retry) isRetry(ctx context.Context, resp *http.Response, err error) (bool
 		}
 		return true, nil
 	}
-	if resp.StatusCode == 0 || (resp.StatusCode >= 500 && resp.StatusCode != 501) {
+	if resp.StatusCode == 0 {
+		return true, nil
+	}
+	if r.StatusCodes != nil {
+		for _, code := range r.StatusCodes {
+			if resp.StatusCode == code {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	if resp.StatusCode >= 500 && resp.StatusCode != 501 {
 		return true, nil
 	}
 	return false, nil
 }
 
-func (*Retry) backoff(min, max time.Duration, attemptNum int) time.Duration {
-	multiply := math.Pow(2, float64(attemptNum)) * float64(min)
+func (r *Retry) backoff(min, max time.Duration, attemptNum int) time.Duration {
+	base := r.Multiplier
+	if base <= 0 {
+		base = 2
+	}
+	multiply := math.Pow(base, float64(attemptNum)) * float64(min)
 	sleep := time.Duration(multiply)
 	if float64(sleep) != multiply || sleep > max {
 		sleep = max
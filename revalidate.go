@@ -0,0 +1,106 @@
+package netgo
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Revalidator remembers the validators and bodies of GET responses and
+// makes repeated GETs of the same URL conditional, answering a 304 with
+// the stored response. Unlike HTTPCache it ignores freshness and asks
+// the origin every time. Set it as Client.Revalidator.
+type Revalidator struct {
+	// Store keeps the responses, a MemoryCacheStore by default
+	Store CacheStore
+
+	once  sync.Once
+	store CacheStore
+}
+
+func (v *Revalidator) getStore() CacheStore {
+	v.once.Do(func() {
+		v.store = v.Store
+		if v.store == nil {
+			v.store = &MemoryCacheStore{}
+		}
+	})
+	return v.store
+}
+
+// middleware makes the GETs of c sent with credentials conditional
+func (v *Revalidator) middleware(c *Client, credentials string) Middleware {
+	return func(next RoundTripperFunc) RoundTripperFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return v.roundTrip(c, credentials, req, next)
+		}
+	}
+}
+
+func (v *Revalidator) roundTrip(c *Client, credentials string, req *http.Request, next RoundTripperFunc) (*http.Response, error) {
+	if req.Method != "GET" || req.Header.Get("Range") != "" ||
+		req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		return next(req)
+	}
+
+	store := v.getStore()
+	key := partitionKey(CacheKey(req), credentials)
+	entry, body, ok := store.Get(key)
+	if ok && !varyMatches(entry, req) {
+		body.Close()
+		ok = false
+	}
+	sent := req
+	if ok {
+		sent = conditional(req, entry)
+	}
+	now := time.Now()
+	resp, err := next(sent)
+	if err != nil || resp.StatusCode != http.StatusNotModified || !ok {
+		if ok {
+			body.Close()
+		}
+		if err == nil {
+			v.save(c, key, req, resp, now)
+		}
+		return resp, err
+	}
+
+	CloseBody(resp)
+	entry = entry.freshen(resp, now, time.Now())
+	if err := store.Update(key, entry); err != nil {
+		c.log().Printf("netter: revalidator update %s: %v", req.URL, err)
+	}
+	return entry.response(req, body, 0), nil
+}
+
+// save streams a response carrying validators into the store
+func (v *Revalidator) save(c *Client, key string, req *http.Request, resp *http.Response, reqTime time.Time) {
+	if resp.StatusCode != http.StatusOK || resp.Body == nil {
+		return
+	}
+	if resp.Header.Get("Etag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return
+	}
+	if parseCacheControl(resp.Header).has("no-store") {
+		return
+	}
+	for _, name := range varyNames(resp.Header) {
+		if name == "*" {
+			return
+		}
+	}
+	w, err := v.getStore().Put(key, &CacheEntry{
+		URL:          req.URL.String(),
+		StatusCode:   resp.StatusCode,
+		Header:       resp.Header.Clone(),
+		VaryHeader:   varyHeader(req, resp),
+		RequestTime:  reqTime,
+		ResponseTime: time.Now(),
+	})
+	if err != nil {
+		c.log().Printf("netter: revalidator put %s: %v", req.URL, err)
+		return
+	}
+	teeToCache(resp, w, c.log())
+}
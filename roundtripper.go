@@ -0,0 +1,68 @@
+package netgo
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// RoundTrip implements http.RoundTripper, running req through the retry
+// loop. The request body is replayed from req.GetBody when set and
+// buffered in memory otherwise. req itself is not modified.
+func (c *Client) RoundTrip(req *http.Request) (*http.Response, error) {
+	r, err := FromRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(r)
+	if err != nil {
+		// a RoundTripper returns a response or an error, never both
+		if resp != nil && resp.Body != nil {
+			resp.Body.Close()
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// StandardClient returns an *http.Client sending through c, for libraries
+// that only accept the standard client
+func (c *Client) StandardClient() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// FromRequest wraps a clone of an existing request so it can be sent by
+// Do. The body is replayed from req.GetBody when set and buffered in
+// memory otherwise; the original body is closed either way.
+func FromRequest(req *http.Request) (*Request, error) {
+	if req == nil || req.URL == nil {
+		return nil, errors.New("netter: request without URL")
+	}
+	clone := req.Clone(req.Context())
+	if req.Body == nil || req.Body == http.NoBody {
+		return &Request{Request: clone}, nil
+	}
+
+	var body ReaderFunc
+	if req.GetBody != nil {
+		getBody := req.GetBody
+		body = func() (io.Reader, error) { return getBody() }
+		req.Body.Close()
+	} else {
+		buf, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body = func() (io.Reader, error) {
+			return ioutil.NopCloser(bytes.NewReader(buf)), nil
+		}
+		if clone.ContentLength <= 0 {
+			clone.ContentLength = int64(len(buf))
+		}
+	}
+	clone.Body = nil
+	return &Request{body: body, Request: clone}, nil
+}
@@ -0,0 +1,118 @@
+package netgo
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type onlyReader struct {
+	*strings.Reader
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n++
+		body, _ := ioutil.ReadAll(req.Body)
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer ts.Close()
+
+	client := &Client{Inner: ts.Client(), Logger: NewClient().Logger}
+	client.Max = 1
+	std := client.StandardClient()
+
+	// a plain io.Reader has no GetBody, so the body must be buffered
+	resp, err := std.Post(ts.URL, "text/plain", onlyReader{strings.NewReader("payload")})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if string(body) != "payload" || n != 2 {
+		t.Fatalf("body not replayed on retry: %q after %d attempts", body, n)
+	}
+}
+
+type closeTracker struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return c.ReadCloser.Close()
+}
+
+func TestClientRoundTripError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "late")
+	}))
+	defer ts.Close()
+
+	// the context ends once the response arrived, so Do returns both
+	ctx, cancel := context.WithCancel(context.Background())
+	var body *closeTracker
+	client := NewClient(WithTransport(ts.Client().Transport))
+	client.Use(AroundAttempt, func(next RoundTripperFunc) RoundTripperFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			resp, err := next(req)
+			if err == nil {
+				body = &closeTracker{ReadCloser: resp.Body}
+				resp.Body = body
+			}
+			cancel()
+			return resp, err
+		}
+	})
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	resp, err := client.StandardClient().Do(req)
+	if err == nil || resp != nil {
+		t.Fatalf("got %v, %v; want only an error", resp, err)
+	}
+	if body == nil || !body.closed {
+		t.Fatal("response body of the failed round trip left open")
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	hr, _ := http.NewRequest("PUT", ts.URL, ioutil.NopCloser(onlyReader{strings.NewReader("payload")}))
+	req, err := FromRequest(hr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp.Body.Close()
+	if len(bodies) != 2 || bodies[0] != "payload" || bodies[1] != "payload" {
+		t.Fatalf("body not replayed: %q", bodies)
+	}
+
+	if _, err := FromRequest(nil); err == nil {
+		t.Fatal("nil request should error")
+	}
+}
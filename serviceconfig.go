@@ -0,0 +1,152 @@
+package netgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// grpcStatusHTTP maps gRPC status codes to the HTTP statuses they are
+// conventionally translated to
+var grpcStatusHTTP = []struct {
+	name string
+	code int
+	http int
+}{
+	{"CANCELLED", 1, 499},
+	{"UNKNOWN", 2, http.StatusInternalServerError},
+	{"INVALID_ARGUMENT", 3, http.StatusBadRequest},
+	{"DEADLINE_EXCEEDED", 4, http.StatusGatewayTimeout},
+	{"NOT_FOUND", 5, http.StatusNotFound},
+	{"ALREADY_EXISTS", 6, http.StatusConflict},
+	{"PERMISSION_DENIED", 7, http.StatusForbidden},
+	{"RESOURCE_EXHAUSTED", 8, http.StatusTooManyRequests},
+	{"FAILED_PRECONDITION", 9, http.StatusBadRequest},
+	{"ABORTED", 10, http.StatusConflict},
+	{"OUT_OF_RANGE", 11, http.StatusBadRequest},
+	{"UNIMPLEMENTED", 12, http.StatusNotImplemented},
+	{"INTERNAL", 13, http.StatusInternalServerError},
+	{"UNAVAILABLE", 14, http.StatusServiceUnavailable},
+	{"DATA_LOSS", 15, http.StatusInternalServerError},
+	{"UNAUTHENTICATED", 16, http.StatusUnauthorized},
+}
+
+// serviceConfigRetry is the retryPolicy object of a gRPC service config
+type serviceConfigRetry struct {
+	MaxAttempts          int               `json:"maxAttempts"`
+	InitialBackoff       string            `json:"initialBackoff"`
+	MaxBackoff           string            `json:"maxBackoff"`
+	BackoffMultiplier    float64           `json:"backoffMultiplier"`
+	RetryableStatusCodes []json.RawMessage `json:"retryableStatusCodes"`
+}
+
+// MarshalServiceConfig encodes the policy as a gRPC service config
+// retryPolicy object. Retried HTTP statuses are written as the gRPC codes
+// translating to them; statuses without a gRPC equivalent are dropped.
+func (r *Retry) MarshalServiceConfig() ([]byte, error) {
+	multiplier := r.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	statuses := r.StatusCodes
+	if statuses == nil {
+		statuses = []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+
+	var codes []json.RawMessage
+	seen := make(map[string]bool)
+	for _, status := range statuses {
+		for _, g := range grpcStatusHTTP {
+			if g.http == status && !seen[g.name] {
+				seen[g.name] = true
+				codes = append(codes, json.RawMessage(strconv.Quote(g.name)))
+			}
+		}
+	}
+
+	return json.Marshal(serviceConfigRetry{
+		MaxAttempts:          r.Max + 1,
+		InitialBackoff:       formatProtoDuration(r.WaitMin),
+		MaxBackoff:           formatProtoDuration(r.WaitMax),
+		BackoffMultiplier:    multiplier,
+		RetryableStatusCodes: codes,
+	})
+}
+
+// UnmarshalServiceConfig loads a gRPC service config retryPolicy object.
+// Status codes may be gRPC names or numbers and are translated to HTTP
+// statuses.
+func (r *Retry) UnmarshalServiceConfig(data []byte) error {
+	var sc serviceConfigRetry
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return err
+	}
+	if sc.MaxAttempts < 1 {
+		return fmt.Errorf("netter: retryPolicy maxAttempts must be positive, got %d", sc.MaxAttempts)
+	}
+	min, err := parseProtoDuration(sc.InitialBackoff)
+	if err != nil {
+		return err
+	}
+	max, err := parseProtoDuration(sc.MaxBackoff)
+	if err != nil {
+		return err
+	}
+
+	statuses := []int{}
+	for _, raw := range sc.RetryableStatusCodes {
+		status, err := grpcCodeToHTTP(raw)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, status)
+	}
+
+	r.Max = sc.MaxAttempts - 1
+	r.WaitMin = min
+	r.WaitMax = max
+	r.Multiplier = sc.BackoffMultiplier
+	r.StatusCodes = statuses
+	return nil
+}
+
+func grpcCodeToHTTP(raw json.RawMessage) (int, error) {
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		for _, g := range grpcStatusHTTP {
+			if strings.EqualFold(g.name, name) {
+				return g.http, nil
+			}
+		}
+		return 0, fmt.Errorf("netter: unknown gRPC status code %q", name)
+	}
+	var code int
+	if err := json.Unmarshal(raw, &code); err != nil {
+		return 0, fmt.Errorf("netter: bad gRPC status code %s", raw)
+	}
+	for _, g := range grpcStatusHTTP {
+		if g.code == code {
+			return g.http, nil
+		}
+	}
+	return 0, fmt.Errorf("netter: unknown gRPC status code %d", code)
+}
+
+// formatProtoDuration renders d in the JSON form of google.protobuf.Duration
+func formatProtoDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+func parseProtoDuration(s string) (time.Duration, error) {
+	if !strings.HasSuffix(s, "s") {
+		return 0, fmt.Errorf("netter: bad duration %q", s)
+	}
+	secs, err := strconv.ParseFloat(strings.TrimSuffix(s, "s"), 64)
+	if err != nil || secs < 0 {
+		return 0, fmt.Errorf("netter: bad duration %q", s)
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
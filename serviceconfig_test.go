@@ -0,0 +1,46 @@
+package netgo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRetryServiceConfig(t *testing.T) {
+	var r Retry
+	err := r.UnmarshalServiceConfig([]byte(`{
+		"maxAttempts": 4,
+		"initialBackoff": "0.1s",
+		"maxBackoff": "1s",
+		"backoffMultiplier": 3,
+		"retryableStatusCodes": ["UNAVAILABLE", 8]
+	}`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if r.Max != 3 || r.WaitMin != 100*time.Millisecond || r.WaitMax != time.Second || r.Multiplier != 3 {
+		t.Fatalf("bad policy: %+v", r)
+	}
+	if len(r.StatusCodes) != 2 || r.StatusCodes[0] != 503 || r.StatusCodes[1] != 429 {
+		t.Fatalf("bad status codes: %v", r.StatusCodes)
+	}
+	if d := r.backoff(r.WaitMin, r.WaitMax, 1); d != 300*time.Millisecond {
+		t.Fatalf("multiplier not applied: %v", d)
+	}
+
+	data, err := r.MarshalServiceConfig()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if out["maxAttempts"] != 4.0 || out["initialBackoff"] != "0.1s" || out["maxBackoff"] != "1s" {
+		t.Fatalf("bad service config: %s", data)
+	}
+	codes := out["retryableStatusCodes"].([]interface{})
+	if len(codes) != 2 || codes[0] != "UNAVAILABLE" || codes[1] != "RESOURCE_EXHAUSTED" {
+		t.Fatalf("bad status codes: %s", data)
+	}
+}
@@ -0,0 +1,240 @@
+package netgo
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSCredentials are the keys requests are signed with
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken of temporary credentials, sent as X-Amz-Security-Token
+	SessionToken string
+}
+
+// Retrieve implements AWSCredentialsProvider for static credentials
+func (c AWSCredentials) Retrieve(ctx context.Context) (AWSCredentials, error) {
+	return c, nil
+}
+
+// AWSCredentialsProvider hands out the credentials for each signature,
+// so rotating credentials are picked up by the next attempt
+type AWSCredentialsProvider interface {
+	Retrieve(ctx context.Context) (AWSCredentials, error)
+}
+
+// SigV4 signs requests with AWS Signature Version 4. Install its
+// middleware around attempts, so every retry is signed anew with a fresh
+// timestamp:
+//
+//	client.Use(AroundAttempt, (&SigV4{Region: "us-east-1", Service: "s3", Credentials: creds}).Middleware())
+type SigV4 struct {
+	Region      string
+	Service     string
+	Credentials AWSCredentialsProvider
+	// UnsignedPayload skips hashing the body, as S3 allows for streaming
+	// uploads
+	UnsignedPayload bool
+
+	// now stands in for time.Now in tests
+	now func() time.Time
+}
+
+// Middleware returns the signing middleware
+func (s *SigV4) Middleware() Middleware {
+	return func(next RoundTripperFunc) RoundTripperFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			now := time.Now
+			if s.now != nil {
+				now = s.now
+			}
+			if err := s.Sign(req, now()); err != nil {
+				return nil, fmt.Errorf("netter: sigv4: %w", err)
+			}
+			return next(req)
+		}
+	}
+}
+
+// Sign sets the Authorization and X-Amz-* headers of req for time t
+func (s *SigV4) Sign(req *http.Request, t time.Time) error {
+	creds, err := s.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return err
+	}
+	payload := "UNSIGNED-PAYLOAD"
+	if !s.UnsignedPayload {
+		if payload, err = digestBody(req, sha256.New()); err != nil {
+			return err
+		}
+	}
+
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+	if s.Service == "s3" || s.UnsignedPayload {
+		req.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+
+	headers, signed := canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL, s.Service != "s3"),
+		canonicalQuery(req.URL),
+		headers,
+		signed,
+		payload,
+	}, "\n")
+	scope := day + "/" + s.Region + "/" + s.Service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), day)
+	for _, part := range []string{s.Region, s.Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// digestBody hashes the body of req with h, from a fresh copy when
+// GetBody can provide one and otherwise by buffering it
+func digestBody(req *http.Request, h hash.Hash) (string, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return "", err
+		}
+		defer body.Close()
+		if _, err := io.Copy(h, body); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+	buf, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	h.Write(buf)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// awsEscape percent-encodes everything but unreserved characters and,
+// unless encodeSlash, slashes
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalPath encodes the path once for S3 and twice for every other
+// service, as the specification demands
+func canonicalPath(u *url.URL, twice bool) string {
+	p := u.Path
+	if p == "" {
+		return "/"
+	}
+	p = awsEscape(p, false)
+	if twice {
+		p = awsEscape(p, false)
+	}
+	return p
+}
+
+func canonicalQuery(u *url.URL) string {
+	// sorted by escaped name, then value: sorting "name=value" would put
+	// "a-b=1" before "a=1"
+	type pair struct{ k, v string }
+	var pairs []pair
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			pairs = append(pairs, pair{awsEscape(k, true), awsEscape(v, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].k != pairs[j].k {
+			return pairs[i].k < pairs[j].k
+		}
+		return pairs[i].v < pairs[j].v
+	})
+	out := make([]string, len(pairs))
+	for i, p := range pairs {
+		out[i] = p.k + "=" + p.v
+	}
+	return strings.Join(out, "&")
+}
+
+// canonicalHeaders returns the canonical header block and the signed
+// header list: host, content type and md5 and all x-amz-* headers
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for k, vs := range req.Header {
+		name := strings.ToLower(k)
+		if name != "content-type" && name != "content-md5" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + values[name] + "\n")
+	}
+	return b.String(), strings.Join(names, ";")
+}
@@ -0,0 +1,61 @@
+package netgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigV4(t *testing.T) {
+	// get-vanilla of the AWS signature test suite
+	s := &SigV4{
+		Region:  "us-east-1",
+		Service: "service",
+		Credentials: AWSCredentials{
+			AccessKeyID:     "AKIDEXAMPLE",
+			SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		},
+	}
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err := s.Sign(req, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization = %q, want %q", got, want)
+	}
+
+	// names sort before values, even where "=" sorts after the name
+	u, _ := url.Parse("https://example.amazonaws.com/?a.b=0&a-b=1&a=2&a1=x&a=1&b=%20")
+	if got, want := canonicalQuery(u), "a=1&a=2&a-b=1&a.b=0&a1=x&b=%20"; got != want {
+		t.Fatalf("canonical query %q, want %q", got, want)
+	}
+
+	var dates []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		dates = append(dates, req.Header.Get("X-Amz-Date"))
+		if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") || len(dates) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 1, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	// every attempt is signed a second later than the previous one
+	clock := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	s.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	client.Use(AroundAttempt, s.Middleware())
+	resp, err := client.Post(ts.URL, "text/plain", strings.NewReader("payload"))
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("post: %v %v", resp, err)
+	}
+	if len(dates) != 2 || dates[0] == dates[1] {
+		t.Fatalf("attempts signed at %q, want two timestamps", dates)
+	}
+}
@@ -0,0 +1,173 @@
+package netgo
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// socks5URL is the proxy URL of a SOCKS5 server at addr
+func socks5URL(addr, user, password string) *url.URL {
+	u := &url.URL{Scheme: "socks5", Host: addr}
+	if user != "" {
+		u.User = url.UserPassword(user, password)
+	}
+	return u
+}
+
+// WithSOCKS5 sends every request of the client through the SOCKS5
+// proxy at addr, authenticating with user and password unless user is
+// empty. Host names are resolved by the proxy.
+func WithSOCKS5(addr, user, password string) Option {
+	return func(c *Client) {
+		tr, ok := c.Inner.Transport.(*http.Transport)
+		if !ok {
+			return
+		}
+		tr = tr.Clone()
+		tr.Proxy = http.ProxyURL(socks5URL(addr, user, password))
+		c.Inner.Transport = tr
+	}
+}
+
+// WithSOCKS5Proxy sends this request through the SOCKS5 proxy at addr,
+// as WithSOCKS5 does for a whole client. Such requests use a connection
+// pool per proxy.
+func WithSOCKS5Proxy(addr, user, password string) RequestOption {
+	return WithProxy(socks5URL(addr, user, password))
+}
+
+// socksTunnel connects to addr through the SOCKS5 proxy (RFC 1928),
+// leaving host names for the proxy to resolve. The bool reports whether
+// a failure is worth retrying.
+func socksTunnel(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error),
+	proxy *url.URL, addr string) (net.Conn, bool, error) {
+
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, false, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, false, fmt.Errorf("netter: bad port in %q", addr)
+	}
+	proxyAddr := proxy.Host
+	if proxy.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxy.Hostname(), "1080")
+	}
+
+	conn, err := dial(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, true, err
+	}
+	// the handshake must not outlive ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	retryable, err := socksHandshake(conn, proxy.User, host, uint16(port))
+	if err != nil {
+		conn.Close()
+		return nil, retryable, fmt.Errorf("netter: socks5 proxy %s: %w", proxy.Host, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, false, nil
+}
+
+func socksHandshake(conn net.Conn, user *url.Userinfo, host string, port uint16) (bool, error) {
+	methods := []byte{0x00}
+	if user != nil {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return true, err
+	}
+	var buf [4]byte
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return true, err
+	}
+	if buf[0] != 0x05 {
+		return false, fmt.Errorf("unexpected version %d", buf[0])
+	}
+	switch buf[1] {
+	case 0x00:
+	case 0x02:
+		if user == nil {
+			return false, errors.New("asked for credentials")
+		}
+		pass, _ := user.Password()
+		if len(user.Username()) > 255 || len(pass) > 255 {
+			return false, errors.New("credentials too long")
+		}
+		msg := []byte{0x01, byte(len(user.Username()))}
+		msg = append(msg, user.Username()...)
+		msg = append(msg, byte(len(pass)))
+		msg = append(msg, pass...)
+		if _, err := conn.Write(msg); err != nil {
+			return true, err
+		}
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return true, err
+		}
+		if buf[1] != 0x00 {
+			return false, errors.New("authentication failed")
+		}
+	default:
+		return false, errors.New("no acceptable authentication method")
+	}
+
+	req := []byte{0x05, 0x01, 0x00}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if ip.Is4() {
+			req = append(req, 0x01)
+		} else {
+			req = append(req, 0x04)
+		}
+		req = append(req, ip.AsSlice()...)
+	} else {
+		if len(host) > 255 {
+			return false, fmt.Errorf("host name %q too long", host)
+		}
+		req = append(req, 0x03, byte(len(host)))
+		req = append(req, host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, port)
+	if _, err := conn.Write(req); err != nil {
+		return true, err
+	}
+
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return true, err
+	}
+	if rep := buf[1]; rep != 0x00 {
+		// general failure, unreachable network or host may pass
+		return rep == 0x01 || rep == 0x03 || rep == 0x04, fmt.Errorf("connect %s refused with code %d", host, rep)
+	}
+	var skip int
+	switch buf[3] {
+	case 0x01:
+		skip = net.IPv4len
+	case 0x04:
+		skip = net.IPv6len
+	case 0x03:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return true, err
+		}
+		skip = int(buf[0])
+	default:
+		return false, fmt.Errorf("unexpected address type %d", buf[3])
+	}
+	// the bound address and port are of no use to the caller
+	_, err := io.CopyN(io.Discard, conn, int64(skip+2))
+	return true, err
+}
@@ -0,0 +1,184 @@
+package netgo
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SRVResolver looks up SRV records
+type SRVResolver interface {
+	// LookupSRV returns the records of name and how long they may be
+	// cached, zero when the resolver does not know
+	LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error)
+}
+
+// LookupSRV implements SRVResolver
+func (r SystemResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	res := r.Resolver
+	if res == nil {
+		res = net.DefaultResolver
+	}
+	_, records, err := res.LookupSRV(ctx, "", "", name)
+	return records, 0, err
+}
+
+// LookupSRV implements SRVResolver
+func (r *DNSServerResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	return lookupSRV(ctx, name, true, r.exchange)
+}
+
+// LookupSRV implements SRVResolver
+func (r *DoHResolver) LookupSRV(ctx context.Context, name string) ([]*net.SRV, time.Duration, error) {
+	return lookupSRV(ctx, name, false, r.exchange)
+}
+
+func lookupSRV(ctx context.Context, name string, randomID bool, exchange dnsExchange) ([]*net.SRV, time.Duration, error) {
+	resp, err := queryDNS(ctx, name, dnsTypeSRV, randomID, exchange)
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		records []*net.SRV
+		ttl     uint32
+	)
+	for _, rr := range resp.answers {
+		if rr.typ != dnsTypeSRV || len(rr.data) < 7 {
+			continue
+		}
+		target, _, err := dnsName(rr.msg, rr.off+6)
+		if err != nil {
+			return nil, 0, &net.DNSError{Err: err.Error(), Name: name}
+		}
+		records = append(records, &net.SRV{
+			Priority: binary.BigEndian.Uint16(rr.data),
+			Weight:   binary.BigEndian.Uint16(rr.data[2:]),
+			Port:     binary.BigEndian.Uint16(rr.data[4:]),
+			Target:   target + ".",
+		})
+		if len(records) == 1 || rr.ttl < ttl {
+			ttl = rr.ttl
+		}
+	}
+	if len(records) == 0 {
+		return nil, 0, &net.DNSError{Err: "no SRV records", Name: name, IsNotFound: true}
+	}
+	return records, time.Duration(ttl) * time.Second, nil
+}
+
+// SRVDiscovery finds the endpoints of a service through its SRV records,
+// e.g. _api._tcp.service.consul. The records are looked up again once
+// their TTL or Refresh passed.
+type SRVDiscovery struct {
+	Name string
+	// Resolver looks up the records, a SystemResolver by default
+	Resolver SRVResolver
+	// Refresh bounds how long records are used, 30s by default
+	Refresh time.Duration
+
+	mu      sync.Mutex
+	records []*net.SRV
+	expires time.Time
+}
+
+// WithSRV dials the endpoints of d whenever the client connects to host,
+// at any port: by priority, spread by weight, failing over to the next
+// endpoint when one cannot be dialed. TLS still verifies host, see
+// WithServerName otherwise.
+func WithSRV(host string, d *SRVDiscovery) Option {
+	return withDialer(func(dl *dialer) {
+		srv := make(map[string]*SRVDiscovery, len(dl.srv)+1)
+		for k, v := range dl.srv {
+			srv[k] = v
+		}
+		srv[strings.ToLower(host)] = d
+		dl.srv = srv
+	})
+}
+
+// Targets returns the endpoints as host:port in the order to try them
+func (d *SRVDiscovery) Targets(ctx context.Context) ([]string, error) {
+	records, err := d.lookup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 1 && records[0].Target == "." {
+		return nil, fmt.Errorf("netter: service %s is not available", d.Name)
+	}
+	ordered := orderSRV(records)
+	targets := make([]string, len(ordered))
+	for i, r := range ordered {
+		targets[i] = net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port)))
+	}
+	return targets, nil
+}
+
+func (d *SRVDiscovery) lookup(ctx context.Context) ([]*net.SRV, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	if d.records != nil && now.Before(d.expires) {
+		return d.records, nil
+	}
+	res := d.Resolver
+	if res == nil {
+		res = SystemResolver{}
+	}
+	records, ttl, err := res.LookupSRV(ctx, d.Name)
+	if err != nil {
+		if d.records != nil {
+			// keep the known endpoints while the lookup fails
+			return d.records, nil
+		}
+		return nil, err
+	}
+	refresh := durationOr(d.Refresh, 30*time.Second)
+	if ttl <= 0 || ttl > refresh {
+		ttl = refresh
+	}
+	d.records, d.expires = records, now.Add(ttl)
+	return records, nil
+}
+
+// orderSRV sorts records by priority and, within a priority, by the
+// weighted random selection of RFC 2782
+func orderSRV(records []*net.SRV) []*net.SRV {
+	sorted := append([]*net.SRV(nil), records...)
+	sort.SliceStable(sorted, func(a, b int) bool { return sorted[a].Priority < sorted[b].Priority })
+	out := make([]*net.SRV, 0, len(sorted))
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j].Priority == sorted[i].Priority {
+			j++
+		}
+		group := sorted[i:j]
+		for len(group) > 0 {
+			total := 0
+			for _, r := range group {
+				total += int(r.Weight)
+			}
+			pick := 0
+			if total > 0 {
+				n := rand.Intn(total + 1)
+				for sum := 0; pick < len(group); pick++ {
+					if sum += int(group[pick].Weight); sum >= n && (group[pick].Weight > 0 || n == 0) {
+						break
+					}
+				}
+			} else {
+				pick = rand.Intn(len(group))
+			}
+			out = append(out, group[pick])
+			group = append(group[:pick:pick], group[pick+1:]...)
+		}
+		i = j
+	}
+	return out
+}
@@ -0,0 +1,240 @@
+package netgo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// withTLSConfig clones the client transport and lets fn adjust its TLS
+// configuration; other transports are left alone
+func withTLSConfig(fn func(*tls.Config)) Option {
+	return func(c *Client) {
+		tr, ok := c.Inner.Transport.(*http.Transport)
+		if !ok {
+			return
+		}
+		tr = tr.Clone()
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+		fn(tr.TLSClientConfig)
+		c.Inner.Transport = tr
+	}
+}
+
+// WithTLSMinVersion refuses TLS versions below v, e.g. tls.VersionTLS13
+func WithTLSMinVersion(v uint16) Option {
+	return withTLSConfig(func(cfg *tls.Config) { cfg.MinVersion = v })
+}
+
+// WithCipherSuites limits the TLS 1.2 cipher suites offered to ids;
+// TLS 1.3 suites are not configurable. Suites listed by
+// tls.InsecureCipherSuites fail every request of the client.
+func WithCipherSuites(ids ...uint16) Option {
+	return func(c *Client) {
+		for _, insecure := range tls.InsecureCipherSuites() {
+			for _, id := range ids {
+				if id == insecure.ID {
+					c.setErr(fmt.Errorf("netter: insecure cipher suite %s", insecure.Name))
+					return
+				}
+			}
+		}
+		withTLSConfig(func(cfg *tls.Config) { cfg.CipherSuites = ids })(c)
+	}
+}
+
+// WithServerName sends name as SNI and verifies server certificates
+// against it, for hosts dialed by IP address; see Request.SetSNI for a
+// single request
+func WithServerName(name string) Option {
+	return withTLSConfig(func(cfg *tls.Config) { cfg.ServerName = name })
+}
+
+// WithInsecureSkipVerify accepts any server certificate. Meant for
+// development only, it logs a warning through the logger the client has
+// once all options are applied.
+func WithInsecureSkipVerify() Option {
+	return func(c *Client) {
+		c.afterOptions(func(c *Client) {
+			c.log().Printf("netter: WARNING: TLS certificate verification disabled, connections are open to interception")
+		})
+		withTLSConfig(func(cfg *tls.Config) { cfg.InsecureSkipVerify = true })(c)
+	}
+}
+
+// WithRootCAsPEM verifies servers against the PEM certificates in pem
+// only. A bundle without certificates fails every request of the client.
+func WithRootCAsPEM(pem []byte) Option {
+	return func(c *Client) {
+		pool := x509.NewCertPool()
+		if err := appendCAs(pool, pem); err != nil {
+			c.setErr(err)
+			return
+		}
+		withTLSConfig(func(cfg *tls.Config) { cfg.RootCAs = pool })(c)
+	}
+}
+
+// WithRootCAsFile verifies servers against the PEM certificates in the
+// file at path only. A bundle that cannot be loaded fails every request
+// of the client.
+func WithRootCAsFile(path string) Option {
+	return func(c *Client) {
+		pem, err := readCAs(path)
+		if err != nil {
+			c.setErr(err)
+			return
+		}
+		WithRootCAsPEM(pem)(c)
+	}
+}
+
+// WithRootCAPool verifies servers against pool only. A nil pool fails
+// every request of the client rather than trusting the system roots.
+func WithRootCAPool(pool *x509.CertPool) Option {
+	return func(c *Client) {
+		if pool == nil {
+			c.setErr(errors.New("netter: nil root CA pool"))
+			return
+		}
+		withTLSConfig(func(cfg *tls.Config) { cfg.RootCAs = pool })(c)
+	}
+}
+
+// WithSystemCAsPlusPEM verifies servers against the system roots and the
+// PEM certificates in pem
+func WithSystemCAsPlusPEM(pem []byte) Option {
+	return func(c *Client) {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if err := appendCAs(pool, pem); err != nil {
+			c.setErr(err)
+			return
+		}
+		withTLSConfig(func(cfg *tls.Config) { cfg.RootCAs = pool })(c)
+	}
+}
+
+// WithSystemCAsPlusFile verifies servers against the system roots and
+// the PEM certificates in the file at path
+func WithSystemCAsPlusFile(path string) Option {
+	return func(c *Client) {
+		pem, err := readCAs(path)
+		if err != nil {
+			c.setErr(err)
+			return
+		}
+		WithSystemCAsPlusPEM(pem)(c)
+	}
+}
+
+func readCAs(path string) ([]byte, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("netter: reading CA bundle: %w", err)
+	}
+	return pem, nil
+}
+
+func appendCAs(pool *x509.CertPool, pem []byte) error {
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("netter: no certificates in CA bundle")
+	}
+	return nil
+}
+
+// setErr records the first error of an option
+func (c *Client) setErr(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// WithClientCertificate presents the PEM key pair in certFile and
+// keyFile to servers asking for a client certificate. The files are
+// read at the first handshake, whose error reports unreadable ones.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return WithCertificateReloader(&CertificateReloader{CertFile: certFile, KeyFile: keyFile})
+}
+
+// WithCertificateReloader presents the client certificate of r, picking
+// up rotated files without rebuilding the transport
+func WithCertificateReloader(r *CertificateReloader) Option {
+	return withTLSConfig(func(cfg *tls.Config) {
+		cfg.Certificates = nil
+		cfg.GetClientCertificate = r.GetClientCertificate
+	})
+}
+
+// CertificateReloader serves a client certificate from PEM files and
+// reloads it once the files change, e.g. when a sidecar rotates them.
+// A pair failing to load, say while only one file was replaced, leaves
+// the previous certificate in use until the next check. Pooled
+// connections keep the certificate they were established with.
+type CertificateReloader struct {
+	CertFile string
+	KeyFile  string
+	// Interval between checks of the file modification times, made
+	// during handshakes; zero loads the files only once
+	Interval time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	checkedAt time.Time
+}
+
+// GetClientCertificate implements tls.Config.GetClientCertificate
+func (r *CertificateReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cert == nil {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+		return r.cert, nil
+	}
+	if r.Interval > 0 && time.Since(r.checkedAt) >= r.Interval {
+		r.checkedAt = time.Now()
+		certMod, keyMod := modTime(r.CertFile), modTime(r.KeyFile)
+		if !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod) {
+			r.load()
+		}
+	}
+	return r.cert, nil
+}
+
+// Reload reads the key pair now
+func (r *CertificateReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.load()
+}
+
+func (r *CertificateReloader) load() error {
+	certMod, keyMod := modTime(r.CertFile), modTime(r.KeyFile)
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return err
+	}
+	r.cert, r.certMod, r.keyMod, r.checkedAt = &cert, certMod, keyMod, time.Now()
+	return nil
+}
+
+func modTime(name string) time.Time {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
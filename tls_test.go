@@ -0,0 +1,250 @@
+package netgo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed PEM key pair for cn into dir
+func writeCert(t *testing.T, dir, cn string, notAfter time.Time) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		DNSNames:              []string{cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if ip := net.ParseIP(cn); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestCertificateReloader(t *testing.T) {
+	var seen []string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		seen = append(seen, req.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first", time.Now().Add(time.Hour))
+	r := &CertificateReloader{CertFile: certFile, KeyFile: keyFile, Interval: time.Millisecond}
+	client := NewClient(WithTransport(ts.Client().Transport), WithCertificateReloader(r))
+	get := func() {
+		resp, err := client.Get(ts.URL)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("get: %v %v", resp, err)
+		}
+		resp.Body.Close()
+		client.Inner.CloseIdleConnections()
+	}
+	get()
+	writeCert(t, dir, "second", time.Now().Add(time.Hour))
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	time.Sleep(2 * time.Millisecond)
+	get()
+	if len(seen) != 2 || seen[0] != "first" || seen[1] != "second" {
+		t.Fatalf("client certificates %q, want first then second", seen)
+	}
+
+	client = NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{}),
+		WithClientCertificate(filepath.Join(dir, "missing.pem"), keyFile))
+	if _, err := client.Get(ts.URL); err == nil {
+		t.Fatal("handshake succeeded without certificate files")
+	}
+}
+
+func TestRootCAs(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "127.0.0.1", time.Now().Add(time.Hour))
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	ts.StartTLS()
+	defer ts.Close()
+
+	pemCerts, _ := os.ReadFile(certFile)
+	for name, opt := range map[string]Option{
+		"file":       WithRootCAsFile(certFile),
+		"bytes":      WithRootCAsPEM(pemCerts),
+		"plus file":  WithSystemCAsPlusFile(certFile),
+		"plus bytes": WithSystemCAsPlusPEM(pemCerts),
+	} {
+		client := NewClient(WithRetry(Retry{}), opt)
+		resp, err := client.Get(ts.URL)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: %v %v", name, resp, err)
+		}
+		resp.Body.Close()
+	}
+
+	client := NewClient(WithRetry(Retry{}), WithRootCAsPEM([]byte("garbage")))
+	if _, err := client.Get(ts.URL); err == nil || !strings.Contains(err.Error(), "no certificates") {
+		t.Fatalf("bad bundle: %v", err)
+	}
+	var pool *x509.CertPool
+	client = NewClient(WithRetry(Retry{}), WithRootCAPool(pool))
+	if _, err := client.Get(ts.URL); err == nil || !strings.Contains(err.Error(), "nil root CA pool") {
+		t.Fatalf("nil pool: %v", err)
+	}
+	if _, err := NewClient(WithRetry(Retry{})).Get(ts.URL); err == nil {
+		t.Fatal("unknown CA trusted by default")
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	var (
+		versions    []uint16
+		serverNames []string
+	)
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		versions = append(versions, req.TLS.Version)
+		serverNames = append(serverNames, req.TLS.ServerName)
+	}))
+	ts.StartTLS()
+	defer ts.Close()
+
+	var logs strings.Builder
+	client := NewClient(WithRetry(Retry{}), WithInsecureSkipVerify(), WithLogger(log.New(&logs, "", 0)),
+		WithTLSMinVersion(tls.VersionTLS13), WithServerName("example.com"))
+	resp, err := client.Get(ts.URL)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("get: %v %v", resp, err)
+	}
+	resp.Body.Close()
+	if len(versions) != 1 || versions[0] != tls.VersionTLS13 || !strings.Contains(logs.String(), "WARNING") {
+		t.Fatalf("versions %v, log %q", versions, logs.String())
+	}
+	if serverNames[0] != "example.com" {
+		t.Fatalf("SNI %q", serverNames[0])
+	}
+
+	client = NewClient(WithRetry(Retry{}), WithCipherSuites(tls.TLS_RSA_WITH_RC4_128_SHA))
+	if _, err := client.Get(ts.URL); err == nil || !strings.Contains(err.Error(), "insecure cipher suite") {
+		t.Fatalf("insecure suite: %v", err)
+	}
+}
+
+func TestCertExpiryMonitor(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "127.0.0.1", time.Now().Add(72*time.Hour))
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	ts.StartTLS()
+	defer ts.Close()
+
+	var (
+		logs     strings.Builder
+		expiring []string
+	)
+	m := &CertExpiryMonitor{OnExpiring: func(server string, notAfter time.Time) {
+		expiring = append(expiring, server)
+	}}
+	client := NewClient(WithRootCAsFile(certFile), WithCertExpiryMonitor(m), WithLogger(log.New(&logs, "", 0)))
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if len(expiring) != 1 || expiring[0] != "127.0.0.1" || !strings.Contains(logs.String(), "certificate of 127.0.0.1 expires") {
+		t.Fatalf("warnings for %q, log %q", expiring, logs.String())
+	}
+	if m.Expiries()["127.0.0.1"].IsZero() {
+		t.Fatalf("expiry not recorded: %v", m.Expiries())
+	}
+}
+
+func TestHTTP2(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	ts.EnableHTTP2 = true
+	ts.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	ts.StartTLS()
+	defer ts.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	for _, tc := range []struct {
+		opts  []Option
+		proto int
+	}{
+		{nil, 1},
+		{[]Option{WithHTTP2(HTTP2{ReadIdleTimeout: time.Second, PingTimeout: time.Second})}, 2},
+		{[]Option{WithHTTP2(HTTP2{}), WithHTTP2(HTTP2{Disable: true})}, 1},
+	} {
+		client := NewClient(append([]Option{WithRootCAPool(pool), WithRetry(Retry{})}, tc.opts...)...)
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("HTTP/%d: %v", tc.proto, err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != tc.proto {
+			t.Fatalf("got %s, want HTTP/%d", resp.Proto, tc.proto)
+		}
+	}
+
+	// requests share one connection
+	client := NewClient(WithRootCAPool(pool), WithHTTP2(HTTP2{}), WithPoolStats(), WithRetry(Retry{}))
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if got := client.PoolStats()[ts.Listener.Addr().String()]; got.New != 1 || got.Reused != 2 || got.Handshakes != 1 {
+		t.Fatalf("HTTP/2 connections not reused: %+v", got)
+	}
+}
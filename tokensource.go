@@ -0,0 +1,95 @@
+package netgo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Token is an access token sent in the Authorization header
+type Token struct {
+	AccessToken string
+	// TokenType is the auth scheme, "Bearer" when empty
+	TokenType string
+	// Expiry is when the token stops being valid, zero if never
+	Expiry time.Time
+}
+
+// tokenExpiryDelta renews tokens that shortly before they expire
+const tokenExpiryDelta = 10 * time.Second
+
+func (t *Token) valid(now time.Time) bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || now.Add(tokenExpiryDelta).Before(t.Expiry))
+}
+
+func (t *Token) header() string {
+	typ := t.TokenType
+	if typ == "" || typ == "bearer" {
+		typ = "Bearer"
+	}
+	return typ + " " + t.AccessToken
+}
+
+// TokenSource supplies access tokens. It has the shape of
+// oauth2.TokenSource, which adapts with a one-line wrapper.
+type TokenSource interface {
+	Token() (*Token, error)
+}
+
+// TokenSourceFunc is a TokenSource implemented by a function
+type TokenSourceFunc func() (*Token, error)
+
+// Token implements TokenSource
+func (f TokenSourceFunc) Token() (*Token, error) {
+	return f()
+}
+
+// tokenCache holds the current token of Client.TokenSource, fetched
+// lazily and dropped when it expires or is rejected
+type tokenCache struct {
+	mu  sync.Mutex
+	tok *Token
+}
+
+func (c *Client) tokenCache() *tokenCache {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	if c.tokens == nil {
+		c.tokens = &tokenCache{}
+	}
+	return c.tokens
+}
+
+// token returns a valid token, asking the source when there is none;
+// sources fetching tokens themselves do so with ctx
+func (c *Client) token(ctx context.Context) (*Token, error) {
+	if rs, ok := c.TokenSource.(refreshingSource); ok {
+		return rs.tokenContext(ctx)
+	}
+	tc := c.tokenCache()
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.tok.valid(time.Now()) {
+		return tc.tok, nil
+	}
+	tok, err := c.TokenSource.Token()
+	if err != nil {
+		return nil, err
+	}
+	tc.tok = tok
+	return tok, nil
+}
+
+// invalidateToken drops the token whose header value was rejected
+func (c *Client) invalidateToken(rejected string) {
+	if rs, ok := c.TokenSource.(refreshingSource); ok {
+		rs.invalidate()
+		return
+	}
+	tc := c.tokenCache()
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.tok != nil && tc.tok.header() == rejected {
+		tc.tok = nil
+	}
+}
@@ -1,21 +1,40 @@
 package netgo
 
 import (
+	"context"
 	"net"
 	"net/http"
+	"reflect"
 	"time"
 )
 
+// baseDialer connects the default transport and dialers
+var baseDialer = &net.Dialer{
+	// Limits the time spent establishing a TCP connection
+	// Errors:
+	// i/o timeout
+	Timeout: 30 * time.Second,
+	// TCP KeepAlive specifies the interval between keep-alive probes for an active network connection.
+	KeepAlive: 30 * time.Second,
+	// Checks the addresses dialed against AllowedHosts and DeniedHosts
+	ControlContext: controlHostPolicy,
+}
+
+// dialBase dials addr through baseDialer
+func dialBase(ctx context.Context, network, addr string) (net.Conn, error) {
+	return baseDialer.DialContext(dialingHost(ctx, addr), network, addr)
+}
+
+// isBaseDial tells whether dial is dialBase
+func isBaseDial(dial func(ctx context.Context, network, addr string) (net.Conn, error)) bool {
+	return dial != nil && reflect.ValueOf(dial).Pointer() == reflect.ValueOf(dialBase).Pointer()
+}
+
+// defaultTransport is the template cloned for every client; it is never
+// used for requests itself
 var defaultTransport = &http.Transport{
-	Proxy: http.ProxyFromEnvironment,
-	DialContext: (&net.Dialer{
-		// Limits the time spent establishing a TCP connection
-		// Errors:
-		// i/o timeout
-		Timeout: 30 * time.Second,
-		// TCP KeepAlive specifies the interval between keep-alive probes for an active network connection.
-		KeepAlive: 30 * time.Second,
-	}).DialContext,
+	Proxy:       http.ProxyFromEnvironment,
+	DialContext: dialBase,
 	// Limits the time spent reading the headers of the response
 	// Errors:
 	// net/http: timeout awaiting response headers
@@ -27,3 +46,28 @@ var defaultTransport = &http.Transport{
 	DisableKeepAlives:     true,
 	MaxIdleConnsPerHost:   -1,
 }
+
+// WithKeepAlives keeps connections open for reuse, up to maxIdlePerHost
+// idle ones per host, 2 when 0 or less. The default transport closes
+// every connection after its request.
+func WithKeepAlives(maxIdlePerHost int) Option {
+	return func(c *Client) {
+		tr, ok := c.Inner.Transport.(*http.Transport)
+		if !ok {
+			return
+		}
+		tr = tr.Clone()
+		keepAlive(tr, maxIdlePerHost)
+		c.Inner.Transport = tr
+	}
+}
+
+// keepAlive enables keep-alives on tr, keeping up to maxIdlePerHost idle
+// connections per host, 2 when 0 or less
+func keepAlive(tr *http.Transport, maxIdlePerHost int) {
+	tr.DisableKeepAlives = false
+	tr.MaxIdleConnsPerHost = maxIdlePerHost
+	if maxIdlePerHost <= 0 {
+		tr.MaxIdleConnsPerHost = http.DefaultMaxIdleConnsPerHost
+	}
+}
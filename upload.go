@@ -0,0 +1,228 @@
+package netgo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// UploadProtocol adapts ChunkedUpload to a resumable upload API, such as
+// tus or S3-style multipart uploads
+type UploadProtocol interface {
+	// Begin creates an upload of size bytes, -1 when unknown, and
+	// returns the location chunks are sent to
+	Begin(ctx context.Context, c *Client, size int64) (string, error)
+	// Chunk builds the request sending one chunk of the upload
+	Chunk(ctx context.Context, ch UploadChunk) (*Request, error)
+	// Finish completes the upload given the response headers of every
+	// part, e.g. to collect their ETags
+	Finish(ctx context.Context, c *Client, location string, parts []http.Header) error
+}
+
+// UploadResumer is implemented by upload protocols able to tell how many
+// bytes of an upload the server holds. ChunkedUpload asks after a chunk
+// failed and resends only its remainder, as the server may have stored a
+// part of it.
+type UploadResumer interface {
+	Offset(ctx context.Context, c *Client, location string) (int64, error)
+}
+
+// UploadChunk describes a part of an upload passed to
+// UploadProtocol.Chunk
+type UploadChunk struct {
+	// Location returned by Begin
+	Location string
+	// Part counts chunks from zero
+	Part int
+	// Offset of Body within the upload
+	Offset int64
+	Body   []byte
+	// Size of the whole upload, -1 when unknown
+	Size int64
+	// Last is set for the final chunk
+	Last bool
+}
+
+// ChunkedUpload sends a large body as a series of requests, one per
+// chunk. Every chunk goes through the retry loop on its own, so a failed
+// chunk is resent without starting the whole transfer over.
+type ChunkedUpload struct {
+	// ChunkSize of each request, 8MB by default
+	ChunkSize int64
+	// Protocol creates the upload and frames its chunks
+	Protocol UploadProtocol
+}
+
+// Upload reads body in chunks and sends them in order. size is the
+// length of body or -1 when unknown; opts apply to every chunk request.
+// It returns the number of bytes sent.
+func (u *ChunkedUpload) Upload(ctx context.Context, c *Client, body io.Reader, size int64, opts ...RequestOption) (int64, error) {
+	chunk := u.ChunkSize
+	if chunk <= 0 {
+		chunk = 8 << 20
+	}
+	location, err := u.Protocol.Begin(ctx, c, size)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		offset int64
+		parts  []http.Header
+		buf    = make([]byte, chunk)
+		br     = bufio.NewReader(body)
+	)
+	for {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return offset, err
+		}
+		// look ahead so the final chunk is marked as such
+		_, err = br.Peek(1)
+		if err != nil && err != io.EOF {
+			return offset, err
+		}
+		last := err == io.EOF
+
+		header, err := u.send(ctx, c, UploadChunk{
+			Location: location,
+			Part:     len(parts),
+			Offset:   offset,
+			Body:     buf[:n],
+			Size:     size,
+			Last:     last,
+		}, opts)
+		if err != nil {
+			return offset, err
+		}
+		parts = append(parts, header)
+		offset += int64(n)
+		if last {
+			break
+		}
+	}
+
+	if size >= 0 && offset != size {
+		return offset, fmt.Errorf("netter: upload %s: sent %d of %d bytes", location, offset, size)
+	}
+	return offset, u.Protocol.Finish(ctx, c, location, parts)
+}
+
+// send sends ch and returns the response headers. With an UploadResumer
+// a failed chunk is resent from the offset the server reports, as often
+// as the retry policy allows.
+func (u *ChunkedUpload) send(ctx context.Context, c *Client, ch UploadChunk, opts []RequestOption) (http.Header, error) {
+	resumer, _ := u.Protocol.(UploadResumer)
+	end := ch.Offset + int64(len(ch.Body))
+	for i := 0; ; i++ {
+		req, err := u.Protocol.Chunk(ctx, ch)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.Do(req, opts...)
+		if err == nil {
+			if err = CheckStatus(resp); err == nil {
+				CloseBody(resp)
+				return resp.Header, nil
+			}
+		}
+		err = fmt.Errorf("netter: upload %s: part %d at %d: %w", ch.Location, ch.Part, ch.Offset, err)
+		if resumer == nil || i >= c.retryPolicy(req).Max || ctx.Err() != nil {
+			return nil, err
+		}
+
+		offset, oerr := resumer.Offset(ctx, c, ch.Location)
+		if oerr != nil || offset < ch.Offset || offset > end {
+			return nil, err
+		}
+		if offset == end {
+			// the chunk arrived, only its response got lost
+			return http.Header{}, nil
+		}
+		c.log().Printf("netter: upload %s: part %d failed, resending from %d: %v", ch.Location, ch.Part, offset, err)
+		ch.Body = ch.Body[offset-ch.Offset:]
+		ch.Offset = offset
+	}
+}
+
+// TusUpload speaks the core tus 1.0 protocol with the creation
+// extension: Begin POSTs to Endpoint and every chunk is a PATCH to the
+// returned location
+type TusUpload struct {
+	Endpoint string
+}
+
+// Begin creates the upload and returns its Location header
+func (t TusUpload) Begin(ctx context.Context, c *Client, size int64) (string, error) {
+	req, err := NewRequestWithContext(ctx, "POST", t.Endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	if size >= 0 {
+		req.Header.Set("Upload-Length", strconv.FormatInt(size, 10))
+	} else {
+		req.Header.Set("Upload-Defer-Length", "1")
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	if err := CheckStatus(resp); err != nil {
+		return "", err
+	}
+	CloseBody(resp)
+	loc, err := resp.Location()
+	if err != nil {
+		return "", fmt.Errorf("netter: tus upload %s: %w", t.Endpoint, err)
+	}
+	return loc.String(), nil
+}
+
+// Chunk PATCHes the chunk at its Upload-Offset, declaring the length
+// with the last chunk when it was deferred
+func (t TusUpload) Chunk(ctx context.Context, ch UploadChunk) (*Request, error) {
+	req, err := NewRequestWithContext(ctx, "PATCH", ch.Location, ch.Body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", strconv.FormatInt(ch.Offset, 10))
+	if ch.Last && ch.Size < 0 {
+		req.Header.Set("Upload-Length", strconv.FormatInt(ch.Offset+int64(len(ch.Body)), 10))
+	}
+	return req, nil
+}
+
+// Offset implements UploadResumer with a HEAD request reading the
+// Upload-Offset of location
+func (t TusUpload) Offset(ctx context.Context, c *Client, location string) (int64, error) {
+	req, err := NewRequestWithContext(ctx, "HEAD", location, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	err = CheckStatus(resp)
+	CloseBody(resp)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("netter: tus upload %s: bad Upload-Offset: %w", location, err)
+	}
+	return offset, nil
+}
+
+// Finish has nothing to do, the last PATCH completes a tus upload
+func (t TusUpload) Finish(ctx context.Context, c *Client, location string, parts []http.Header) error {
+	return nil
+}
@@ -0,0 +1,108 @@
+package netgo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChunkedUploadTus(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		got     bytes.Buffer
+		patches int
+		failed  bool
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method {
+		case "POST":
+			if req.Header.Get("Upload-Length") != "10" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Location", "/files/1")
+			w.WriteHeader(http.StatusCreated)
+		case "PATCH":
+			patches++
+			body, _ := ioutil.ReadAll(req.Body)
+			if patches == 2 && !failed {
+				failed = true
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if req.Header.Get("Upload-Offset") != strconv.Itoa(got.Len()) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			got.Write(body)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	u := &ChunkedUpload{ChunkSize: 4, Protocol: TusUpload{Endpoint: ts.URL + "/files"}}
+	n, err := u.Upload(context.Background(), client, strings.NewReader("0123456789"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 || got.String() != "0123456789" || patches != 4 {
+		t.Fatalf("uploaded %d bytes %q in %d requests", n, got.String(), patches)
+	}
+}
+
+func TestChunkedUploadTusResync(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		got     bytes.Buffer
+		partial bool
+		heads   int
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.Method {
+		case "POST":
+			w.Header().Set("Location", "/files/1")
+			w.WriteHeader(http.StatusCreated)
+		case "HEAD":
+			heads++
+			w.Header().Set("Upload-Offset", strconv.Itoa(got.Len()))
+		case "PATCH":
+			if req.Header.Get("Upload-Offset") != strconv.Itoa(got.Len()) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			if got.Len() == 4 && !partial {
+				// keep half of the chunk, then fail
+				partial = true
+				io.CopyN(&got, req.Body, 2)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			io.Copy(&got, req.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithTransport(ts.Client().Transport), WithRetry(Retry{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}))
+	u := &ChunkedUpload{ChunkSize: 4, Protocol: TusUpload{Endpoint: ts.URL + "/files"}}
+	n, err := u.Upload(context.Background(), client, strings.NewReader("0123456789"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 || got.String() != "0123456789" || heads != 1 {
+		t.Fatalf("uploaded %d bytes %q after %d offset checks", n, got.String(), heads)
+	}
+}
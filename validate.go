@@ -0,0 +1,152 @@
+package netgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidRequest is matched by errors.Is for requests rejected by a
+// URLPolicy, AllowedHosts or DeniedHosts
+var ErrInvalidRequest = errors.New("netter: invalid request")
+
+// InvalidRequestError tells why a request URL was rejected
+type InvalidRequestError struct {
+	URL    string
+	Reason string
+}
+
+func (e *InvalidRequestError) Error() string {
+	return fmt.Sprintf("netter: invalid request %s: %s", e.URL, e.Reason)
+}
+
+// Unwrap returns ErrInvalidRequest
+func (e *InvalidRequestError) Unwrap() error {
+	return ErrInvalidRequest
+}
+
+// URLPolicy validates request URLs before anything is dialed
+type URLPolicy struct {
+	// Schemes allowed, http and https by default
+	Schemes []string
+	// AllowUserinfo permits credentials in the URL
+	AllowUserinfo bool
+	// MaxLength of the URL in bytes, 8192 by default
+	MaxLength int
+}
+
+func (p *URLPolicy) check(u *url.URL) error {
+	raw := u.String()
+	reject := func(format string, args ...interface{}) error {
+		return &InvalidRequestError{URL: u.Redacted(), Reason: fmt.Sprintf(format, args...)}
+	}
+
+	max := p.MaxLength
+	if max == 0 {
+		max = 8192
+	}
+	if len(raw) > max {
+		return reject("URL longer than %d bytes", max)
+	}
+
+	schemes := p.Schemes
+	if schemes == nil {
+		schemes = []string{"http", "https"}
+	}
+	allowed := false
+	for _, s := range schemes {
+		if strings.EqualFold(s, u.Scheme) {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return reject("scheme %q not allowed", u.Scheme)
+	}
+
+	if u.User != nil && !p.AllowUserinfo {
+		return reject("userinfo not allowed")
+	}
+	return checkHost(u, reject)
+}
+
+func checkHost(u *url.URL, reject func(string, ...interface{}) error) error {
+	host := u.Hostname()
+	if host == "" {
+		return reject("missing host")
+	}
+	if port := u.Port(); port != "" {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return reject("bad port %q", port)
+		}
+	} else if strings.HasSuffix(u.Host, ":") {
+		return reject("empty port")
+	}
+	if strings.Contains(u.Host, "[") {
+		if net.ParseIP(host) == nil {
+			return reject("bad IP literal %q", host)
+		}
+		return nil
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if len(host) > 253 {
+		return reject("host name too long")
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return reject("bad host name %q", host)
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return reject("bad host name %q", host)
+			}
+		}
+	}
+	return nil
+}
+
+// checkURL applies the URLPolicy and host policy to u
+func (c *Client) checkURL(ctx context.Context, u *url.URL) error {
+	if c.URLPolicy != nil {
+		if err := c.URLPolicy.check(u); err != nil {
+			return err
+		}
+	}
+	return c.checkHostPolicy(ctx, u)
+}
+
+// checkingRedirects returns inner, or a copy checking redirects when
+// the client has a URLPolicy or host policy
+func (c *Client) checkingRedirects(inner *http.Client) *http.Client {
+	if c.URLPolicy == nil && len(c.AllowedHosts) == 0 && len(c.DeniedHosts) == 0 {
+		return inner
+	}
+	cp := *inner
+	cp.CheckRedirect = c.checkRedirect(inner.CheckRedirect)
+	return &cp
+}
+
+// checkRedirect applies the URLPolicy and host policy to every redirect
+// before next, or the ten redirect limit of http.Client, decides
+func (c *Client) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if err := c.checkURL(req.Context(), req.URL); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,384 @@
+package netgo
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebSocket message and control frame opcodes
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketMessage bounds messages read unless
+// WebSocketOptions.MaxMessageBytes says otherwise
+const maxWebSocketMessage = 32 << 20
+
+// ErrWebSocketClosed is returned once the connection was closed locally
+var ErrWebSocketClosed = errors.New("netter: websocket closed")
+
+// WebSocketCloseError reports a close frame sent by the peer
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return fmt.Sprintf("netter: websocket closed by peer: %d %s", e.Code, e.Reason)
+}
+
+// WebSocketOptions configures DialWebSocket
+type WebSocketOptions struct {
+	// Header is sent with the opening handshake
+	Header http.Header
+	// Subprotocols offered to the server, in order of preference
+	Subprotocols []string
+	// PingInterval between keepalive pings, 30s by default; negative
+	// disables them. A connection silent for two intervals while
+	// ReadMessage waits is dropped.
+	PingInterval time.Duration
+	// MaxMessageBytes bounds messages read, 32MB by default
+	MaxMessageBytes int64
+	// Reconnect redials a dropped connection within ReadMessage,
+	// OnReconnect is then called, e.g. to resubscribe
+	Reconnect   bool
+	OnReconnect func(ws *WebSocketConn) error
+}
+
+// DialWebSocket opens a WebSocket connection to a ws, wss, http or https
+// url. The handshake is an ordinary request of the client: it takes its
+// transport, middleware, auth and retry policy, so a refused or failing
+// handshake is retried with backoff like any other request.
+func (c *Client) DialWebSocket(ctx context.Context, url string, opts *WebSocketOptions) (*WebSocketConn, error) {
+	if opts == nil {
+		opts = &WebSocketOptions{}
+	}
+	ws := &WebSocketConn{c: c, url: url, opts: opts, ctx: ctx, done: make(chan struct{})}
+	if err := ws.dial(); err != nil {
+		return nil, err
+	}
+	if opts.PingInterval >= 0 {
+		go ws.keepalive()
+	}
+	return ws, nil
+}
+
+// WebSocketConn is a client WebSocket connection. Reads and writes may
+// happen concurrently, but only one goroutine may read at a time.
+type WebSocketConn struct {
+	c    *Client
+	url  string
+	opts *WebSocketOptions
+	ctx  context.Context
+
+	// Subprotocol chosen by the server
+	Subprotocol string
+
+	mu       sync.Mutex
+	rwc      io.ReadWriteCloser
+	br       *bufio.Reader
+	lastRead time.Time
+	reading  bool
+	closed   bool
+	done     chan struct{}
+	wmu      sync.Mutex
+}
+
+func (ws *WebSocketConn) dial() error {
+	url := ws.url
+	if strings.HasPrefix(url, "ws://") {
+		url = "http://" + url[len("ws://"):]
+	} else if strings.HasPrefix(url, "wss://") {
+		url = "https://" + url[len("wss://"):]
+	}
+	req, err := NewRequestWithContext(ws.ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for k, vs := range ws.opts.Header {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	if len(ws.opts.Subprotocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(ws.opts.Subprotocols, ", "))
+	}
+	req.upgrade = true
+
+	resp, err := ws.c.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		if err := CheckStatus(resp); err != nil {
+			return err
+		}
+		CloseBody(resp)
+		return fmt.Errorf("netter: websocket %s: handshake answered with %s", ws.url, resp.Status)
+	}
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return fmt.Errorf("netter: websocket %s: transport does not support upgrades", ws.url)
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(sum[:]) {
+		rwc.Close()
+		return fmt.Errorf("netter: websocket %s: bad Sec-WebSocket-Accept", ws.url)
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		rwc.Close()
+		return ErrWebSocketClosed
+	}
+	if ws.rwc != nil {
+		ws.rwc.Close()
+	}
+	ws.rwc, ws.br = rwc, bufio.NewReader(rwc)
+	ws.lastRead = time.Now()
+	ws.Subprotocol = resp.Header.Get("Sec-WebSocket-Protocol")
+	return nil
+}
+
+// ReadMessage returns the next text or binary message. Pings are
+// answered meanwhile. With Reconnect set, a dropped connection is
+// redialed and reading goes on; a close frame of the peer ends it.
+func (ws *WebSocketConn) ReadMessage() (typ int, data []byte, err error) {
+	for {
+		typ, data, err = ws.readMessage()
+		if err == nil || !ws.opts.Reconnect || ws.isClosed() || ws.ctx.Err() != nil {
+			return typ, data, err
+		}
+		var closeErr *WebSocketCloseError
+		if errors.As(err, &closeErr) {
+			return typ, data, err
+		}
+		ws.c.log().Printf("netter: websocket %s dropped: %v, reconnecting", ws.url, err)
+		if err := ws.dial(); err != nil {
+			return 0, nil, err
+		}
+		if ws.opts.OnReconnect != nil {
+			if err := ws.opts.OnReconnect(ws); err != nil {
+				return 0, nil, err
+			}
+		}
+	}
+}
+
+func (ws *WebSocketConn) readMessage() (int, []byte, error) {
+	ws.mu.Lock()
+	br := ws.br
+	ws.reading = true
+	ws.lastRead = time.Now()
+	ws.mu.Unlock()
+	defer func() {
+		ws.mu.Lock()
+		ws.reading = false
+		ws.mu.Unlock()
+	}()
+
+	limit := ws.opts.MaxMessageBytes
+	if limit <= 0 {
+		limit = maxWebSocketMessage
+	}
+	var (
+		typ  int
+		data []byte
+	)
+	for {
+		fin, op, payload, err := readFrame(br, limit-int64(len(data)))
+		if err != nil {
+			return 0, nil, err
+		}
+		ws.mu.Lock()
+		ws.lastRead = time.Now()
+		ws.mu.Unlock()
+
+		switch op {
+		case PingMessage:
+			if err := ws.writeFrame(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			e := &WebSocketCloseError{Code: 1005}
+			if len(payload) >= 2 {
+				e.Code = int(binary.BigEndian.Uint16(payload))
+				e.Reason = string(payload[2:])
+			}
+			ws.writeFrame(CloseMessage, payload[:min(len(payload), 2)])
+			ws.shutdown()
+			return 0, nil, e
+		case 0:
+			if typ == 0 {
+				return 0, nil, errors.New("netter: websocket: unexpected continuation frame")
+			}
+		default:
+			if typ != 0 {
+				return 0, nil, errors.New("netter: websocket: interleaved message")
+			}
+			typ = op
+		}
+		data = append(data, payload...)
+		if fin {
+			return typ, data, nil
+		}
+	}
+}
+
+// WriteMessage sends data as a single text or binary message
+func (ws *WebSocketConn) WriteMessage(typ int, data []byte) error {
+	return ws.writeFrame(typ, data)
+}
+
+// Close sends a normal closure frame and closes the connection
+func (ws *WebSocketConn) Close() error {
+	if ws.isClosed() {
+		return nil
+	}
+	ws.writeFrame(CloseMessage, []byte{0x03, 0xe8})
+	return ws.shutdown()
+}
+
+func (ws *WebSocketConn) shutdown() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return nil
+	}
+	ws.closed = true
+	close(ws.done)
+	return ws.rwc.Close()
+}
+
+func (ws *WebSocketConn) isClosed() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.closed
+}
+
+// keepalive pings the server and drops connections gone silent
+func (ws *WebSocketConn) keepalive() {
+	interval := ws.opts.PingInterval
+	if interval == 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ws.done:
+			return
+		case <-ticker.C:
+		}
+		ws.mu.Lock()
+		silent := ws.reading && time.Since(ws.lastRead) > 2*interval
+		rwc := ws.rwc
+		ws.mu.Unlock()
+		if silent {
+			// unblocks the reader, which may reconnect
+			rwc.Close()
+			continue
+		}
+		ws.writeFrame(PingMessage, nil)
+	}
+}
+
+// writeFrame sends a single masked frame
+func (ws *WebSocketConn) writeFrame(op int, payload []byte) error {
+	ws.mu.Lock()
+	rwc, closed := ws.rwc, ws.closed
+	ws.mu.Unlock()
+	if closed {
+		return ErrWebSocketClosed
+	}
+
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|byte(op))
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, 0x80|byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 0x80|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 0x80|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	buf = append(buf, mask[:]...)
+	for i, b := range payload {
+		buf = append(buf, b^mask[i%4])
+	}
+
+	ws.wmu.Lock()
+	defer ws.wmu.Unlock()
+	_, err := rwc.Write(buf)
+	return err
+}
+
+// readFrame reads a single unmasked server frame of at most limit bytes
+func readFrame(r *bufio.Reader, limit int64) (fin bool, op int, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, op = hdr[0]&0x80 != 0, int(hdr[0]&0x0f)
+	if hdr[1]&0x80 != 0 {
+		return false, 0, nil, errors.New("netter: websocket: masked server frame")
+	}
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > uint64(limit) {
+		return false, 0, nil, fmt.Errorf("netter: websocket: message exceeds %d bytes", limit)
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	return fin, op, payload, nil
+}
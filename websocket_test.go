@@ -0,0 +1,101 @@
+package netgo
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// echoWebSocket upgrades and echoes every message back unmasked
+func echoWebSocket(w http.ResponseWriter, req *http.Request) {
+	sum := sha1.Sum([]byte(req.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+	conn, rw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n")
+	rw.Flush()
+	for {
+		op, payload, err := readClientFrame(rw.Reader)
+		if err != nil || op == CloseMessage {
+			return
+		}
+		frame := []byte{0x80 | byte(op), byte(len(payload))}
+		rw.Write(append(frame, payload...))
+		rw.Flush()
+	}
+}
+
+func readClientFrame(r *bufio.Reader) (int, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(hdr[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(r, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	var mask [4]byte
+	io.ReadFull(r, mask[:])
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return int(hdr[0] & 0x0f), payload, nil
+}
+
+func TestDialWebSocket(t *testing.T) {
+	var handshakes int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&handshakes, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		echoWebSocket(w, req)
+	}))
+	defer ts.Close()
+
+	client := NewClient(
+		WithTransport(ts.Client().Transport),
+		WithRetry(Retry{Max: 2, WaitMin: time.Millisecond, WaitMax: time.Millisecond}),
+		WithDefaultHeader("Authorization", "Bearer t"),
+	)
+	ws, err := client.DialWebSocket(context.Background(), "ws"+strings.TrimPrefix(ts.URL, "http"), &WebSocketOptions{PingInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	// outlives the client timeout and several keepalive pings
+	time.Sleep(50 * time.Millisecond)
+	if err := ws.WriteMessage(TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	typ, data, err := ws.ReadMessage()
+	if err != nil || typ != TextMessage || string(data) != "hello" {
+		t.Fatalf("got %d %q %v", typ, data, err)
+	}
+	if n := atomic.LoadInt32(&handshakes); n != 2 {
+		t.Fatalf("%d handshakes, want 2", n)
+	}
+}